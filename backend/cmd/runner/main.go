@@ -0,0 +1,24 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/theweirdfulmurk/cfd-platform/internal/runner"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("cfd-runner: ")
+
+	if len(os.Args) > 1 && os.Args[1] == runner.RshCommand {
+		os.Exit(runner.Rsh(os.Args[2:]))
+	}
+
+	spec, err := runner.DecodeSpec(os.Getenv(runner.SpecEnv))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	os.Exit(runner.Run(spec))
+}
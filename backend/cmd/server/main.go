@@ -1,13 +1,19 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/theweirdfulmurk/cfd-platform/internal/archive"
+	"github.com/theweirdfulmurk/cfd-platform/internal/config"
 	httpHandler "github.com/theweirdfulmurk/cfd-platform/internal/delivery/http"
+	"github.com/theweirdfulmurk/cfd-platform/internal/domain"
 	"github.com/theweirdfulmurk/cfd-platform/internal/infrastructure/k8s"
 	"github.com/theweirdfulmurk/cfd-platform/internal/repository"
 	"github.com/theweirdfulmurk/cfd-platform/internal/usecase"
@@ -17,6 +23,26 @@ func main() {
 	// Configuration
 	namespace := getEnv("K8S_NAMESPACE", "default")
 	port := getEnv("PORT", "8080")
+	storageDriver := getEnv("STORAGE_DRIVER", "memory")
+	sqlitePath := getEnv("SQLITE_PATH", "/pvc/db/cfd-platform.db")
+	postgresDSN := getEnv("POSTGRES_DSN", "")
+	postgresMaxConns := getEnvInt("POSTGRES_MAX_CONNS", 10)
+	reconcileInterval := getEnvDuration("RECONCILE_INTERVAL", 5*time.Minute)
+	reconcileGC := getEnv("RECONCILE_GC_ORPHANS", "false") == "true"
+	watchResync := getEnvDuration("WATCH_RESYNC", 10*time.Minute)
+	eventHistory := getEnvInt("EVENT_HISTORY", 1000)
+	leaderElection := getEnv("LEADER_ELECTION", "false") == "true"
+	podName := getEnv("POD_NAME", "")
+	resourcePolicyFile := getEnv("RESOURCE_POLICY_FILE", "")
+	solverCatalogFile := getEnv("SOLVER_CATALOG_FILE", "")
+	runnerImage := getEnv("RUNNER_IMAGE", "cfd-platform-backend:latest")
+	uploadLimits := archive.DefaultLimits()
+	uploadLimits.MaxBytes = int64(getEnvInt("UPLOAD_MAX_EXTRACTED_BYTES", int(uploadLimits.MaxBytes)))
+	uploadLimits.MaxEntries = getEnvInt("UPLOAD_MAX_ENTRIES", uploadLimits.MaxEntries)
+	archiveCacheBytes := int64(getEnvInt("ARCHIVE_CACHE_MAX_BYTES", 1<<30))
+	if getEnv("CACHE_RESULT_ARCHIVES", "false") != "true" {
+		archiveCacheBytes = 0
+	}
 
 	// Initialize K8s client
 	k8sClient, err := k8s.NewClient()
@@ -24,17 +50,99 @@ func main() {
 		log.Fatalf("Failed to create K8s client: %v", err)
 	}
 
+	resourcePolicy, err := config.LoadResourcePolicy(resourcePolicyFile)
+	if err != nil {
+		log.Fatalf("Failed to load resource policy: %v", err)
+	}
+	solverCatalog, err := config.LoadSolverCatalog(solverCatalogFile)
+	if err != nil {
+		log.Fatalf("Failed to load solver catalog: %v", err)
+	}
+
 	// Infrastructure
-	vizK8sManager := k8s.NewVisualizationManager(k8sClient, namespace)
-	simK8sManager := k8s.NewSimulationManager(k8sClient, namespace)
+	vizK8sManager := k8s.NewVisualizationManager(k8sClient, namespace, solverCatalog.VisualizationImage)
+	simK8sManager := k8s.NewSimulationManager(k8sClient, namespace, solverCatalog, runnerImage)
 
 	// Repositories
-	vizRepo := repository.NewInMemoryVisualizationRepo()
-	simRepo := repository.NewInMemorySimulationRepo()
+	var (
+		vizRepo  domain.VisualizationRepository
+		simRepo  domain.SimulationRepository
+		eventLog domain.EventLog
+	)
+	switch storageDriver {
+	case "memory":
+		vizRepo = repository.NewInMemoryVisualizationRepo()
+		simRepo = repository.NewInMemorySimulationRepo()
+	case "sqlite":
+		db, err := repository.OpenSQLite(sqlitePath)
+		if err != nil {
+			log.Fatalf("Failed to open SQLite database: %v", err)
+		}
+		defer db.Close()
+		vizRepo = repository.NewSQLiteVisualizationRepo(db)
+		simRepo = repository.NewSQLiteSimulationRepo(db)
+	case "postgres":
+		db, err := repository.OpenPostgres(repository.PostgresConfig{
+			DSN:             postgresDSN,
+			MaxOpenConns:    postgresMaxConns,
+			MaxIdleConns:    postgresMaxConns / 2,
+			ConnMaxLifetime: 30 * time.Minute,
+		})
+		if err != nil {
+			log.Fatalf("Failed to open PostgreSQL database: %v", err)
+		}
+		defer db.Close()
+		vizRepo = repository.NewPostgresVisualizationRepo(db)
+		simRepo = repository.NewPostgresSimulationRepo(db)
+		eventLog = repository.NewPostgresEventLog(db, eventHistory)
+	default:
+		log.Fatalf("Unknown STORAGE_DRIVER: %s", storageDriver)
+	}
+	if leaderElection && eventLog == nil {
+		log.Fatalf("LEADER_ELECTION needs STORAGE_DRIVER=postgres to share state between replicas")
+	}
 
 	// Use Cases
 	vizUseCase := usecase.NewVisualizationUseCase(vizRepo, vizK8sManager)
-	simUseCase := usecase.NewSimulationUseCase(simRepo, simK8sManager)
+	simEvents := usecase.NewEventBroker(eventHistory)
+	if eventLog != nil {
+		// Clients may follow events on any replica
+		if err := simEvents.Share(context.Background(), eventLog); err != nil {
+			log.Fatalf("Failed to share events: %v", err)
+		}
+	}
+	simUseCase := usecase.NewSimulationUseCase(simRepo, simK8sManager, resourcePolicy, solverCatalog, uploadLimits, simEvents)
+	simUseCase.CacheArchives(archiveCacheBytes)
+
+	reconciler := usecase.NewReconciler(simRepo, vizRepo, simK8sManager, vizK8sManager, simEvents, reconcileGC)
+
+	// Applying status, following solver logs and reconciling must happen
+	// once however many replicas serve requests, so only the leader does it
+	lead := func(ctx context.Context) {
+		// Push Job and Pod status changes into the repositories as they happen
+		statusWatcher := k8s.NewStatusWatcher(k8sClient, namespace, watchResync)
+		statusWatcher.OnSimulation(simUseCase.ApplyJobState)
+		statusWatcher.OnVisualization(vizUseCase.ApplyPodState)
+		if err := statusWatcher.Start(ctx); err != nil {
+			log.Fatalf("Failed to start status watcher: %v", err)
+		}
+
+		// Rebuild state from the cluster on startup and periodically afterwards
+		reconciler.Run(ctx, reconcileInterval)
+	}
+	if leaderElection {
+		if podName == "" {
+			podName, _ = os.Hostname()
+		}
+		go func() {
+			k8s.Lead(context.Background(), k8sClient, namespace, podName, lead)
+			// Log followers started while leading would race with the new
+			// leader's; start over as a follower instead
+			log.Fatalf("Lost the backend lease")
+		}()
+	} else {
+		go lead(context.Background())
+	}
 
 	// HTTP Handlers
 	vizHandler := httpHandler.NewVisualizationHandler(vizUseCase)
@@ -55,9 +163,21 @@ func main() {
 		r.Route("/simulations", func(r chi.Router) {
 			r.Post("/", simHandler.Create)
 			r.Get("/", simHandler.List)
+			r.Get("/events", simHandler.Events)
 			r.Get("/{simId}", simHandler.Get)
 			r.Delete("/{simId}", simHandler.Delete)
+			r.Post("/{simId}/cancel", simHandler.Cancel)
+			r.Post("/{simId}/suspend", simHandler.Suspend)
+			r.Post("/{simId}/resume", simHandler.Resume)
+			r.Post("/{simId}/restart", simHandler.Restart)
 			r.Get("/{simId}/results", simHandler.DownloadResults)
+			r.Get("/{simId}/results/files", simHandler.ResultFiles)
+			r.Get("/{simId}/results/files/*", simHandler.ResultFile)
+			r.Get("/{simId}/events", simHandler.SimulationEvents)
+			r.Get("/{simId}/logs", simHandler.Logs)
+			r.Get("/{simId}/residuals", simHandler.Residuals)
+			r.Get("/{simId}/stages", simHandler.Stages)
+			r.Get("/{simId}/mesh-report", simHandler.MeshReport)
 
 			// Visualization routes nested under simulation
 			r.Get("/{simId}/visualizations", vizHandler.ListBySimulation)
@@ -70,6 +190,8 @@ func main() {
 			r.Get("/{vizId}/ws-url", vizHandler.GetWebSocketURL)
 			r.Delete("/{vizId}", vizHandler.Delete)
 		})
+
+		r.Get("/solvers", simHandler.Solvers)
 	})
 
 	// Health check
@@ -92,6 +214,30 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", key, err)
+	}
+	return d
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", key, err)
+	}
+	return n
+}
+
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
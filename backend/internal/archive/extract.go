@@ -0,0 +1,157 @@
+// Package archive unpacks uploaded archives onto the shared volume and
+// streams result archives for download.
+package archive
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ErrUnsafeArchive is returned for archives that were rejected rather than
+// unreadable: unsafe entries or limits exceeded.
+var ErrUnsafeArchive = errors.New("unsafe archive")
+
+// Limits bound what an archive may expand to.
+type Limits struct {
+	MaxBytes   int64 // total size of all extracted files
+	MaxEntries int   // files and directories
+}
+
+// DefaultLimits fit typical OpenFOAM cases with a pre-built mesh.
+func DefaultLimits() Limits {
+	return Limits{
+		MaxBytes:   2 << 30, // 2 GiB
+		MaxEntries: 20000,
+	}
+}
+
+// ExtractTarGz unpacks a gzip-compressed tar archive into dest, which must
+// not exist yet. Only regular files and directories are accepted; absolute
+// names, ".." components and links of any kind reject the whole archive.
+// Files are written with normalized permissions, keeping just the execute
+// bit so scripts like Allrun still run. On error dest is removed again.
+func ExtractTarGz(r io.Reader, dest string, limits Limits) (err error) {
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("invalid gzip format: %w", err)
+	}
+	defer gzr.Close()
+
+	if err := os.Mkdir(dest, 0755); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			os.RemoveAll(dest)
+		}
+	}()
+
+	tr := tar.NewReader(gzr)
+	var (
+		entries int
+		written int64
+	)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar: %w", err)
+		}
+		if hdr.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+
+		name, err := entryPath(hdr.Name)
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			continue // the archive's "./" entry
+		}
+
+		entries++
+		if entries > limits.MaxEntries {
+			return fmt.Errorf("%w: more than %d entries", ErrUnsafeArchive, limits.MaxEntries)
+		}
+
+		target := filepath.Join(dest, filepath.FromSlash(name))
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			if hdr.Size > limits.MaxBytes-written {
+				return fmt.Errorf("%w: extracted size exceeds %d bytes", ErrUnsafeArchive, limits.MaxBytes)
+			}
+			n, err := writeFile(target, tr, hdr.FileInfo().Mode(), limits.MaxBytes-written)
+			written += n
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%w: %s is not a regular file or directory", ErrUnsafeArchive, hdr.Name)
+		}
+	}
+}
+
+// entryPath cleans an entry name and rejects anything that could leave the
+// destination directory.
+func entryPath(name string) (string, error) {
+	if strings.ContainsRune(name, 0) || strings.Contains(name, `\`) {
+		return "", fmt.Errorf("%w: invalid entry name %q", ErrUnsafeArchive, name)
+	}
+	if path.IsAbs(name) {
+		return "", fmt.Errorf("%w: absolute path %s", ErrUnsafeArchive, name)
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("%w: parent-relative path %s", ErrUnsafeArchive, name)
+		}
+	}
+
+	cleaned := path.Clean(name)
+	if cleaned == "." {
+		return "", nil
+	}
+	return cleaned, nil
+}
+
+// writeFile creates a new file; an entry that names an existing path is an
+// error rather than an overwrite. It returns the number of bytes written and
+// fails once more than limit bytes arrive, whatever the header claimed.
+func writeFile(target string, r io.Reader, mode os.FileMode, limit int64) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return 0, err
+	}
+
+	perm := os.FileMode(0644)
+	if mode&0111 != 0 {
+		perm = 0755
+	}
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := io.Copy(f, io.LimitReader(r, limit+1))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return n, err
+	}
+	if n > limit {
+		return n, fmt.Errorf("%w: extracted size exceeds the limit", ErrUnsafeArchive)
+	}
+	return n, nil
+}
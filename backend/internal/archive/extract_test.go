@@ -0,0 +1,160 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// entry is a tar entry for tests; Body sets the size of regular files.
+type entry struct {
+	Name     string
+	Type     byte
+	Body     string
+	Mode     int64
+	Linkname string
+}
+
+func tarGz(t *testing.T, entries ...entry) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.Name, Typeflag: e.Type, Mode: e.Mode, Linkname: e.Linkname}
+		if hdr.Typeflag == 0 {
+			hdr.Typeflag = tar.TypeReg
+		}
+		if hdr.Mode == 0 {
+			hdr.Mode = 0644
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			hdr.Size = int64(len(e.Body))
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.Body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestExtractTarGz(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []entry
+		limits  Limits
+		unsafe  bool // rejected with ErrUnsafeArchive
+		failed  bool // rejected for another reason
+	}{
+		{name: "case", entries: []entry{
+			{Name: "./", Type: tar.TypeDir, Mode: 0755},
+			{Name: "cavity/", Type: tar.TypeDir, Mode: 0755},
+			{Name: "cavity/system/controlDict", Body: "application icoFoam;\n"},
+			{Name: "cavity/Allrun", Body: "#!/bin/sh\n", Mode: 0775},
+		}},
+		{name: "absolute path", entries: []entry{{Name: "/etc/passwd", Body: "x"}}, unsafe: true},
+		{name: "parent directory", entries: []entry{{Name: "cavity/../../escape", Body: "x"}}, unsafe: true},
+		{name: "parent directory only", entries: []entry{{Name: "..", Type: tar.TypeDir}}, unsafe: true},
+		{name: "backslash", entries: []entry{{Name: `cavity\..\escape`, Body: "x"}}, unsafe: true},
+		{name: "symlink", entries: []entry{{Name: "link", Type: tar.TypeSymlink, Linkname: "/etc"}}, unsafe: true},
+		{name: "hard link", entries: []entry{
+			{Name: "a", Body: "x"},
+			{Name: "b", Type: tar.TypeLink, Linkname: "a"},
+		}, unsafe: true},
+		{name: "device", entries: []entry{{Name: "null", Type: tar.TypeChar}}, unsafe: true},
+		{name: "too many entries", entries: []entry{
+			{Name: "a", Body: "x"}, {Name: "b", Body: "x"}, {Name: "c", Body: "x"},
+		}, limits: Limits{MaxBytes: 100, MaxEntries: 2}, unsafe: true},
+		{name: "too large", entries: []entry{
+			{Name: "a", Body: strings.Repeat("x", 60)},
+			{Name: "b", Body: strings.Repeat("x", 60)},
+		}, limits: Limits{MaxBytes: 100, MaxEntries: 10}, unsafe: true},
+		{name: "exactly at the limit", entries: []entry{
+			{Name: "a", Body: strings.Repeat("x", 50)},
+			{Name: "b", Body: strings.Repeat("x", 50)},
+		}, limits: Limits{MaxBytes: 100, MaxEntries: 2}},
+		{name: "duplicate file", entries: []entry{
+			{Name: "a", Body: "first"},
+			{Name: "a", Body: "second"},
+		}, failed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limits := tt.limits
+			if limits == (Limits{}) {
+				limits = DefaultLimits()
+			}
+			dest := filepath.Join(t.TempDir(), "case")
+
+			err := ExtractTarGz(tarGz(t, tt.entries...), dest, limits)
+			switch {
+			case tt.unsafe && !errors.Is(err, ErrUnsafeArchive):
+				t.Fatalf("err = %v, want ErrUnsafeArchive", err)
+			case tt.failed && err == nil:
+				t.Fatal("err = nil, want an error")
+			case !tt.unsafe && !tt.failed && err != nil:
+				t.Fatalf("err = %v", err)
+			}
+			if err != nil {
+				if _, statErr := os.Stat(dest); !os.IsNotExist(statErr) {
+					t.Errorf("destination left behind after %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractTarGzFiles(t *testing.T) {
+	dest := filepath.Join(t.TempDir(), "case")
+	err := ExtractTarGz(tarGz(t,
+		entry{Name: "system/controlDict", Body: "application icoFoam;\n", Mode: 0600},
+		entry{Name: "Allrun", Body: "#!/bin/sh\n", Mode: 0700},
+	), dest, DefaultLimits())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dest, "system", "controlDict"))
+	if err != nil || string(data) != "application icoFoam;\n" {
+		t.Errorf("controlDict = %q, %v", data, err)
+	}
+	// Permissions are normalized, keeping only whether a file is executable.
+	for name, exec := range map[string]bool{"system/controlDict": false, "Allrun": true} {
+		info, err := os.Stat(filepath.Join(dest, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode()&0100 != 0; got != exec {
+			t.Errorf("%s executable = %v, want %v", name, got, exec)
+		}
+	}
+}
+
+func TestExtractTarGzRejectsBadInput(t *testing.T) {
+	dir := t.TempDir()
+	if err := ExtractTarGz(strings.NewReader("not gzip"), filepath.Join(dir, "a"), DefaultLimits()); err == nil {
+		t.Error("plain text accepted as an archive")
+	}
+
+	existing := filepath.Join(dir, "existing")
+	if err := os.Mkdir(existing, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ExtractTarGz(tarGz(t, entry{Name: "a", Body: "x"}), existing, DefaultLimits()); err == nil {
+		t.Error("extracted into an existing directory")
+	}
+}
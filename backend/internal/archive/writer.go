@@ -0,0 +1,131 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Format is an archive format for downloads.
+type Format string
+
+const (
+	FormatZip    Format = "zip"
+	FormatTarGz  Format = "tar.gz"
+	FormatTarZst Format = "tar.zst"
+)
+
+// ParseFormat accepts the name of a format; an empty name means zip.
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(name); f {
+	case "":
+		return FormatZip, nil
+	case FormatZip, FormatTarGz, FormatTarZst:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown archive format %q, use zip, tar.gz or tar.zst", name)
+}
+
+// ContentType is the media type of archives in the format.
+func (f Format) ContentType() string {
+	switch f {
+	case FormatTarGz:
+		return "application/gzip"
+	case FormatTarZst:
+		return "application/zstd"
+	}
+	return "application/zip"
+}
+
+// Writer streams files into an archive. Nothing is buffered beyond what
+// the compressor needs, so archives of any size can be written straight to
+// a response.
+type Writer interface {
+	// Add stores exactly size bytes from r under name, a slash-separated
+	// path.
+	Add(name string, size int64, modified time.Time, r io.Reader) error
+	// Close writes the end of the archive. It does not close the
+	// underlying writer.
+	Close() error
+}
+
+// NewWriter starts an archive in format f on w.
+func NewWriter(w io.Writer, f Format) (Writer, error) {
+	switch f {
+	case FormatZip:
+		return &zipWriter{zw: zip.NewWriter(w)}, nil
+	case FormatTarGz:
+		gz, err := gzip.NewWriterLevel(w, gzip.DefaultCompression)
+		if err != nil {
+			return nil, err
+		}
+		return &tarWriter{tw: tar.NewWriter(gz), compressor: gz}, nil
+	case FormatTarZst:
+		// One goroutine per download; the encoder runs synchronously and
+		// leaves nothing behind if a download is abandoned.
+		zw, err := zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return &tarWriter{tw: tar.NewWriter(zw), compressor: zw}, nil
+	}
+	return nil, fmt.Errorf("unknown archive format %q", f)
+}
+
+type zipWriter struct {
+	zw *zip.Writer
+}
+
+func (z *zipWriter) Add(name string, size int64, modified time.Time, r io.Reader) error {
+	header := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified}
+	header.SetMode(0644)
+	w, err := z.zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	return copySize(w, r, name, size)
+}
+
+func (z *zipWriter) Close() error { return z.zw.Close() }
+
+type tarWriter struct {
+	tw         *tar.Writer
+	compressor io.WriteCloser
+}
+
+func (t *tarWriter) Add(name string, size int64, modified time.Time, r io.Reader) error {
+	err := t.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0644,
+		Size:     size,
+		ModTime:  modified,
+		Format:   tar.FormatPAX,
+	})
+	if err != nil {
+		return err
+	}
+	return copySize(t.tw, r, name, size)
+}
+
+func (t *tarWriter) Close() error {
+	if err := t.tw.Close(); err != nil {
+		return err
+	}
+	return t.compressor.Close()
+}
+
+// copySize copies a file whose size was announced up front; a file that
+// shrank or grew meanwhile would corrupt a tar stream.
+func copySize(w io.Writer, r io.Reader, name string, size int64) error {
+	n, err := io.CopyN(w, r, size)
+	if err == io.EOF {
+		return fmt.Errorf("%s shrank from %d to %d bytes while archiving", name, size, n)
+	}
+	return err
+}
@@ -0,0 +1,164 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		want Format
+		ok   bool
+	}{
+		{"", FormatZip, true},
+		{"zip", FormatZip, true},
+		{"tar.gz", FormatTarGz, true},
+		{"tar.zst", FormatTarZst, true},
+		{"rar", "", false},
+		{"TAR.GZ", "", false},
+	}
+	for _, tt := range tests {
+		got, err := ParseFormat(tt.name)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("ParseFormat(%q) = %q, %v", tt.name, got, err)
+		}
+	}
+}
+
+// readArchive returns the files of an archive written by NewWriter.
+func readArchive(t *testing.T, f Format, data []byte) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	if f == FormatZip {
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, file := range zr.File {
+			r, err := file.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			content, err := io.ReadAll(r)
+			r.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			files[file.Name] = string(content)
+		}
+		return files
+	}
+
+	var r io.Reader
+	switch f {
+	case FormatTarGz:
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = gz
+	case FormatTarZst:
+		zr, err := zstd.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[hdr.Name] = string(content)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	files := map[string]string{
+		"0.5/U":          strings.Repeat("(1 0 0)\n", 1000),
+		"log.simpleFoam": "Time = 0.5\n",
+		"empty":          "",
+	}
+	modified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	for _, f := range []Format{FormatZip, FormatTarGz, FormatTarZst} {
+		t.Run(string(f), func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriter(&buf, f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range []string{"0.5/U", "empty", "log.simpleFoam"} {
+				if err := w.Add(name, int64(len(files[name])), modified, strings.NewReader(files[name])); err != nil {
+					t.Fatalf("Add(%s): %v", name, err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			got := readArchive(t, f, buf.Bytes())
+			if len(got) != len(files) {
+				t.Errorf("archive has %d files, want %d", len(got), len(files))
+			}
+			for name, want := range files {
+				if got[name] != want {
+					t.Errorf("%s = %d bytes, want %d", name, len(got[name]), len(want))
+				}
+			}
+		})
+	}
+}
+
+func TestWriterRejectsChangedFiles(t *testing.T) {
+	for _, f := range []Format{FormatZip, FormatTarGz, FormatTarZst} {
+		t.Run(string(f), func(t *testing.T) {
+			w, err := NewWriter(io.Discard, f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// A file that shrank after its size was taken.
+			err = w.Add("log", 10, time.Now(), strings.NewReader("short"))
+			if err == nil || !strings.Contains(err.Error(), "shrank from 10 to 5 bytes") {
+				t.Errorf("err = %v, want the file to have shrunk", err)
+			}
+		})
+	}
+
+	// A file that grew is cut at the announced size.
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf, FormatTarGz)
+	if err := w.Add("log", 4, time.Now(), strings.NewReader("Time = 1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readArchive(t, FormatTarGz, buf.Bytes())["log"]; got != "Time" {
+		t.Errorf("log = %q, want the first 4 bytes", got)
+	}
+}
+
+func TestNewWriterUnknownFormat(t *testing.T) {
+	if _, err := NewWriter(io.Discard, "rar"); err == nil {
+		t.Error("NewWriter accepted an unknown format")
+	}
+}
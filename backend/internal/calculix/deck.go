@@ -0,0 +1,196 @@
+// Package calculix reads CalculiX input decks.
+package calculix
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/theweirdfulmurk/cfd-platform/internal/casefile"
+)
+
+// Error is a problem in a deck, reported with its line number.
+type Error = casefile.Error
+
+// Card is a keyword line, e.g. "*SOLID SECTION, ELSET=EALL, MATERIAL=STEEL".
+type Card struct {
+	// Keyword is upper case without blanks or the leading "*", e.g.
+	// "SOLIDSECTION"; CalculiX ignores both.
+	Keyword string
+	// Params maps upper-case parameter names to their values; parameters
+	// without "=" map to "".
+	Params map[string]string
+	File   string
+	Line   int
+}
+
+// Param returns a parameter value in upper case, the way CalculiX compares
+// names.
+func (c *Card) Param(name string) (string, bool) {
+	v, ok := c.Params[name]
+	return strings.ToUpper(v), ok
+}
+
+// DataLine is a data line of the current card, split at commas.
+type DataLine struct {
+	Fields []string
+	File   string
+	Line   int
+}
+
+// Visitor receives the cards of a deck in order. data is nil for the
+// keyword line itself and set for each of its data lines.
+type Visitor func(card *Card, data *DataLine) error
+
+// maxIncludeDepth stops *INCLUDE cycles.
+const maxIncludeDepth = 8
+
+// maxLineLength bounds a single line, continuations included.
+const maxLineLength = 1 << 20
+
+// Walk streams the deck name in dir to visit, following *INCLUDE cards.
+// Included files are resolved relative to dir and may not leave it.
+func Walk(dir, name string, visit Visitor) error {
+	w := &walker{dir: dir, visit: visit}
+	return w.file(name, nil, 0)
+}
+
+type walker struct {
+	dir   string
+	visit Visitor
+}
+
+func (w *walker) file(name string, from *Card, depth int) error {
+	f, err := casefile.Open(w.dir, name)
+	if err != nil {
+		if from != nil {
+			return &Error{File: from.File, Line: from.Line, Msg: fmt.Sprintf("cannot include %s: %v", name, err)}
+		}
+		return &Error{File: name, Msg: err.Error()}
+	}
+	defer f.Close()
+	return w.read(f, name, depth)
+}
+
+func (w *walker) read(r io.Reader, name string, depth int) error {
+	lines := &lineReader{scanner: bufio.NewScanner(r), file: name}
+	lines.scanner.Buffer(make([]byte, 64*1024), maxLineLength)
+
+	var card *Card
+	for {
+		text, line, err := lines.next()
+		if err != nil {
+			return err
+		}
+		if line == 0 {
+			return nil
+		}
+
+		if !strings.HasPrefix(text, "*") {
+			if card == nil {
+				return &Error{File: name, Line: line, Msg: "data line before the first keyword"}
+			}
+			// Element lines with many nodes continue after a trailing comma.
+			for card.Keyword == "ELEMENT" && strings.HasSuffix(text, ",") {
+				more, next, err := lines.next()
+				if err != nil {
+					return err
+				}
+				if next == 0 || strings.HasPrefix(more, "*") {
+					return &Error{File: name, Line: line, Msg: "element line continues past the data"}
+				}
+				text += more
+			}
+			fields := strings.Split(strings.TrimSuffix(text, ","), ",")
+			for i := range fields {
+				fields[i] = strings.TrimSpace(fields[i])
+			}
+			if err := w.visit(card, &DataLine{Fields: fields, File: name, Line: line}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		card = parseCard(text, name, line)
+		if card.Keyword == "INCLUDE" {
+			input := card.Params["INPUT"]
+			if input == "" {
+				return &Error{File: name, Line: line, Msg: "*INCLUDE needs INPUT="}
+			}
+			if depth >= maxIncludeDepth {
+				return &Error{File: name, Line: line, Msg: "*INCLUDE nested too deeply"}
+			}
+			if err := w.file(filepath.Clean(input), card, depth+1); err != nil {
+				return err
+			}
+			card = nil
+			continue
+		}
+		if err := w.visit(card, nil); err != nil {
+			return err
+		}
+	}
+}
+
+func parseCard(text, file string, line int) *Card {
+	parts := strings.Split(strings.TrimPrefix(text, "*"), ",")
+	card := &Card{
+		Keyword: strings.ToUpper(strings.Join(strings.Fields(parts[0]), "")),
+		Params:  make(map[string]string),
+		File:    file,
+		Line:    line,
+	}
+	for _, part := range parts[1:] {
+		name, value, _ := strings.Cut(part, "=")
+		name = strings.ToUpper(strings.Join(strings.Fields(name), ""))
+		if name == "" {
+			continue
+		}
+		card.Params[name] = strings.Trim(strings.TrimSpace(value), `"`)
+	}
+	return card
+}
+
+// lineReader yields logical lines: comments ("**") and blank lines are
+// skipped, and keyword lines ending in a comma are joined with the next
+// line.
+type lineReader struct {
+	scanner *bufio.Scanner
+	file    string
+	line    int
+}
+
+// next returns the next logical line and the number of its first physical
+// line, or line 0 at the end of the file.
+func (l *lineReader) next() (string, int, error) {
+	var text string
+	start := 0
+	for l.scanner.Scan() {
+		l.line++
+		s := strings.TrimSpace(l.scanner.Text())
+		if s == "" || strings.HasPrefix(s, "**") {
+			continue
+		}
+		if start == 0 {
+			start = l.line
+			text = s
+		} else {
+			text += s
+		}
+		if !strings.HasPrefix(text, "*") || !strings.HasSuffix(text, ",") {
+			return text, start, nil
+		}
+		if len(text) > maxLineLength {
+			return "", 0, &Error{File: l.file, Line: start, Msg: "keyword line too long"}
+		}
+	}
+	if err := l.scanner.Err(); err != nil {
+		return "", 0, &Error{File: l.file, Line: l.line + 1, Msg: err.Error()}
+	}
+	if start != 0 {
+		return text, start, nil
+	}
+	return "", 0, nil
+}
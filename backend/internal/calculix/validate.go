@@ -0,0 +1,266 @@
+package calculix
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/theweirdfulmurk/cfd-platform/internal/casefile"
+)
+
+// DeckErrors lists the problems found in a deck.
+type DeckErrors = casefile.Errors
+
+// procedures are the keywords that select a step's analysis type.
+var procedures = map[string]bool{
+	"STATIC": true, "FREQUENCY": true, "BUCKLE": true, "DYNAMIC": true,
+	"MODALDYNAMIC": true, "STEADYSTATEDYNAMICS": true, "COMPLEXFREQUENCY": true,
+	"HEATTRANSFER": true, "COUPLEDTEMPERATURE-DISPLACEMENT": true,
+	"UNCOUPLEDTEMPERATURE-DISPLACEMENT": true, "VISCO": true,
+	"ELECTROMAGNETICS": true, "GREEN": true, "SENSITIVITY": true,
+	"CRACKPROPAGATION": true, "FEASIBLEDIRECTION": true, "NOANALYSIS": true,
+}
+
+// sections assign a material to an element set.
+var sections = map[string]bool{
+	"SOLIDSECTION": true, "SHELLSECTION": true, "BEAMSECTION": true,
+	"MEMBRANESECTION": true, "FLUIDSECTION": true, "GAPSECTION": true,
+}
+
+// reference is a use of a named set or material, checked once the whole
+// deck has been read because CalculiX reads decks in several passes.
+type reference struct {
+	kind string // "node set", "element set" or "material"
+	name string
+	file string
+	line int
+}
+
+// deckValidator collects definitions, references and errors while a deck
+// streams past.
+type deckValidator struct {
+	errs DeckErrors
+
+	nodeSets    map[string]bool
+	elementSets map[string]bool
+	materials   map[string]bool
+	refs        []reference
+
+	nodes, elements int
+	inStep          *Card
+	stepProcedure   bool
+	steps           int
+}
+
+// ValidateDeck reads the deck name in dir and everything it includes. It
+// checks the card syntax, that the node and element sets and materials the
+// deck refers to are defined, and that the deck has at least one *STEP with
+// an analysis procedure. It returns DeckErrors with file and line of every
+// problem, or nil.
+func ValidateDeck(dir, name string) error {
+	v := &deckValidator{
+		nodeSets:    map[string]bool{},
+		elementSets: map[string]bool{},
+		materials:   map[string]bool{},
+	}
+
+	if err := Walk(dir, name, v.visit); err != nil {
+		var derr *Error
+		if !errors.As(err, &derr) {
+			return err
+		}
+		v.errs = append(v.errs, derr)
+		return v.errs.Err()
+	}
+	v.finish(name)
+	return v.errs.Err()
+}
+
+func (v *deckValidator) add(file string, line int, format string, args ...any) {
+	v.errs = append(v.errs, &Error{File: file, Line: line, Msg: fmt.Sprintf(format, args...)})
+}
+
+func (v *deckValidator) refer(kind, name, file string, line int) {
+	v.refs = append(v.refs, reference{kind: kind, name: strings.ToUpper(name), file: file, line: line})
+}
+
+func (v *deckValidator) visit(card *Card, data *DataLine) error {
+	if data == nil {
+		v.keyword(card)
+	} else {
+		v.data(card, data)
+	}
+	return nil
+}
+
+func (v *deckValidator) keyword(card *Card) {
+	switch {
+	case card.Keyword == "STEP":
+		if v.inStep != nil {
+			v.add(card.File, card.Line, "*STEP inside the step started on line %d", v.inStep.Line)
+		}
+		v.inStep, v.stepProcedure = card, false
+		v.steps++
+	case card.Keyword == "ENDSTEP":
+		if v.inStep == nil {
+			v.add(card.File, card.Line, "*END STEP without *STEP")
+		} else if !v.stepProcedure {
+			v.add(v.inStep.File, v.inStep.Line, "step has no analysis procedure such as *STATIC")
+		}
+		v.inStep = nil
+	case procedures[card.Keyword]:
+		if v.inStep == nil {
+			v.add(card.File, card.Line, "*%s outside a *STEP", card.Keyword)
+		}
+		v.stepProcedure = true
+	case card.Keyword == "NODE":
+		if set, ok := card.Param("NSET"); ok && set != "" {
+			v.nodeSets[set] = true
+		}
+	case card.Keyword == "ELEMENT":
+		if t, ok := card.Params["TYPE"]; !ok || t == "" {
+			v.add(card.File, card.Line, "*ELEMENT needs TYPE=")
+		}
+		if set, ok := card.Param("ELSET"); ok && set != "" {
+			v.elementSets[set] = true
+		}
+	case card.Keyword == "NSET":
+		if set, _ := card.Param("NSET"); set != "" {
+			v.nodeSets[set] = true
+		} else {
+			v.add(card.File, card.Line, "*NSET needs NSET=")
+		}
+	case card.Keyword == "ELSET":
+		if set, _ := card.Param("ELSET"); set != "" {
+			v.elementSets[set] = true
+		} else {
+			v.add(card.File, card.Line, "*ELSET needs ELSET=")
+		}
+	case card.Keyword == "MATERIAL":
+		if name, _ := card.Param("NAME"); name != "" {
+			v.materials[name] = true
+		} else {
+			v.add(card.File, card.Line, "*MATERIAL needs NAME=")
+		}
+	case sections[card.Keyword]:
+		if set, _ := card.Param("ELSET"); set != "" {
+			v.refer("element set", set, card.File, card.Line)
+		} else {
+			v.add(card.File, card.Line, "*%s needs ELSET=", card.Keyword)
+		}
+		if material, _ := card.Param("MATERIAL"); material != "" {
+			v.refer("material", material, card.File, card.Line)
+		} else if card.Keyword != "GAPSECTION" {
+			v.add(card.File, card.Line, "*%s needs MATERIAL=", card.Keyword)
+		}
+	}
+
+	// Output requests name their sets as parameters.
+	switch card.Keyword {
+	case "NODEPRINT", "NODEFILE", "NODEOUTPUT", "CONTACTPRINT":
+		if set, _ := card.Param("NSET"); set != "" {
+			v.refer("node set", set, card.File, card.Line)
+		}
+	case "ELPRINT", "ELFILE", "ELEMENTOUTPUT":
+		if set, _ := card.Param("ELSET"); set != "" {
+			v.refer("element set", set, card.File, card.Line)
+		}
+	}
+}
+
+func (v *deckValidator) data(card *Card, data *DataLine) {
+	first := data.Fields[0]
+	switch card.Keyword {
+	case "NODE":
+		if !isInt(first) {
+			v.add(data.File, data.Line, "node number %q is not an integer", first)
+			return
+		}
+		for _, coord := range data.Fields[1:] {
+			if _, err := strconv.ParseFloat(fortranFloat(coord), 64); coord != "" && err != nil {
+				v.add(data.File, data.Line, "coordinate %q is not a number", coord)
+				return
+			}
+		}
+		v.nodes++
+	case "ELEMENT":
+		for _, field := range data.Fields {
+			if !isInt(field) {
+				v.add(data.File, data.Line, "element line has %q where a number is expected", field)
+				return
+			}
+		}
+		if len(data.Fields) < 2 {
+			v.add(data.File, data.Line, "element %s has no nodes", first)
+			return
+		}
+		v.elements++
+	case "NSET", "ELSET":
+		if _, generate := card.Params["GENERATE"]; generate {
+			return
+		}
+		kind := "node set"
+		if card.Keyword == "ELSET" {
+			kind = "element set"
+		}
+		for _, field := range data.Fields {
+			if field != "" && !isInt(field) {
+				v.refer(kind, field, data.File, data.Line)
+			}
+		}
+	case "BOUNDARY", "CLOAD", "TEMPERATURE":
+		if first != "" && !isInt(first) {
+			v.refer("node set", first, data.File, data.Line)
+		}
+	case "DLOAD", "DFLUX", "FILM", "RADIATE":
+		if first != "" && !isInt(first) {
+			v.refer("element set", first, data.File, data.Line)
+		}
+	}
+}
+
+// finish checks what can only be known at the end of the deck.
+func (v *deckValidator) finish(name string) {
+	if v.inStep != nil {
+		v.add(v.inStep.File, v.inStep.Line, "*STEP without *END STEP")
+	}
+	if v.nodes == 0 {
+		v.add(name, 0, "deck defines no nodes (*NODE)")
+	}
+	if v.elements == 0 {
+		v.add(name, 0, "deck defines no elements (*ELEMENT)")
+	}
+	if v.steps == 0 {
+		v.add(name, 0, "deck has no *STEP")
+	}
+
+	// CalculiX puts every node and element into NALL and EALL.
+	v.nodeSets["NALL"] = true
+	v.elementSets["EALL"] = true
+
+	defined := map[string]map[string]bool{
+		"node set":    v.nodeSets,
+		"element set": v.elementSets,
+		"material":    v.materials,
+	}
+	reported := map[string]bool{}
+	for _, ref := range v.refs {
+		key := ref.kind + "\x00" + ref.name
+		if defined[ref.kind][ref.name] || reported[key] {
+			continue
+		}
+		reported[key] = true
+		v.add(ref.file, ref.line, "undefined %s %s", ref.kind, ref.name)
+	}
+}
+
+func isInt(s string) bool {
+	_, err := strconv.Atoi(s)
+	return err == nil
+}
+
+// fortranFloat accepts the D exponent of Fortran-style numbers.
+func fortranFloat(s string) string {
+	return strings.NewReplacer("d", "e", "D", "e").Replace(s)
+}
@@ -0,0 +1,118 @@
+package calculix
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const beam = `** A single hexahedron clamped at one end
+*NODE, NSET=NALL
+1, 0, 0, 0
+2, 1, 0, 0
+3, 1, 1, 0
+4, 0, 1, 0
+5, 0, 0, 1
+6, 1, 0, 1
+7, 1, 1, 1
+8, 0, 1, 1.0D0
+*ELEMENT, TYPE=C3D8,
+ ELSET=EALL
+1, 1, 2, 3, 4,
+ 5, 6, 7, 8
+*NSET, NSET=FIX
+1, 4, 5, 8
+*MATERIAL, NAME=STEEL
+*ELASTIC
+210000, 0.3
+*SOLID SECTION, ELSET=EALL, MATERIAL=steel
+*STEP
+*STATIC
+*BOUNDARY
+FIX, 1, 3
+*CLOAD
+2, 1, 100.
+*NODE PRINT, NSET=FIX
+U
+*END STEP
+`
+
+func TestValidateDeck(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string // "line: message" of each problem, in order
+	}{
+		{name: "valid", files: map[string]string{"input.inp": beam}},
+		{name: "include", files: map[string]string{
+			"input.inp":           strings.Replace(beam, "*MATERIAL, NAME=STEEL\n*ELASTIC\n210000, 0.3\n", "*INCLUDE, INPUT=materials/steel.inp\n", 1),
+			"materials/steel.inp": "*MATERIAL, NAME=STEEL\n*ELASTIC\n210000, 0.3\n",
+		}},
+		{name: "undefined references", files: map[string]string{
+			"input.inp": strings.NewReplacer("MATERIAL=steel", "MATERIAL=ALU", "FIX, 1, 3", "CLAMP, 1, 3").Replace(beam),
+		}, want: []string{"20: undefined material ALU", "24: undefined node set CLAMP"}},
+		{name: "bad data", files: map[string]string{
+			"input.inp": strings.NewReplacer("2, 1, 0, 0", "2, one, 0, 0", "210000, 0.3\n", "210000, 0.3\n*NSET\n").Replace(beam),
+		}, want: []string{"4: coordinate \"one\" is not a number", "20: *NSET needs NSET="}},
+		{name: "no step", files: map[string]string{
+			"input.inp": beam[:strings.Index(beam, "*STEP")],
+		}, want: []string{"deck has no *STEP"}},
+		{name: "step without procedure", files: map[string]string{
+			"input.inp": strings.Replace(beam, "*STATIC\n", "", 1),
+		}, want: []string{"21: step has no analysis procedure such as *STATIC"}},
+		{name: "unterminated step", files: map[string]string{
+			"input.inp": strings.Replace(beam, "*END STEP\n", "", 1),
+		}, want: []string{"21: *STEP without *END STEP"}},
+		{name: "data before keyword", files: map[string]string{
+			"input.inp": "1, 0, 0, 0\n" + beam,
+		}, want: []string{"1: data line before the first keyword"}},
+		{name: "missing include", files: map[string]string{
+			"input.inp": "*INCLUDE, INPUT=mesh.inp\n" + beam,
+		}, want: []string{"1: cannot include mesh.inp: file does not exist"}},
+		{name: "include outside", files: map[string]string{
+			"input.inp": "*INCLUDE, INPUT=../mesh.inp\n" + beam,
+		}, want: []string{"1: cannot include ../mesh.inp: path leaves the case directory"}},
+		{name: "include cycle", files: map[string]string{
+			"input.inp": "*INCLUDE, INPUT=input.inp\n",
+		}, want: []string{"1: *INCLUDE nested too deeply"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, text := range tt.files {
+				path := filepath.Join(dir, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := ValidateDeck(dir, "input.inp")
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("err = %v", err)
+				}
+				return
+			}
+			var errs DeckErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("err = %v, want DeckErrors", err)
+			}
+			var got []string
+			for _, e := range errs {
+				if e.Line > 0 {
+					got = append(got, strings.TrimPrefix(e.Error(), e.File+":"))
+				} else {
+					got = append(got, e.Msg)
+				}
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("problems:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+// Package casefile holds what the readers of uploaded solver inputs share:
+// problems reported by file and line, and opening files without following
+// links out of the upload.
+package casefile
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Error is a problem in an input file, reported with its line number.
+type Error struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
+	}
+	return fmt.Sprintf("%s: %s", e.File, e.Msg)
+}
+
+// Errors lists the problems found in one upload.
+type Errors []*Error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// MaxErrors caps a report; past a handful the rest is usually noise.
+const MaxErrors = 20
+
+// Err returns the first MaxErrors problems, or nil when there are none.
+func (e Errors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	if len(e) > MaxErrors {
+		e = e[:MaxErrors]
+	}
+	return e
+}
+
+// ErrOutside is returned for a path that, once symlinks are resolved, is
+// not inside the directory it has to stay in.
+var ErrOutside = errors.New("path leaves the case directory")
+
+// Resolve returns path with symlinks resolved, or ErrOutside unless it is
+// dir itself or lies below it. Uploads may contain links pointing anywhere.
+func Resolve(dir, path string) (string, error) {
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	if rel, err := filepath.Rel(root, resolved); err != nil || !filepath.IsLocal(rel) {
+		return "", ErrOutside
+	}
+	return resolved, nil
+}
+
+// Open opens the regular file rel of dir. A missing file is reported as
+// fs.ErrNotExist, without the paths involved.
+func Open(dir, rel string) (*os.File, error) {
+	if !filepath.IsLocal(rel) {
+		return nil, ErrOutside
+	}
+	path, err := Resolve(dir, filepath.Join(dir, rel))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fs.ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		f.Close()
+		return nil, errors.New("not a regular file")
+	}
+	return f, nil
+}
@@ -0,0 +1,80 @@
+package casefile
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	for _, path := range []string{filepath.Join(dir, "system", "controlDict"), filepath.Join(outside, "secret")} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		"inside":       "system/controlDict",
+		"outside":      filepath.Join(outside, "secret"),
+		"outsideDir":   outside,
+		"system/up":    "../system/controlDict",
+		"system/upper": "../..",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		rel  string
+		want error // nil for a file that opens
+	}{
+		{rel: "system/controlDict"},
+		{rel: "inside"},
+		{rel: "system/up"},
+		{rel: "missing", want: fs.ErrNotExist},
+		{rel: "../secret", want: ErrOutside},
+		{rel: filepath.Join(outside, "secret"), want: ErrOutside},
+		{rel: "outside", want: ErrOutside},
+		{rel: "outsideDir/secret", want: ErrOutside},
+		{rel: "system/upper", want: ErrOutside},
+	}
+	for _, tt := range tests {
+		f, err := Open(dir, tt.rel)
+		if err == nil {
+			f.Close()
+		}
+		if tt.want == nil && err != nil || tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("Open(%q) = %v, want %v", tt.rel, err, tt.want)
+		}
+	}
+
+	if _, err := Open(dir, "system"); err == nil {
+		t.Error("opened a directory")
+	}
+}
+
+func TestErrorsErr(t *testing.T) {
+	var errs Errors
+	if errs.Err() != nil {
+		t.Error("no problems reported as an error")
+	}
+	for i := 1; i <= MaxErrors+5; i++ {
+		errs = append(errs, &Error{File: "input.inp", Line: i, Msg: fmt.Sprint("problem ", i)})
+	}
+	var got Errors
+	if !errors.As(errs.Err(), &got) || len(got) != MaxErrors {
+		t.Fatalf("Err() = %d problems, want %d", len(got), MaxErrors)
+	}
+	if got[0].Error() != "input.inp:1: problem 1" {
+		t.Errorf("first problem = %q", got[0].Error())
+	}
+}
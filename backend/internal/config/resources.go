@@ -0,0 +1,129 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/theweirdfulmurk/cfd-platform/internal/domain"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/yaml"
+)
+
+// Resources mirrors domain.ResourceRequirements with YAML field names.
+type Resources struct {
+	CPU              string `json:"cpu,omitempty"`
+	Memory           string `json:"memory,omitempty"`
+	EphemeralStorage string `json:"ephemeralStorage,omitempty"`
+}
+
+// ResourcePolicy is the admin configuration for solver resources:
+//
+//	defaults:
+//	  cfd: {cpu: "1", memory: 1Gi}
+//	  fea: {cpu: "1", memory: 1Gi}
+//	min: {cpu: 100m, memory: 256Mi}
+//	max: {cpu: "4", memory: 8Gi, ephemeralStorage: 20Gi}
+type ResourcePolicy struct {
+	Defaults map[domain.SimulationType]Resources `json:"defaults"`
+	Min      Resources                           `json:"min"`
+	Max      Resources                           `json:"max"`
+}
+
+// DefaultResourcePolicy is used when no policy file is configured.
+func DefaultResourcePolicy() *ResourcePolicy {
+	return &ResourcePolicy{
+		Defaults: map[domain.SimulationType]Resources{
+			domain.SimTypeCFD: {CPU: "1", Memory: "1Gi"},
+			domain.SimTypeFEA: {CPU: "1", Memory: "1Gi"},
+		},
+		Min: Resources{CPU: "100m", Memory: "256Mi"},
+		Max: Resources{CPU: "4", Memory: "8Gi", EphemeralStorage: "20Gi"},
+	}
+}
+
+// LoadResourcePolicy reads a policy file; an empty path yields the default
+// policy.
+func LoadResourcePolicy(path string) (*ResourcePolicy, error) {
+	if path == "" {
+		return DefaultResourcePolicy(), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read resource policy: %w", err)
+	}
+
+	policy := &ResourcePolicy{}
+	if err := yaml.UnmarshalStrict(data, policy); err != nil {
+		return nil, fmt.Errorf("invalid resource policy %s: %w", path, err)
+	}
+
+	// Check the policy itself once so bad config fails at startup.
+	for _, bound := range []string{
+		policy.Min.CPU, policy.Min.Memory, policy.Min.EphemeralStorage,
+		policy.Max.CPU, policy.Max.Memory, policy.Max.EphemeralStorage,
+	} {
+		if bound == "" {
+			continue
+		}
+		if _, err := resource.ParseQuantity(bound); err != nil {
+			return nil, fmt.Errorf("invalid bound %q in resource policy: %w", bound, err)
+		}
+	}
+	for simType := range policy.Defaults {
+		if _, err := policy.Resolve(simType, domain.ResourceRequirements{}); err != nil {
+			return nil, fmt.Errorf("invalid defaults for %s: %w", simType, err)
+		}
+	}
+
+	return policy, nil
+}
+
+// Resolve fills empty fields of requested with the defaults for simType and
+// checks every value against the configured bounds.
+func (p *ResourcePolicy) Resolve(simType domain.SimulationType, requested domain.ResourceRequirements) (domain.ResourceRequirements, error) {
+	defaults := p.Defaults[simType]
+
+	cpu, err := resolveQuantity("cpu", requested.CPU, defaults.CPU, p.Min.CPU, p.Max.CPU)
+	if err != nil {
+		return domain.ResourceRequirements{}, err
+	}
+	memory, err := resolveQuantity("memory", requested.Memory, defaults.Memory, p.Min.Memory, p.Max.Memory)
+	if err != nil {
+		return domain.ResourceRequirements{}, err
+	}
+	storage, err := resolveQuantity("ephemeralStorage", requested.EphemeralStorage, defaults.EphemeralStorage,
+		p.Min.EphemeralStorage, p.Max.EphemeralStorage)
+	if err != nil {
+		return domain.ResourceRequirements{}, err
+	}
+
+	return domain.ResourceRequirements{CPU: cpu, Memory: memory, EphemeralStorage: storage}, nil
+}
+
+func resolveQuantity(name, requested, fallback, min, max string) (string, error) {
+	value := requested
+	if value == "" {
+		value = fallback
+	}
+	if value == "" {
+		return "", nil
+	}
+
+	q, err := resource.ParseQuantity(value)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s %q is not a valid quantity", domain.ErrInvalidResources, name, value)
+	}
+	if min != "" {
+		if minQ := resource.MustParse(min); q.Cmp(minQ) < 0 {
+			return "", fmt.Errorf("%w: %s %s is below the minimum of %s", domain.ErrInvalidResources, name, value, min)
+		}
+	}
+	if max != "" {
+		if maxQ := resource.MustParse(max); q.Cmp(maxQ) > 0 {
+			return "", fmt.Errorf("%w: %s %s exceeds the maximum of %s", domain.ErrInvalidResources, name, value, max)
+		}
+	}
+
+	return q.String(), nil
+}
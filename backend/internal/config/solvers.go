@@ -0,0 +1,214 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/theweirdfulmurk/cfd-platform/internal/domain"
+	"github.com/theweirdfulmurk/cfd-platform/internal/runner"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/yaml"
+)
+
+// Solver is a catalog entry as written in the config file. The first entry
+// of a type, or the one marked default, is used when a simulation doesn't
+// name a solver; likewise per name for a bare "openfoam".
+type Solver struct {
+	Name         string                `json:"name"`
+	Version      string                `json:"version"`
+	Type         domain.SimulationType `json:"type"`
+	Image        string                `json:"image"`
+	Command      string                `json:"command,omitempty"`
+	Resources    Resources             `json:"resources,omitempty"`
+	InputFormats []string              `json:"inputFormats"`
+	Outputs      []string              `json:"outputs,omitempty"`
+	MPI          bool                  `json:"mpi,omitempty"`
+	Default      bool                  `json:"default,omitempty"`
+}
+
+// SolverCatalog is the admin configuration of solver images:
+//
+//	visualizationImage: kitware/paraview:pvw-v5.7.1-osmesa-py2
+//	solvers:
+//	- name: openfoam
+//	  version: v2312
+//	  type: cfd
+//	  image: opencfd/openfoam-default:2312
+//	  command: |
+//	    source /usr/lib/openfoam/openfoam2312/etc/bashrc
+//	    {{.Run}}
+//	  inputFormats: [.tar.gz]
+//	  outputs: ["{times}", constant/polyMesh, postProcessing, "log.*"]
+//	- name: calculix
+//	  version: 2.21-mpi
+//	  type: fea
+//	  image: registry.example.com/ccx:2.21-pastix-mpi
+//	  inputFormats: [.inp]
+//	  mpi: true
+type SolverCatalog struct {
+	VisualizationImage string   `json:"visualizationImage"`
+	Solvers            []Solver `json:"solvers"`
+}
+
+// DefaultSolverCatalog is used when no catalog file is configured.
+func DefaultSolverCatalog() *SolverCatalog {
+	return &SolverCatalog{
+		VisualizationImage: "kitware/paraview:pvw-v5.7.1-osmesa-py2",
+		Solvers: []Solver{
+			{
+				Name:         "openfoam",
+				Version:      "8",
+				Type:         domain.SimTypeCFD,
+				Image:        "openfoam/openfoam8-paraview56",
+				InputFormats: []string{".tar.gz"},
+			},
+			{
+				Name:         "calculix",
+				Version:      "latest",
+				Type:         domain.SimTypeFEA,
+				Image:        "calculix/ccx:latest",
+				InputFormats: []string{".inp"},
+			},
+		},
+	}
+}
+
+// LoadSolverCatalog reads a catalog file; an empty path yields the default
+// catalog.
+func LoadSolverCatalog(path string) (*SolverCatalog, error) {
+	if path == "" {
+		return DefaultSolverCatalog(), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read solver catalog: %w", err)
+	}
+
+	catalog := &SolverCatalog{}
+	if err := yaml.UnmarshalStrict(data, catalog); err != nil {
+		return nil, fmt.Errorf("invalid solver catalog %s: %w", path, err)
+	}
+	if catalog.VisualizationImage == "" {
+		catalog.VisualizationImage = DefaultSolverCatalog().VisualizationImage
+	}
+
+	if err := catalog.validate(); err != nil {
+		return nil, fmt.Errorf("invalid solver catalog %s: %w", path, err)
+	}
+
+	return catalog, nil
+}
+
+func (c *SolverCatalog) validate() error {
+	refs := make(map[string]bool)
+	defaults := make(map[domain.SimulationType]bool)
+
+	for _, s := range c.Solvers {
+		ref := s.Name + ":" + s.Version
+		switch {
+		case s.Name == "" || s.Version == "":
+			return fmt.Errorf("solver %q needs a name and a version", ref)
+		case strings.Contains(s.Name, ":"):
+			return fmt.Errorf("solver name %q must not contain ':'", s.Name)
+		case s.Type != domain.SimTypeCFD && s.Type != domain.SimTypeFEA:
+			return fmt.Errorf("solver %s has unknown type %q", ref, s.Type)
+		case s.Image == "":
+			return fmt.Errorf("solver %s has no image", ref)
+		case len(s.InputFormats) == 0:
+			return fmt.Errorf("solver %s accepts no input formats", ref)
+		case refs[ref]:
+			return fmt.Errorf("solver %s is listed twice", ref)
+		}
+		refs[ref] = true
+
+		if s.Default {
+			if defaults[s.Type] {
+				return fmt.Errorf("more than one default solver for %s", s.Type)
+			}
+			defaults[s.Type] = true
+		}
+
+		if _, err := template.New(ref).Parse(s.Command); err != nil {
+			return fmt.Errorf("solver %s: invalid command template: %w", ref, err)
+		}
+		for _, pattern := range s.Outputs {
+			if pattern == runner.TimeDirs {
+				continue
+			}
+			if _, err := filepath.Match(pattern, ""); err != nil || !filepath.IsLocal(pattern) {
+				return fmt.Errorf("solver %s: output %q must be a glob inside the simulation directory", ref, pattern)
+			}
+		}
+		for _, q := range []string{s.Resources.CPU, s.Resources.Memory, s.Resources.EphemeralStorage} {
+			if q == "" {
+				continue
+			}
+			if _, err := resource.ParseQuantity(q); err != nil {
+				return fmt.Errorf("solver %s: invalid resource %q: %w", ref, q, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *SolverCatalog) Resolve(ref string, simType domain.SimulationType) (*domain.Solver, error) {
+	name, version, _ := strings.Cut(ref, ":")
+
+	var match *Solver
+	for i := range c.Solvers {
+		s := &c.Solvers[i]
+		if s.Type != simType || (name != "" && s.Name != name) {
+			continue
+		}
+		if version != "" {
+			if s.Version == version {
+				match = s
+				break
+			}
+			continue
+		}
+		if match == nil || (s.Default && !match.Default) {
+			match = s
+		}
+	}
+	if match == nil {
+		if ref == "" {
+			return nil, fmt.Errorf("%w: no solver configured for %s", domain.ErrUnknownSolver, simType)
+		}
+		return nil, fmt.Errorf("%w: %s is not available for %s simulations", domain.ErrUnknownSolver, ref, simType)
+	}
+
+	solver := match.toDomain()
+	return &solver, nil
+}
+
+func (c *SolverCatalog) List() []domain.Solver {
+	solvers := make([]domain.Solver, 0, len(c.Solvers))
+	for _, s := range c.Solvers {
+		solvers = append(solvers, s.toDomain())
+	}
+	return solvers
+}
+
+func (s Solver) toDomain() domain.Solver {
+	return domain.Solver{
+		Name:    s.Name,
+		Version: s.Version,
+		Type:    s.Type,
+		Image:   s.Image,
+		Command: s.Command,
+		Resources: domain.ResourceRequirements{
+			CPU:              s.Resources.CPU,
+			Memory:           s.Resources.Memory,
+			EphemeralStorage: s.Resources.EphemeralStorage,
+		},
+		InputFormats: append([]string(nil), s.InputFormats...),
+		Outputs:      append([]string(nil), s.Outputs...),
+		MPI:          s.MPI,
+	}
+}
@@ -47,13 +47,14 @@ func validateOpenFOAMArchive(file multipart.File, header *multipart.FileHeader)
 	tr := tar.NewReader(gzr)
 
 	requiredFiles := map[string]bool{
-		"system/controlDict":   false,
-		"system/fvSchemes":     false,
-		"system/fvSolution":    false,
-		"constant/transportProperties": false,
+		"system/controlDict": false,
+		"system/fvSchemes":   false,
+		"system/fvSolution":  false,
 	}
 
-	hasPolyMesh := false
+	// A case either ships its mesh or the dictionaries to build it; the
+	// platform runs blockMesh and snappyHexMesh itself.
+	hasMesh := false
 
 	for {
 		hdr, err := tr.Next()
@@ -64,16 +65,18 @@ func validateOpenFOAMArchive(file multipart.File, header *multipart.FileHeader)
 			return fmt.Errorf("failed to read tar: %w", err)
 		}
 
-		path := strings.TrimPrefix(hdr.Name, filepath.Dir(hdr.Name)+"/")
+		// Archives may wrap the case in a folder, so match on the end of
+		// the path.
+		path := "/" + strings.TrimPrefix(filepath.ToSlash(filepath.Clean(hdr.Name)), "./")
 
 		for required := range requiredFiles {
-			if strings.HasSuffix(path, required) {
+			if strings.HasSuffix(path, "/"+required) {
 				requiredFiles[required] = true
 			}
 		}
 
-		if strings.Contains(path, "constant/polyMesh") {
-			hasPolyMesh = true
+		if strings.Contains(path, "/constant/polyMesh/") || strings.HasSuffix(path, "/system/blockMeshDict") {
+			hasMesh = true
 		}
 	}
 
@@ -88,8 +91,8 @@ func validateOpenFOAMArchive(file multipart.File, header *multipart.FileHeader)
 		return fmt.Errorf("missing required OpenFOAM files: %v", missing)
 	}
 
-	if !hasPolyMesh {
-		return fmt.Errorf("missing constant/polyMesh directory")
+	if !hasMesh {
+		return fmt.Errorf("missing constant/polyMesh or system/blockMeshDict")
 	}
 
 	return nil
@@ -104,26 +107,6 @@ func validateCalculiXInput(file multipart.File, header *multipart.FileHeader) er
 		return fmt.Errorf("input file too large: %d bytes (max 50MB)", header.Size)
 	}
 
-	buf := make([]byte, 1024)
-	n, err := file.Read(buf)
-	if err != nil && err != io.EOF {
-		return fmt.Errorf("failed to read file: %w", err)
-	}
-
-	content := string(buf[:n])
-
-	requiredKeywords := []string{"*NODE", "*ELEMENT"}
-	var found []string
-
-	for _, kw := range requiredKeywords {
-		if strings.Contains(strings.ToUpper(content), kw) {
-			found = append(found, kw)
-		}
-	}
-
-	if len(found) < len(requiredKeywords) {
-		return fmt.Errorf("invalid CalculiX .inp file: missing keywords %v", requiredKeywords)
-	}
-
+	// The backend parses the whole deck, includes and all, once it is saved.
 	return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,95 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/theweirdfulmurk/cfd-platform/internal/usecase"
+)
+
+// sseHeartbeat keeps idle connections from being closed by proxies.
+const sseHeartbeat = 15 * time.Second
+
+// Events streams status changes of all simulations as Server-Sent Events.
+func (h *SimulationHandler) Events(w http.ResponseWriter, r *http.Request) {
+	h.streamEvents(w, r, "")
+}
+
+// SimulationEvents streams status changes of a single simulation.
+func (h *SimulationHandler) SimulationEvents(w http.ResponseWriter, r *http.Request) {
+	simID := chi.URLParam(r, "simId")
+
+	if _, err := h.useCase.GetByID(simID); err != nil {
+		respondError(w, http.StatusNotFound, "simulation not found")
+		return
+	}
+
+	h.streamEvents(w, r, simID)
+}
+
+func (h *SimulationHandler) streamEvents(w http.ResponseWriter, r *http.Request, simID string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		// EventSource can't set headers on the first connect
+		lastEventID = r.URL.Query().Get("lastEventId")
+	}
+
+	replay, events, unsubscribe := h.useCase.Subscribe(simID, lastEventID)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprint(w, "retry: 3000\n\n")
+	for _, event := range replay {
+		if err := writeEvent(w, event); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				// Fell behind; the client reconnects with Last-Event-ID.
+				return
+			}
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event usecase.SimulationEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: status\ndata: %s\n\n", event.ID, data)
+	return err
+}
@@ -1,15 +1,20 @@
 package http
 
 import (
-	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"os"
-	"path/filepath"
+	"strconv"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/theweirdfulmurk/cfd-platform/internal/archive"
 	"github.com/theweirdfulmurk/cfd-platform/internal/domain"
+	"github.com/theweirdfulmurk/cfd-platform/internal/repository"
 	"github.com/theweirdfulmurk/cfd-platform/internal/usecase"
 )
 
@@ -42,6 +47,47 @@ func (h *SimulationHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	divergence, err := parseDivergenceRules(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	subdomains, err := parseCount(r, "subdomains")
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	nodes, err := parseCount(r, "nodes")
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	allrun := false
+	if v := r.FormValue("allrun"); v != "" {
+		if allrun, err = strconv.ParseBool(v); err != nil {
+			respondError(w, http.StatusBadRequest, "allrun must be true or false")
+			return
+		}
+	}
+
+	params := usecase.SimulationParams{
+		Name:       name,
+		Type:       simType,
+		Divergence: divergence,
+		Resources: domain.ResourceRequirements{
+			CPU:              r.FormValue("cpu"),
+			Memory:           r.FormValue("memory"),
+			EphemeralStorage: r.FormValue("ephemeralStorage"),
+		},
+		Subdomains: subdomains,
+		Nodes:      nodes,
+		Solver:     r.FormValue("solver"),
+		Allrun:     allrun,
+		// One field per function object; their arguments may contain commas.
+		PostProcess: r.MultipartForm.Value["postProcess"],
+	}
+
 	// Get uploaded file
 	file, header, err := r.FormFile("file")
 	if err != nil {
@@ -62,7 +108,13 @@ func (h *SimulationHandler) Create(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create simulation with uploaded file
-	sim, err := h.useCase.CreateWithFile(name, simType, file, header.Filename)
+	sim, err := h.useCase.CreateWithFile(params, file, header.Filename)
+	if errors.Is(err, domain.ErrInvalidResources) || errors.Is(err, domain.ErrUnknownSolver) ||
+		errors.Is(err, domain.ErrUnsupportedInput) || errors.Is(err, archive.ErrUnsafeArchive) ||
+		errors.Is(err, domain.ErrInvalidCase) || errors.Is(err, domain.ErrInvalidRunOptions) {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -104,59 +156,346 @@ func (h *SimulationHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// DownloadResults streams the results of a simulation as an archive.
+// Query parameters: format=zip|tar.gz|tar.zst (default zip), include and
+// exclude globs (repeatable), latest=true for only the latest time
+// directory, or from and to for a range of times. The SHA-256 of the whole
+// archive follows in the X-Archive-SHA256 trailer; if streaming fails, the
+// connection is cut so the archive stays visibly incomplete.
 func (h *SimulationHandler) DownloadResults(w http.ResponseWriter, r *http.Request) {
 	simID := chi.URLParam(r, "simId")
+	query := r.URL.Query()
 
-	resultsPath := fmt.Sprintf("/results/%s", simID)
+	format, err := archive.ParseFormat(query.Get("format"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	sel := usecase.ResultsSelection{Include: query["include"], Exclude: query["exclude"]}
+	if v := query.Get("latest"); v != "" {
+		if sel.Latest, err = strconv.ParseBool(v); err != nil {
+			respondError(w, http.StatusBadRequest, "latest must be true or false")
+			return
+		}
+	}
+	if sel.From, err = parseTime(query.Get("from"), "from"); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if sel.To, err = parseTime(query.Get("to"), "to"); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	if _, err := os.Stat(resultsPath); os.IsNotExist(err) {
+	results, err := h.useCase.PrepareResultsArchive(simID, format, sel)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		respondError(w, http.StatusNotFound, "simulation not found")
+		return
+	case errors.Is(err, usecase.ErrInvalidSelection):
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, os.ErrNotExist):
 		respondError(w, http.StatusNotFound, "results not found")
 		return
+	case err != nil:
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
 	}
 
-	resultsPath = fmt.Sprintf("/results/%s", simID)
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", results.Name))
+
+	if cached, sum, ok := results.OpenCached(); ok {
+		defer cached.Close()
+		if info, err := cached.Stat(); err == nil {
+			w.Header().Set("X-Archive-SHA256", sum)
+			http.ServeContent(w, r, results.Name, info.ModTime(), cached)
+			return
+		}
+	}
+
+	w.Header().Set("Trailer", "X-Archive-SHA256")
+	sum, err := results.Stream(w)
+	if err != nil {
+		log.Printf("results archive of %s failed: %v", simID, err)
+		panic(http.ErrAbortHandler)
+	}
+	w.Header().Set("X-Archive-SHA256", sum)
+}
 
-	if _, err := os.Stat(resultsPath); os.IsNotExist(err) {
+// parseTime reads an optional simulation time.
+func parseTime(v, field string) (*float64, error) {
+	if v == "" {
+		return nil, nil
+	}
+	t, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a time", field)
+	}
+	return &t, nil
+}
+
+// ResultFiles lists the files in a simulation's results directory.
+func (h *SimulationHandler) ResultFiles(w http.ResponseWriter, r *http.Request) {
+	simID := chi.URLParam(r, "simId")
+
+	files, err := h.useCase.ListResultFiles(simID)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		respondError(w, http.StatusNotFound, "simulation not found")
+		return
+	case errors.Is(err, os.ErrNotExist):
 		respondError(w, http.StatusNotFound, "results not found")
 		return
+	case err != nil:
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/zip")
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=results-%s.zip", simID))
+	respondJSON(w, http.StatusOK, files)
+}
 
-	zipWriter := zip.NewWriter(w)
-	defer zipWriter.Close()
+// ResultFile downloads one result file. Range requests are supported, so
+// large fields can be fetched in pieces or resumed.
+func (h *SimulationHandler) ResultFile(w http.ResponseWriter, r *http.Request) {
+	simID := chi.URLParam(r, "simId")
 
-	var err error
-	err = filepath.Walk(resultsPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if info.IsDir() {
-			return nil
+	file, info, err := h.useCase.OpenResultFile(simID, chi.URLParam(r, "*"))
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		respondError(w, http.StatusNotFound, "simulation not found")
+		return
+	case errors.Is(err, usecase.ErrInvalidPath):
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, os.ErrNotExist):
+		respondError(w, http.StatusNotFound, "file not found")
+		return
+	case err != nil:
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", info.Name()))
+	http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+}
+
+// maxTailLines bounds tail=N; archived logs are tailed in memory.
+const maxTailLines = 100000
+
+// Logs streams the solver output of a simulation as chunked plain text.
+// Query parameters: follow=true keeps the stream open while the solver runs,
+// tail=N limits output to the last N lines.
+func (h *SimulationHandler) Logs(w http.ResponseWriter, r *http.Request) {
+	simID := chi.URLParam(r, "simId")
+	follow := r.URL.Query().Get("follow") == "true"
+
+	var tailLines int64
+	if tail := r.URL.Query().Get("tail"); tail != "" {
+		n, err := strconv.ParseInt(tail, 10, 64)
+		if err != nil || n < 0 || n > maxTailLines {
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("tail must be an integer from 0 to %d", maxTailLines))
+			return
 		}
+		tailLines = n
+	}
+
+	if _, err := h.useCase.GetByID(simID); err != nil {
+		respondError(w, http.StatusNotFound, "simulation not found")
+		return
+	}
 
-		relPath, err := filepath.Rel(resultsPath, path)
+	logs, err := h.useCase.StreamLogs(r.Context(), simID, follow, tailLines)
+	if err != nil {
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	defer logs.Close()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := logs.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
 		if err != nil {
-			return err
+			return
 		}
+	}
+}
 
-		zipFile, err := zipWriter.Create(relPath)
-		if err != nil {
-			return err
+// Residuals returns the residual history of a CFD simulation as JSON, or as
+// CSV with format=csv.
+func (h *SimulationHandler) Residuals(w http.ResponseWriter, r *http.Request) {
+	simID := chi.URLParam(r, "simId")
+
+	residuals, err := h.useCase.GetResiduals(simID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	if r.URL.Query().Get("format") == "csv" {
+		var buf bytes.Buffer
+		if err := residuals.WriteCSV(&buf); err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
 		}
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=residuals-%s.csv", simID))
+		buf.WriteTo(w)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, residuals)
+}
+
+// Stages returns the exit code of every stage the solver container ran.
+func (h *SimulationHandler) Stages(w http.ResponseWriter, r *http.Request) {
+	simID := chi.URLParam(r, "simId")
+
+	stages, err := h.useCase.GetStages(simID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
 
-		file, err := os.Open(path)
+	respondJSON(w, http.StatusOK, stages)
+}
+
+func (h *SimulationHandler) MeshReport(w http.ResponseWriter, r *http.Request) {
+	simID := chi.URLParam(r, "simId")
+
+	report, err := h.useCase.GetMeshReport(simID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, report)
+}
+
+// parseDivergenceRules reads the optional maxResidual, maxCourant and
+// stopOnNaN form fields on top of the platform defaults.
+func parseDivergenceRules(r *http.Request) (domain.DivergenceRules, error) {
+	rules := domain.DefaultDivergenceRules()
+
+	if v := r.FormValue("maxResidual"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 {
+			return rules, fmt.Errorf("maxResidual must be a non-negative number")
+		}
+		rules.MaxResidual = f
+	}
+	if v := r.FormValue("maxCourant"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 {
+			return rules, fmt.Errorf("maxCourant must be a non-negative number")
+		}
+		rules.MaxCourant = f
+	}
+	if v := r.FormValue("stopOnNaN"); v != "" {
+		b, err := strconv.ParseBool(v)
 		if err != nil {
-			return err
+			return rules, fmt.Errorf("stopOnNaN must be true or false")
 		}
-		defer file.Close()
+		rules.StopOnNaN = b
+	}
 
-		_, err = io.Copy(zipFile, file)
-		return err
-	})
+	return rules, nil
+}
 
-	if err != nil {
-		respondError(w, http.StatusInternalServerError, "failed to create archive")
+func (h *SimulationHandler) Solvers(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, h.useCase.Solvers())
+}
+
+// parseCount reads an optional non-negative integer form field.
+func parseCount(r *http.Request, field string) (int, error) {
+	v := r.FormValue(field)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer", field)
 	}
+	return n, nil
+}
+
+func (h *SimulationHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	h.control(w, r, h.useCase.Cancel)
+}
+
+func (h *SimulationHandler) Suspend(w http.ResponseWriter, r *http.Request) {
+	h.control(w, r, h.useCase.Suspend)
+}
+
+func (h *SimulationHandler) Resume(w http.ResponseWriter, r *http.Request) {
+	h.control(w, r, h.useCase.Resume)
+}
+
+func (h *SimulationHandler) control(w http.ResponseWriter, r *http.Request, action func(string) (*domain.Simulation, error)) {
+	simID := chi.URLParam(r, "simId")
+
+	sim, err := action(simID)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		respondError(w, http.StatusNotFound, "simulation not found")
+		return
+	case errors.Is(err, usecase.ErrInvalidState):
+		respondError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, sim)
+}
+
+type restartSimulationRequest struct {
+	Name    string   `json:"name"`
+	EndTime *float64 `json:"endTime"`
+}
+
+// Restart creates a child simulation continuing from the latest time step.
+// The JSON body is optional.
+func (h *SimulationHandler) Restart(w http.ResponseWriter, r *http.Request) {
+	simID := chi.URLParam(r, "simId")
+
+	var req restartSimulationRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			respondError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+	}
+
+	sim, err := h.useCase.Restart(simID, req.Name, req.EndTime)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		respondError(w, http.StatusNotFound, "simulation not found")
+		return
+	case errors.Is(err, usecase.ErrInvalidState):
+		respondError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, sim)
 }
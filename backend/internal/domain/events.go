@@ -0,0 +1,21 @@
+package domain
+
+import "context"
+
+// EventLogEntry is one entry of an EventLog. Seq grows with every entry.
+type EventLogEntry struct {
+	Seq     int64
+	Payload []byte
+}
+
+// EventLog carries events between the replicas of the backend: whatever
+// one replica appends, every replica's followers receive, in the same
+// order. Only the latest entries are kept.
+type EventLog interface {
+	Append(payload []byte) error
+	// Read returns the kept entries after seq, oldest first.
+	Read(after int64) ([]EventLogEntry, error)
+	// Follow calls deliver with every entry after seq as it is appended,
+	// until ctx is done or the log fails.
+	Follow(ctx context.Context, after int64, deliver func(EventLogEntry)) error
+}
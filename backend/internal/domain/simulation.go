@@ -1,19 +1,89 @@
 package domain
 
-import "time"
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
 
 // Simulation represents a CFD/FEA computation task
 type Simulation struct {
-	ID          string
-	Name        string
-	Type        SimulationType
-	Status      SimulationStatus
-	PodName     string
-	ResultPath  string
-	ConfigPath  string
-	CreatedAt   time.Time
-	StartedAt   *time.Time
-	CompletedAt *time.Time
+	ID           string
+	Name         string
+	Type         SimulationType
+	Status       SimulationStatus
+	StatusReason string // e.g. why the run failed
+	PodName      string
+	ResultPath   string
+	ConfigPath   string
+	CreatedAt    time.Time
+	StartedAt    *time.Time
+	CompletedAt  *time.Time
+	Divergence   DivergenceRules
+	ParentID     string // set on runs restarted from another simulation's case
+	Resources    ResourceRequirements
+	Subdomains   int // MPI ranks for parallel CFD runs; 0 or 1 runs serially
+	Nodes        int    // pods of a multi-node MPI run; 0 or 1 runs in one pod
+	Solver       string // catalog reference, e.g. "openfoam:v2312"
+	Run          RunPlan
+}
+
+// ResourceRequirements are Kubernetes quantities such as "500m" or "4Gi".
+// They are applied to the solver container as both requests and limits;
+// empty values are left unset.
+type ResourceRequirements struct {
+	CPU              string
+	Memory           string
+	EphemeralStorage string
+}
+
+// RunPlan records how the Job runs a case, so a run can be reproduced.
+// Stages are filled in when the Job is created.
+type RunPlan struct {
+	Application string   // solver from system/controlDict, e.g. "simpleFoam"
+	Allrun      bool     // run the case's own Allrun script instead
+	PostProcess []string // function objects run on the latest time afterwards
+	Stages      []RunStage
+}
+
+// RunStage is one step of the run, as passed to the runner.
+type RunStage struct {
+	Name    string
+	Builtin string
+	Args    []string
+	If      string
+	Unless  string
+	Always  bool
+}
+
+// ErrInvalidResources is returned when requested resources violate the
+// admin-configured policy.
+var ErrInvalidResources = errors.New("invalid resource request")
+
+// ErrInvalidCase is returned when an uploaded case or input deck fails
+// validation.
+var ErrInvalidCase = errors.New("invalid case")
+
+// ErrInvalidRunOptions is returned when the requested way of running a
+// simulation doesn't fit its type or size.
+var ErrInvalidRunOptions = errors.New("invalid run options")
+
+// ResourcePolicy fills in per-type defaults and enforces admin limits.
+type ResourcePolicy interface {
+	Resolve(simType SimulationType, requested ResourceRequirements) (ResourceRequirements, error)
+}
+
+// DivergenceRules stop a CFD run early once it is clearly blowing up.
+// Zero thresholds disable the corresponding check.
+type DivergenceRules struct {
+	MaxResidual float64 // initial residual of any field
+	MaxCourant  float64
+	StopOnNaN   bool
+}
+
+func DefaultDivergenceRules() DivergenceRules {
+	return DivergenceRules{StopOnNaN: true}
 }
 
 // SimulationType defines the simulation solver type
@@ -32,6 +102,10 @@ const (
 	SimStatusRunning   SimulationStatus = "running"
 	SimStatusCompleted SimulationStatus = "completed"
 	SimStatusFailed    SimulationStatus = "failed"
+	SimStatusLost      SimulationStatus = "lost"     // Job disappeared before finishing
+	SimStatusDiverged  SimulationStatus = "diverged" // stopped by a divergence rule
+	SimStatusCancelled SimulationStatus = "cancelled"
+	SimStatusSuspended SimulationStatus = "suspended"
 )
 
 // SimulationRepository defines the interface for simulation data access
@@ -45,7 +119,15 @@ type SimulationRepository interface {
 
 // SimulationK8sManager defines the interface for Kubernetes operations
 type SimulationK8sManager interface {
-	CreateJob(simID string, simType SimulationType, configPath string) error
+	// CreateJob starts the simulation's Job and records the stages it runs
+	// in sim.Run.
+	CreateJob(sim *Simulation) error
 	GetJobStatus(simID string) (SimulationStatus, error)
+	ListJobs() ([]*Simulation, error)
 	DeleteJob(simID string) error
-}
\ No newline at end of file
+	SuspendJob(simID string) error
+	ResumeJob(simID string) error
+	// StreamLogs returns the solver container output of the Job's pod.
+	// tailLines <= 0 returns the whole log.
+	StreamLogs(ctx context.Context, simID string, follow bool, tailLines int64) (io.ReadCloser, error)
+}
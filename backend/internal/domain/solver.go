@@ -0,0 +1,47 @@
+package domain
+
+import "errors"
+
+// Solver is a solver version packaged as a container image, e.g.
+// openfoam:v2312. The catalog is admin configuration.
+type Solver struct {
+	Name    string
+	Version string
+	Type    SimulationType
+	Image   string
+	// Command is a text/template for the container's bash script. {{.Run}}
+	// expands to the platform's run steps, so a template typically sources
+	// the solver environment first. Empty means just {{.Run}}.
+	Command      string
+	Resources    ResourceRequirements // defaults below the user's request
+	InputFormats []string             // accepted upload suffixes, e.g. ".tar.gz"
+	// Outputs are glob patterns, relative to the simulation directory, of
+	// what is staged into the results after a run. Empty uses the platform
+	// defaults for the type.
+	Outputs []string
+	// MPI marks CalculiX builds with an MPI solver (PaStiX or MUMPS), which
+	// can run across several pods. OpenFOAM images always can.
+	MPI bool
+}
+
+// Ref is how users and stored simulations refer to the solver.
+func (s Solver) Ref() string {
+	return s.Name + ":" + s.Version
+}
+
+var (
+	// ErrUnknownSolver is returned for a solver reference that isn't in the
+	// catalog or doesn't match the simulation type.
+	ErrUnknownSolver = errors.New("unknown solver")
+	// ErrUnsupportedInput is returned when the solver can't read the upload.
+	ErrUnsupportedInput = errors.New("unsupported input format")
+)
+
+// SolverCatalog lists the configured solvers.
+type SolverCatalog interface {
+	// Resolve finds a solver by "name:version" or by bare name, which
+	// selects that solver's default version. An empty ref selects the
+	// default solver for simType.
+	Resolve(ref string, simType SimulationType) (*Solver, error)
+	List() []Solver
+}
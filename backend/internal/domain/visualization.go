@@ -22,6 +22,7 @@ const (
 	VizStatusRunning VisualizationStatus = "running"
 	VizStatusReady   VisualizationStatus = "ready"
 	VizStatusFailed  VisualizationStatus = "failed"
+	VizStatusLost    VisualizationStatus = "lost" // Pod disappeared while in use
 )
 
 // VisualizationRepository defines the interface for visualization data access
@@ -35,8 +36,9 @@ type VisualizationRepository interface {
 
 // VisualizationK8sManager defines the interface for Kubernetes operations
 type VisualizationK8sManager interface {
-	CreatePod(vizID, resultPath string) error
+	CreatePod(viz *Visualization) error
 	GetPodStatus(vizID string) (VisualizationStatus, error)
+	ListPods() ([]*Visualization, error)
 	GetPodIP(vizID string) (string, error)
 	DeletePod(vizID string) error
 }
\ No newline at end of file
@@ -0,0 +1,285 @@
+package k8s
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/theweirdfulmurk/cfd-platform/internal/domain"
+	"github.com/theweirdfulmurk/cfd-platform/internal/runner"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// The runner binary is copied from the backend image into every solver
+	// pod by an init container.
+	runnerDir    = "/opt/cfd-runner"
+	runnerBinary = runnerDir + "/cfd-runner"
+
+	// stagingGracePeriod is how long, in seconds, a stopped solver pod has
+	// to stage its results before it is killed.
+	stagingGracePeriod = 300
+)
+
+// executionStrategy decides how the solver container runs a simulation.
+type executionStrategy interface {
+	stages(sim *domain.Simulation) []runner.Stage
+	env(sim *domain.Simulation) []corev1.EnvVar
+}
+
+// commandData is available to solver command templates.
+type commandData struct {
+	Run   string // starts the runner
+	Ranks int    // MPI ranks, 1 for serial runs
+}
+
+// defaultOutputs are staged into the results when the solver's catalog
+// entry names none: for OpenFOAM a case ParaView can open, for CalculiX the
+// result, printout, status and convergence files.
+var defaultOutputs = map[domain.SimulationType][]string{
+	domain.SimTypeCFD: {runner.TimeDirs, "constant/polyMesh", "system/controlDict", "postProcessing", "log.*"},
+	domain.SimTypeFEA: {"*.frd", "*.dat", "*.sta", "*.cvg"},
+}
+
+// runSpec describes the run for the runner. Only the runner interprets the
+// simulation's paths; they never pass through a shell. Every run ends by
+// staging the solver's outputs into the results directory, including runs
+// that failed or were stopped, so their logs and partial results are kept.
+func runSpec(sim *domain.Simulation, solver *domain.Solver, strategy executionStrategy) (*runner.Spec, error) {
+	outputs := solver.Outputs
+	if len(outputs) == 0 {
+		outputs = defaultOutputs[sim.Type]
+	}
+	spec := &runner.Spec{
+		Root:    path.Join(runner.SimulationsDir, sim.ConfigPath),
+		Results: path.Join(runner.ResultsDir, sim.ID),
+		Stages: append(strategy.stages(sim),
+			runner.Stage{Name: "stageResults", Builtin: runner.BuiltinStageResults, Args: outputs, Always: true}),
+	}
+	if sim.Nodes > 1 {
+		spec.MPIDir = path.Join(spec.Root, ".mpi-"+sim.ID)
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// runStages converts the stages of a spec into the form kept on the
+// simulation.
+func runStages(stages []runner.Stage) []domain.RunStage {
+	recorded := make([]domain.RunStage, len(stages))
+	for i, stage := range stages {
+		recorded[i] = domain.RunStage{
+			Name:    stage.Name,
+			Builtin: stage.Builtin,
+			Args:    stage.Args,
+			If:      stage.If,
+			Unless:  stage.Unless,
+			Always:  stage.Always,
+		}
+	}
+	return recorded
+}
+
+// solverCommand renders the solver's command template around the runner,
+// e.g. to source the solver's environment first. Templates are admin
+// configuration and see no user input.
+func solverCommand(solver *domain.Solver, sim *domain.Simulation) ([]string, error) {
+	tmpl := solver.Command
+	if tmpl == "" {
+		tmpl = "{{.Run}}"
+	}
+	t, err := template.New(solver.Ref()).Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid command template of %s: %w", solver.Ref(), err)
+	}
+
+	ranks := max(sim.Subdomains, 1)
+	if sim.Type == domain.SimTypeFEA {
+		ranks = max(sim.Nodes, 1)
+	}
+
+	var script strings.Builder
+	err = t.Execute(&script, commandData{
+		Run:   "exec " + runnerBinary,
+		Ranks: ranks,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to render command of %s: %w", solver.Ref(), err)
+	}
+	return []string{"/bin/bash", "-c", script.String()}, nil
+}
+
+// executionStrategyFor picks the strategy from the simulation type, the
+// number of requested subdomains and the number of pods.
+func executionStrategyFor(sim *domain.Simulation) (executionStrategy, error) {
+	switch sim.Type {
+	case domain.SimTypeCFD:
+		if sim.Nodes > 1 {
+			return multiNodeMPI{
+				ranks:   sim.Subdomains,
+				prepare: openfoamDecomposeStages(sim),
+				program: []string{application(sim), "-parallel"},
+				finish: append([]runner.Stage{{Name: "reconstruct", Args: []string{"reconstructPar"}}},
+					postProcessStages(sim)...),
+			}, nil
+		}
+		if sim.Subdomains > 1 {
+			return parallelOpenFOAM{}, nil
+		}
+		return serialOpenFOAM{}, nil
+	case domain.SimTypeFEA:
+		if sim.Nodes > 1 {
+			// Only for catalog entries marked mpi; each rank still uses the
+			// pod's cores for the threaded parts.
+			return multiNodeMPI{
+				ranks:      sim.Nodes,
+				program:    []string{"ccx", "-i", "input"},
+				threadsEnv: calculixThreads(sim),
+			}, nil
+		}
+		return calculix{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported simulation type: %s", sim.Type)
+	}
+}
+
+// serialOpenFOAM meshes the case if needed, runs the solver named in
+// controlDict in a single process and then the requested function objects.
+// Cases that opt in run their own Allrun script instead. The backend has
+// already unpacked the case into the simulation directory.
+type serialOpenFOAM struct{}
+
+func (serialOpenFOAM) stages(sim *domain.Simulation) []runner.Stage {
+	solve := runner.Stage{Name: "solve", Args: []string{application(sim)}}
+	if sim.ParentID != "" {
+		// Restarts continue the parent's case in place: no meshing.
+		return append([]runner.Stage{solve}, postProcessStages(sim)...)
+	}
+	if sim.Run.Allrun {
+		return []runner.Stage{{Name: "allrun", Args: []string{"./Allrun"}}}
+	}
+	stages := append(openfoamPrepareStages(), solve)
+	return append(stages, postProcessStages(sim)...)
+}
+
+func (serialOpenFOAM) env(*domain.Simulation) []corev1.EnvVar { return nil }
+
+// parallelOpenFOAM decomposes the case into sim.Subdomains pieces, runs the
+// solver under MPI and reconstructs the fields afterwards.
+type parallelOpenFOAM struct{}
+
+func (parallelOpenFOAM) stages(sim *domain.Simulation) []runner.Stage {
+	stages := append(openfoamDecomposeStages(sim),
+		runner.Stage{Name: "solve", Args: []string{
+			"mpirun", "--allow-run-as-root", "--oversubscribe", "-np", strconv.Itoa(sim.Subdomains),
+			application(sim), "-parallel",
+		}},
+		runner.Stage{Name: "reconstruct", Args: []string{"reconstructPar"}},
+	)
+	return append(stages, postProcessStages(sim)...)
+}
+
+func (parallelOpenFOAM) env(*domain.Simulation) []corev1.EnvVar { return nil }
+
+// application is the solver read from controlDict at upload. Simulations
+// created without an upload leave it to the runner to look up.
+func application(sim *domain.Simulation) string {
+	if sim.Run.Application != "" {
+		return sim.Run.Application
+	}
+	return runner.Application
+}
+
+// postProcessStages evaluate the requested function objects on the latest
+// time. "<solver> -postProcess" loads the solver's own models, which
+// functions such as wallShearStress need.
+func postProcessStages(sim *domain.Simulation) []runner.Stage {
+	stages := make([]runner.Stage, 0, len(sim.Run.PostProcess))
+	for _, fn := range sim.Run.PostProcess {
+		stages = append(stages, runner.Stage{
+			Name: "postProcess " + fn,
+			Args: []string{application(sim), "-postProcess", "-func", fn, "-latestTime"},
+		})
+	}
+	return stages
+}
+
+// uploadedMesh matches a mesh that came with the case.
+const uploadedMesh = "constant/polyMesh/faces*"
+
+// openfoamPrepareStages get an uploaded case ready for its first run. Cases
+// that keep their initial fields only in 0.orig, as tutorials do, get them
+// copied to 0 the way their Allrun scripts would with restore0Dir; then the
+// mesh is built if needed.
+func openfoamPrepareStages() []runner.Stage {
+	restore := runner.Stage{Name: "restore0Dir", Builtin: runner.BuiltinCopyDir,
+		Args: []string{"0.orig", "0"}, If: "0.orig", Unless: "0"}
+	return append([]runner.Stage{restore}, openfoamMeshStages()...)
+}
+
+// openfoamMeshStages build the mesh of cases that don't ship one: blockMesh
+// for the background mesh and, for cases with a snappyHexMeshDict, feature
+// extraction and snappyHexMesh with the STL geometry in
+// constant/triSurface. A generated mesh is checked and reported on.
+func openfoamMeshStages() []runner.Stage {
+	return []runner.Stage{
+		{Name: "blockMesh", Args: []string{"blockMesh"}, Unless: uploadedMesh},
+		// surfaceFeatureExtract became surfaceFeatures in OpenFOAM 7; each
+		// reads its own dictionary.
+		{Name: "surfaceFeatureExtract", Args: []string{"surfaceFeatureExtract"},
+			If: "system/surfaceFeatureExtractDict", Unless: uploadedMesh},
+		{Name: "surfaceFeatures", Args: []string{"surfaceFeatures"},
+			If: "system/surfaceFeaturesDict", Unless: uploadedMesh},
+		{Name: "snappyHexMesh", Args: []string{"snappyHexMesh", "-overwrite"},
+			If: "system/snappyHexMeshDict", Unless: uploadedMesh},
+		{Name: "checkMesh", Args: []string{"checkMesh"}, Unless: uploadedMesh},
+		{Name: "meshReport", Builtin: runner.BuiltinMeshReport, Unless: uploadedMesh},
+	}
+}
+
+// openfoamDecomposeStages prepare a new case and split it into
+// sim.Subdomains pieces.
+func openfoamDecomposeStages(sim *domain.Simulation) []runner.Stage {
+	var stages []runner.Stage
+	if sim.ParentID == "" {
+		stages = append(stages, openfoamPrepareStages()...)
+	}
+	stages = append(stages, runner.Stage{
+		Name:    "decomposeParDict",
+		Builtin: runner.BuiltinDecomposeDict,
+		Args:    []string{strconv.Itoa(sim.Subdomains)},
+	})
+	if sim.ParentID != "" {
+		// Only the latest time step is needed to continue a run.
+		return append(stages, runner.Stage{Name: "decompose", Args: []string{"decomposePar", "-force", "-latestTime"}})
+	}
+	return append(stages, runner.Stage{Name: "decompose", Args: []string{"decomposePar", "-force"}})
+}
+
+// calculix runs ccx on the uploaded input deck. The SPOOLES solver and the
+// element loops are threaded across the pod's cores.
+type calculix struct{}
+
+func (calculix) stages(*domain.Simulation) []runner.Stage {
+	return []runner.Stage{{Name: "solve", Args: []string{"ccx", "-i", "input"}}}
+}
+
+func (calculix) env(sim *domain.Simulation) []corev1.EnvVar { return calculixThreads(sim) }
+
+// calculixThreads sizes the ccx thread pools to the pod's CPU limit.
+func calculixThreads(sim *domain.Simulation) []corev1.EnvVar {
+	q, err := resource.ParseQuantity(sim.Resources.CPU)
+	if err != nil {
+		return nil
+	}
+	threads := strconv.FormatInt(q.Value(), 10) // rounds fractional cores up
+	return []corev1.EnvVar{
+		{Name: "OMP_NUM_THREADS", Value: threads},
+		{Name: "CCX_NPROC_EQUATION_SOLVER", Value: threads},
+	}
+}
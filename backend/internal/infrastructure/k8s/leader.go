@@ -0,0 +1,41 @@
+package k8s
+
+import (
+	"context"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// leaseName is the Lease the backend replicas compete for.
+const leaseName = "cfd-platform-backend"
+
+// Lead campaigns for the backend's Lease under identity and calls lead once
+// this replica holds it. lead's context is cancelled when the Lease is
+// lost; Lead returns then, or when ctx is done. Replicas that don't lead
+// keep serving requests, so the work done while leading is what must
+// happen exactly once: applying Job status, following solver logs and
+// reconciling.
+func Lead(ctx context.Context, clientset *kubernetes.Clientset, namespace, identity string, lead func(ctx context.Context)) {
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta:  metav1.ObjectMeta{Name: leaseName, Namespace: namespace},
+		Client:     clientset.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: identity},
+	}
+
+	leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   15 * time.Second,
+		RenewDeadline:   10 * time.Second,
+		RetryPeriod:     2 * time.Second,
+		ReleaseOnCancel: true,
+		Name:            leaseName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: lead,
+			OnStoppedLeading: func() {},
+		},
+	})
+}
@@ -0,0 +1,39 @@
+package k8s
+
+import "time"
+
+// Labels select platform objects; annotations carry the metadata needed to
+// rebuild repository records from the cluster after a backend restart.
+const (
+	labelApp  = "app"
+	labelType = "type"
+	labelID   = "id"
+
+	appSimulation    = "simulation"
+	appVisualization = "paraview-viz"
+
+	annotationName         = "cfd-platform.io/name"
+	annotationConfigPath   = "cfd-platform.io/config-path"
+	annotationResultPath   = "cfd-platform.io/result-path"
+	annotationCreatedAt    = "cfd-platform.io/created-at"
+	annotationSimulationID = "cfd-platform.io/simulation-id"
+	annotationParentID     = "cfd-platform.io/parent-id"
+	annotationSubdomains   = "cfd-platform.io/subdomains"
+	annotationNodes        = "cfd-platform.io/nodes"
+	annotationSolver       = "cfd-platform.io/solver"
+	annotationRunPlan      = "cfd-platform.io/run-plan"
+	annotationDivergence   = "cfd-platform.io/divergence"
+)
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// parseTime returns fallback when the annotation is missing or malformed.
+func parseTime(value string, fallback time.Time) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return fallback
+	}
+	return t
+}
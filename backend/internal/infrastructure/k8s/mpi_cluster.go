@@ -0,0 +1,96 @@
+package k8s
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/theweirdfulmurk/cfd-platform/internal/domain"
+	"github.com/theweirdfulmurk/cfd-platform/internal/runner"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Multi-node runs are an Indexed Job with one pod per node. Index 0 is the
+// launcher: the runner writes a hostfile from the stable hostnames provided
+// by a headless Service and starts mpirun. There is no SSH in the solver
+// images and none is needed: mpirun's rsh agent is the runner on the
+// launcher, which hands the MPI daemons' commands to the runners of the
+// remaining indexes through the shared case directory until the launcher
+// is done. The pods get no Kubernetes credentials.
+//
+// All pods share the case directory, so the simulation volumes must support
+// ReadWriteMany when the pods land on different nodes.
+const (
+	rshAgent = runnerBinary + " " + runner.RshCommand
+
+	labelCompletionIndex = "batch.kubernetes.io/job-completion-index"
+)
+
+// multiNodeMPI runs prepare, the MPI program and finish on the launcher and
+// spreads ranks evenly across sim.Nodes pods. The runner keeps the other
+// pods waiting until the launcher is done.
+type multiNodeMPI struct {
+	ranks      int
+	prepare    []runner.Stage
+	program    []string
+	finish     []runner.Stage
+	threadsEnv []corev1.EnvVar
+}
+
+func (s multiNodeMPI) stages(sim *domain.Simulation) []runner.Stage {
+	job := fmt.Sprintf("sim-%s", sim.ID)
+	slots := (s.ranks + sim.Nodes - 1) / sim.Nodes
+
+	mpirun := []string{
+		"mpirun", "--allow-run-as-root", "--hostfile", "hostfile", "-np", strconv.Itoa(s.ranks),
+		"-wdir", runner.WorkDir, "-x", "PATH", "-x", "LD_LIBRARY_PATH",
+		"--mca", "plm_rsh_agent", rshAgent,
+	}
+
+	stages := append([]runner.Stage(nil), s.prepare...)
+	stages = append(stages,
+		runner.Stage{
+			Name:    "hostfile",
+			Builtin: runner.BuiltinHostfile,
+			Args:    []string{job, strconv.Itoa(sim.Nodes), strconv.Itoa(slots)},
+		},
+		runner.Stage{Name: "solve", Args: append(mpirun, s.program...)},
+	)
+	return append(stages, s.finish...)
+}
+
+func (s multiNodeMPI) env(*domain.Simulation) []corev1.EnvVar { return s.threadsEnv }
+
+// configureMultiNode turns a single-pod solver Job into an Indexed Job with
+// one pod per node. Workers exit successfully once the launcher is done, so
+// the Job completes exactly when the launcher rank succeeds; a failed rank
+// can't rejoin a running MPI job, hence no retries.
+func configureMultiNode(job *batchv1.Job, nodes int) {
+	mode := batchv1.IndexedCompletion
+	job.Spec.CompletionMode = &mode
+	job.Spec.Completions = int32Ptr(int32(nodes))
+	job.Spec.Parallelism = int32Ptr(int32(nodes))
+	job.Spec.BackoffLimit = int32Ptr(0)
+
+	job.Spec.Template.Spec.Subdomain = job.Name
+}
+
+// headlessService gives the pods of a multi-node Job stable DNS names. It is
+// owned by the Job, so deleting the Job removes it too.
+func headlessService(job *batchv1.Job) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   job.Name,
+			Labels: job.Labels,
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(job, batchv1.SchemeGroupVersion.WithKind("Job")),
+			},
+		},
+		Spec: corev1.ServiceSpec{
+			ClusterIP:                corev1.ClusterIPNone,
+			Selector:                 map[string]string{"job-name": job.Name},
+			PublishNotReadyAddresses: true,
+		},
+	}
+}
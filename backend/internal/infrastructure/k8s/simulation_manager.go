@@ -2,68 +2,128 @@ package k8s
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"strconv"
 
 	"github.com/theweirdfulmurk/cfd-platform/internal/domain"
+	"github.com/theweirdfulmurk/cfd-platform/internal/runner"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 )
 
 type SimulationManager struct {
-	clientset *kubernetes.Clientset
-	namespace string
+	clientset   *kubernetes.Clientset
+	namespace   string
+	solvers     domain.SolverCatalog
+	runnerImage string // provides the cfd-runner binary, normally the backend image
 }
 
-func NewSimulationManager(clientset *kubernetes.Clientset, namespace string) *SimulationManager {
+func NewSimulationManager(clientset *kubernetes.Clientset, namespace string, solvers domain.SolverCatalog, runnerImage string) *SimulationManager {
 	return &SimulationManager{
-		clientset: clientset,
-		namespace: namespace,
+		clientset:   clientset,
+		namespace:   namespace,
+		solvers:     solvers,
+		runnerImage: runnerImage,
 	}
 }
 
-func (m *SimulationManager) CreateJob(simID string, simType domain.SimulationType, configPath string) error {
-	var image string
-	var command []string
+func (m *SimulationManager) CreateJob(sim *domain.Simulation) error {
+	simID, simType := sim.ID, sim.Type
 
-	switch simType {
-	case domain.SimTypeCFD:
-		image = "openfoam/openfoam8-paraview56"
-		command = []string{"/bin/bash", "-c",
-			"cd /pvc/simulations/" + configPath + " && tar -xzf *.tar.gz && ./Allrun"}
-	case domain.SimTypeFEA:
-		image = "calculix/ccx:latest"
-		command = []string{"/bin/bash", "-c", 
-			"mkdir -p /results/" + configPath + " && cp /pvc/simulations/" + configPath + "/input.inp /tmp/ && cd /tmp && ccx input && cp *.frd *.dat /results/" + configPath + "/"}
-	default:
-		return fmt.Errorf("unsupported simulation type: %s", simType)
+	solver, err := m.solvers.Resolve(sim.Solver, simType)
+	if err != nil {
+		return err
+	}
+	strategy, err := executionStrategyFor(sim)
+	if err != nil {
+		return err
+	}
+	spec, err := runSpec(sim, solver, strategy)
+	if err != nil {
+		return err
+	}
+	sim.Run.Stages = runStages(spec.Stages)
+	runPlan, err := json.Marshal(sim.Run)
+	if err != nil {
+		return err
+	}
+	divergence, err := json.Marshal(sim.Divergence)
+	if err != nil {
+		return err
+	}
+	encodedSpec, err := spec.Encode()
+	if err != nil {
+		return err
+	}
+	command, err := solverCommand(solver, sim)
+	if err != nil {
+		return err
 	}
 
 	job := &batchv1.Job{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: fmt.Sprintf("sim-%s", simID),
 			Labels: map[string]string{
-				"app":  "simulation",
-				"type": string(simType),
-				"id":   simID,
+				labelApp:  appSimulation,
+				labelType: string(simType),
+				labelID:   simID,
+			},
+			Annotations: map[string]string{
+				annotationName:       sim.Name,
+				annotationConfigPath: sim.ConfigPath,
+				annotationResultPath: sim.ResultPath,
+				annotationCreatedAt:  formatTime(sim.CreatedAt),
+				annotationParentID:   sim.ParentID,
+				annotationSubdomains: strconv.Itoa(sim.Subdomains),
+				annotationNodes:      strconv.Itoa(sim.Nodes),
+				annotationSolver:     solver.Ref(),
+				annotationRunPlan:    string(runPlan),
+				annotationDivergence: string(divergence),
 			},
 		},
 		Spec: batchv1.JobSpec{
 			Template: corev1.PodTemplateSpec{
 				Spec: corev1.PodSpec{
 					RestartPolicy: corev1.RestartPolicyNever,
+					// Solver pods run uploaded cases and never talk to the
+					// API server.
+					AutomountServiceAccountToken: boolPtr(false),
+					// Stopped runs still stage their results before exiting.
+					TerminationGracePeriodSeconds: int64Ptr(stagingGracePeriod),
 					SecurityContext: &corev1.PodSecurityContext{
 						FSGroup: int64Ptr(1000),
 					},
+					InitContainers: []corev1.Container{
+						{
+							Name:            "runner",
+							Image:           m.runnerImage,
+							ImagePullPolicy: corev1.PullIfNotPresent,
+							Command:         []string{"cp", "/app/cfd-runner", runnerBinary},
+							VolumeMounts: []corev1.VolumeMount{
+								{Name: "runner", MountPath: runnerDir},
+							},
+						},
+					},
 					Containers: []corev1.Container{
 						{
 							Name:       "solver",
-							Image:      image,
+							Image:      solver.Image,
 							Command:    command,
-							WorkingDir: "/pvc/simulations/" + configPath,
+							Env:        append([]corev1.EnvVar{{Name: runner.SpecEnv, Value: encodedSpec}}, strategy.env(sim)...),
+							WorkingDir: spec.Root,
 							VolumeMounts: []corev1.VolumeMount{
+								{
+									Name:      "runner",
+									MountPath: runnerDir,
+									ReadOnly:  true,
+								},
 								{
 									Name:      "config",
 									MountPath: "/pvc",
@@ -74,19 +134,14 @@ func (m *SimulationManager) CreateJob(simID string, simType domain.SimulationTyp
 									MountPath: "/results",
 								},
 							},
-							Resources: corev1.ResourceRequirements{
-								Requests: corev1.ResourceList{
-									corev1.ResourceMemory: resource.MustParse("512Mi"), // было 4Gi
-									corev1.ResourceCPU:    resource.MustParse("500m"),  // было 2
-								},
-								Limits: corev1.ResourceList{
-									corev1.ResourceMemory: resource.MustParse("1Gi"), // было 8Gi
-									corev1.ResourceCPU:    resource.MustParse("1"),   // было 4
-								},
-							},
+							Resources: resourceRequirements(sim.Resources),
 						},
 					},
 					Volumes: []corev1.Volume{
+						{
+							Name:         "runner",
+							VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+						},
 						{
 							Name: "config",
 							VolumeSource: corev1.VolumeSource{
@@ -109,12 +164,29 @@ func (m *SimulationManager) CreateJob(simID string, simType domain.SimulationTyp
 		},
 	}
 
-	_, err := m.clientset.BatchV1().Jobs(m.namespace).Create(
+	if sim.Nodes > 1 {
+		configureMultiNode(job, sim.Nodes)
+	}
+
+	created, err := m.clientset.BatchV1().Jobs(m.namespace).Create(
 		context.Background(),
 		job,
 		metav1.CreateOptions{},
 	)
-	return err
+	if err != nil || sim.Nodes <= 1 {
+		return err
+	}
+
+	_, err = m.clientset.CoreV1().Services(m.namespace).Create(
+		context.Background(),
+		headlessService(created),
+		metav1.CreateOptions{},
+	)
+	if err != nil {
+		m.DeleteJob(simID)
+		return fmt.Errorf("failed to create service for multi-node job: %w", err)
+	}
+	return nil
 }
 
 func (m *SimulationManager) GetJobStatus(simID string) (domain.SimulationStatus, error) {
@@ -127,34 +199,222 @@ func (m *SimulationManager) GetJobStatus(simID string) (domain.SimulationStatus,
         return "", err
     }
 
-    // Проверь условия
-    for _, condition := range job.Status.Conditions {
-        if condition.Type == batchv1.JobComplete && condition.Status == corev1.ConditionTrue {
-            return domain.SimStatusCompleted, nil
-        }
-        if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue {
-            return domain.SimStatusFailed, nil
-        }
-    }
+    return jobStatus(job), nil
+}
 
-    if job.Status.Active > 0 {
-        return domain.SimStatusRunning, nil
-    }
+// ListJobs rebuilds simulations from every platform Job in the namespace
+// that isn't being deleted. Jobs created without metadata annotations come
+// back with an empty Name.
+func (m *SimulationManager) ListJobs() ([]*domain.Simulation, error) {
+	jobs, err := m.clientset.BatchV1().Jobs(m.namespace).List(
+		context.Background(),
+		metav1.ListOptions{LabelSelector: labelApp + "=" + appSimulation},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	sims := make([]*domain.Simulation, 0, len(jobs.Items))
+	for i := range jobs.Items {
+		if jobs.Items[i].DeletionTimestamp != nil {
+			continue // on its way out with its simulation
+		}
+		sims = append(sims, simulationFromJob(&jobs.Items[i]))
+	}
 
-    return domain.SimStatusPending, nil
+	return sims, nil
 }
 
+// DeleteJob removes the Job and its pods. A Job that is already gone is not
+// an error.
 func (m *SimulationManager) DeleteJob(simID string) error {
 	propagationPolicy := metav1.DeletePropagationBackground
-	return m.clientset.BatchV1().Jobs(m.namespace).Delete(
+	err := m.clientset.BatchV1().Jobs(m.namespace).Delete(
 		context.Background(),
 		fmt.Sprintf("sim-%s", simID),
 		metav1.DeleteOptions{
 			PropagationPolicy: &propagationPolicy,
 		},
 	)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// SuspendJob sets spec.suspend, which makes the Job controller terminate the
+// running pods while keeping the Job.
+func (m *SimulationManager) SuspendJob(simID string) error {
+	return m.setSuspend(simID, true)
+}
+
+func (m *SimulationManager) ResumeJob(simID string) error {
+	return m.setSuspend(simID, false)
+}
+
+func (m *SimulationManager) setSuspend(simID string, suspend bool) error {
+	patch := []byte(fmt.Sprintf(`{"spec":{"suspend":%t}}`, suspend))
+	_, err := m.clientset.BatchV1().Jobs(m.namespace).Patch(
+		context.Background(),
+		fmt.Sprintf("sim-%s", simID),
+		types.MergePatchType,
+		patch,
+		metav1.PatchOptions{},
+	)
+	return err
+}
+
+// simulationFromJob rebuilds a simulation from Job labels, annotations and
+// status. Start and completion times come from the Job itself rather than
+// from when the backend happened to notice the change.
+func simulationFromJob(job *batchv1.Job) *domain.Simulation {
+	annotations := job.Annotations
+
+	sim := &domain.Simulation{
+		ID:         job.Labels[labelID],
+		Name:       annotations[annotationName],
+		Type:       domain.SimulationType(job.Labels[labelType]),
+		Status:     jobStatus(job),
+		PodName:    job.Name,
+		ResultPath: annotations[annotationResultPath],
+		ConfigPath: annotations[annotationConfigPath],
+		CreatedAt:  parseTime(annotations[annotationCreatedAt], job.CreationTimestamp.Time),
+		ParentID:   annotations[annotationParentID],
+		Solver:     annotations[annotationSolver],
+	}
+	sim.Subdomains, _ = strconv.Atoi(annotations[annotationSubdomains])
+	sim.Nodes, _ = strconv.Atoi(annotations[annotationNodes])
+	if plan := annotations[annotationRunPlan]; plan != "" {
+		json.Unmarshal([]byte(plan), &sim.Run) // Jobs from older backends have none
+	}
+	sim.Divergence = domain.DefaultDivergenceRules()
+	if rules := annotations[annotationDivergence]; rules != "" {
+		json.Unmarshal([]byte(rules), &sim.Divergence)
+	}
+	for _, container := range job.Spec.Template.Spec.Containers {
+		if container.Name != "solver" {
+			continue
+		}
+		limits := container.Resources.Limits
+		if q, ok := limits[corev1.ResourceCPU]; ok {
+			sim.Resources.CPU = q.String()
+		}
+		if q, ok := limits[corev1.ResourceMemory]; ok {
+			sim.Resources.Memory = q.String()
+		}
+		if q, ok := limits[corev1.ResourceEphemeralStorage]; ok {
+			sim.Resources.EphemeralStorage = q.String()
+		}
+	}
+	if job.Status.StartTime != nil {
+		t := job.Status.StartTime.Time
+		sim.StartedAt = &t
+	}
+	if job.Status.CompletionTime != nil {
+		t := job.Status.CompletionTime.Time
+		sim.CompletedAt = &t
+	}
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue {
+			t := condition.LastTransitionTime.Time
+			sim.CompletedAt = &t
+			sim.StatusReason = condition.Reason
+			if condition.Message != "" {
+				sim.StatusReason = condition.Message
+			}
+		}
+	}
+
+	return sim
+}
+
+// resourceRequirements applies the resolved simulation resources as both
+// requests and limits, so the scheduler reserves what the solver may use.
+func resourceRequirements(r domain.ResourceRequirements) corev1.ResourceRequirements {
+	list := corev1.ResourceList{}
+	for name, value := range map[corev1.ResourceName]string{
+		corev1.ResourceCPU:              r.CPU,
+		corev1.ResourceMemory:           r.Memory,
+		corev1.ResourceEphemeralStorage: r.EphemeralStorage,
+	} {
+		if q, err := resource.ParseQuantity(value); err == nil {
+			list[name] = q
+		}
+	}
+
+	return corev1.ResourceRequirements{
+		Requests: list,
+		Limits:   list.DeepCopy(),
+	}
+}
+
+func jobStatus(job *batchv1.Job) domain.SimulationStatus {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobComplete && condition.Status == corev1.ConditionTrue {
+			return domain.SimStatusCompleted
+		}
+		if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue {
+			return domain.SimStatusFailed
+		}
+	}
+
+	if job.Spec.Suspend != nil && *job.Spec.Suspend {
+		return domain.SimStatusSuspended
+	}
+
+	if job.Status.Active > 0 {
+		return domain.SimStatusRunning
+	}
+
+	return domain.SimStatusPending
+}
+
+func (m *SimulationManager) StreamLogs(ctx context.Context, simID string, follow bool, tailLines int64) (io.ReadCloser, error) {
+	pods, err := m.clientset.CoreV1().Pods(m.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: "job-name=" + fmt.Sprintf("sim-%s", simID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(pods.Items) == 0 {
+		return nil, fmt.Errorf("no pod found for simulation %s", simID)
+	}
+
+	// A Job may have retried; the newest pod has the relevant output. In a
+	// multi-node run only the launcher (index 0) prints the solver output.
+	var pod *corev1.Pod
+	for i := range pods.Items {
+		p := &pods.Items[i]
+		if index, ok := p.Labels[labelCompletionIndex]; ok && index != "0" {
+			continue
+		}
+		if pod == nil || p.CreationTimestamp.After(pod.CreationTimestamp.Time) {
+			pod = p
+		}
+	}
+	if pod == nil {
+		return nil, fmt.Errorf("no pod found for simulation %s", simID)
+	}
+
+	opts := &corev1.PodLogOptions{
+		Container: "solver",
+		Follow:    follow,
+	}
+	if tailLines > 0 {
+		opts.TailLines = &tailLines
+	}
+
+	return m.clientset.CoreV1().Pods(m.namespace).GetLogs(pod.Name, opts).Stream(ctx)
 }
 
 func int64Ptr(i int64) *int64 {
 	return &i
 }
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
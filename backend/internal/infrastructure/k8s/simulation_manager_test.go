@@ -0,0 +1,38 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/theweirdfulmurk/cfd-platform/internal/domain"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSimulationFromJobDivergence(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		annotations map[string]string
+		want        domain.DivergenceRules
+	}{
+		{
+			name:        "annotated",
+			annotations: map[string]string{annotationDivergence: `{"MaxResidual":5,"MaxCourant":0,"StopOnNaN":false}`},
+			want:        domain.DivergenceRules{MaxResidual: 5},
+		},
+		{
+			name: "from an older backend",
+			want: domain.DefaultDivergenceRules(),
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+				Name:        "sim-s1",
+				Labels:      map[string]string{labelID: "s1", labelType: string(domain.SimTypeCFD)},
+				Annotations: tt.annotations,
+			}}
+			if got := simulationFromJob(job).Divergence; got != tt.want {
+				t.Errorf("divergence rules = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/theweirdfulmurk/cfd-platform/internal/domain"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// StatusWatcher keeps shared informers on platform Jobs and viz Pods and
+// hands every observed state to the registered callbacks, so status reaches
+// the repositories without polling the API server per request.
+type StatusWatcher struct {
+	factory         informers.SharedInformerFactory
+	onSimulation    func(*domain.Simulation)
+	onVisualization func(*domain.Visualization)
+}
+
+func NewStatusWatcher(clientset *kubernetes.Clientset, namespace string, resync time.Duration) *StatusWatcher {
+	factory := informers.NewSharedInformerFactoryWithOptions(
+		clientset,
+		resync,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.LabelSelector = fmt.Sprintf("%s in (%s,%s)", labelApp, appSimulation, appVisualization)
+		}),
+	)
+
+	return &StatusWatcher{factory: factory}
+}
+
+func (w *StatusWatcher) OnSimulation(fn func(*domain.Simulation)) {
+	w.onSimulation = fn
+}
+
+func (w *StatusWatcher) OnVisualization(fn func(*domain.Visualization)) {
+	w.onVisualization = fn
+}
+
+// Start registers the informers, starts them and blocks until their caches
+// have synced. Informers stop when ctx is done.
+func (w *StatusWatcher) Start(ctx context.Context) error {
+	jobInformer := w.factory.Batch().V1().Jobs().Informer()
+	if _, err := jobInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { w.handleJob(obj) },
+		UpdateFunc: func(_, obj interface{}) { w.handleJob(obj) },
+	}); err != nil {
+		return err
+	}
+
+	podInformer := w.factory.Core().V1().Pods().Informer()
+	if _, err := podInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { w.handlePod(obj) },
+		UpdateFunc: func(_, obj interface{}) { w.handlePod(obj) },
+	}); err != nil {
+		return err
+	}
+
+	w.factory.Start(ctx.Done())
+
+	for informerType, synced := range w.factory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			return fmt.Errorf("informer cache for %v did not sync", informerType)
+		}
+	}
+
+	return nil
+}
+
+func (w *StatusWatcher) handleJob(obj interface{}) {
+	job, ok := obj.(*batchv1.Job)
+	if !ok || w.onSimulation == nil {
+		return
+	}
+	w.onSimulation(simulationFromJob(job))
+}
+
+func (w *StatusWatcher) handlePod(obj interface{}) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok || pod.Labels[labelApp] != appVisualization || w.onVisualization == nil {
+		return
+	}
+	w.onVisualization(visualizationFromPod(pod))
+}
@@ -3,6 +3,7 @@ package k8s
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/theweirdfulmurk/cfd-platform/internal/domain"
 	corev1 "k8s.io/api/core/v1"
@@ -14,23 +15,32 @@ import (
 type VisualizationManager struct {
 	clientset *kubernetes.Clientset
 	namespace string
+	image     string
 }
 
-func NewVisualizationManager(clientset *kubernetes.Clientset, namespace string) *VisualizationManager {
+func NewVisualizationManager(clientset *kubernetes.Clientset, namespace, image string) *VisualizationManager {
 	return &VisualizationManager{
 		clientset: clientset,
 		namespace: namespace,
+		image:     image,
 	}
 }
 
-func (m *VisualizationManager) CreatePod(vizID, resultPath string) error {
+func (m *VisualizationManager) CreatePod(viz *domain.Visualization) error {
+	vizID, resultPath := viz.ID, viz.ResultPath
+
 	pod := &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: fmt.Sprintf("viz-%s", vizID),
 			Labels: map[string]string{
-				"app":  "paraview-viz",
-				"type": "visualization",
-				"id":   vizID,
+				labelApp:  appVisualization,
+				labelType: "visualization",
+				labelID:   vizID,
+			},
+			Annotations: map[string]string{
+				annotationSimulationID: viz.SimulationID,
+				annotationResultPath:   viz.ResultPath,
+				annotationCreatedAt:    formatTime(viz.CreatedAt),
 			},
 		},
 		Spec: corev1.PodSpec{
@@ -38,7 +48,7 @@ func (m *VisualizationManager) CreatePod(vizID, resultPath string) error {
 			Containers: []corev1.Container{
 				{
 					Name:  "paraview",
-					Image: "kitware/paraview:pvw-v5.7.1-osmesa-py2",
+					Image: m.image,
 					Command: []string{
 						"/bin/bash", "-c",
 						"cd /pvw && python -m light_viz.server --port 9000 --data /data",
@@ -100,20 +110,56 @@ func (m *VisualizationManager) GetPodStatus(vizID string) (domain.VisualizationS
 		return "", err
 	}
 
+	return podStatus(pod), nil
+}
+
+// ListPods rebuilds visualizations from every platform viz Pod in the
+// namespace. Pods created without metadata annotations come back with an
+// empty SimulationID.
+func (m *VisualizationManager) ListPods() ([]*domain.Visualization, error) {
+	pods, err := m.clientset.CoreV1().Pods(m.namespace).List(
+		context.Background(),
+		metav1.ListOptions{LabelSelector: labelApp + "=" + appVisualization},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	vizList := make([]*domain.Visualization, 0, len(pods.Items))
+	for i := range pods.Items {
+		vizList = append(vizList, visualizationFromPod(&pods.Items[i]))
+	}
+
+	return vizList, nil
+}
+
+func visualizationFromPod(pod *corev1.Pod) *domain.Visualization {
+	return &domain.Visualization{
+		ID:           pod.Labels[labelID],
+		SimulationID: pod.Annotations[annotationSimulationID],
+		Status:       podStatus(pod),
+		PodName:      pod.Name,
+		ResultPath:   pod.Annotations[annotationResultPath],
+		CreatedAt:    parseTime(pod.Annotations[annotationCreatedAt], pod.CreationTimestamp.Time),
+		UpdatedAt:    time.Now(),
+	}
+}
+
+func podStatus(pod *corev1.Pod) domain.VisualizationStatus {
 	switch pod.Status.Phase {
 	case corev1.PodRunning:
 		for _, cond := range pod.Status.Conditions {
 			if cond.Type == corev1.ContainersReady && cond.Status == corev1.ConditionTrue {
-				return domain.VizStatusReady, nil
+				return domain.VizStatusReady
 			}
 		}
-		return domain.VizStatusRunning, nil
+		return domain.VizStatusRunning
 	case corev1.PodPending:
-		return domain.VizStatusPending, nil
+		return domain.VizStatusPending
 	case corev1.PodFailed:
-		return domain.VizStatusFailed, nil
+		return domain.VizStatusFailed
 	default:
-		return domain.VizStatusPending, nil
+		return domain.VizStatusPending
 	}
 }
 
@@ -0,0 +1,373 @@
+package openfoam
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Patch is a boundary patch of the mesh.
+type Patch struct {
+	Name   string
+	Type   string
+	Groups []string
+	File   string
+	Line   int
+}
+
+// constraintTypes are patch types whose fields must use the boundary
+// condition of the same name, or one derived from it.
+var constraintTypes = map[string][]string{
+	"empty":              {"empty"},
+	"wedge":              {"wedge"},
+	"symmetry":           {"symmetry"},
+	"symmetryPlane":      {"symmetryPlane"},
+	"cyclic":             {"cyclic", "cyclicSlip", "fan", "fixedJump", "jumpCyclic", "porousBafflePressure", "uniformJump"},
+	"cyclicSlip":         {"cyclicSlip"},
+	"cyclicAMI":          {"cyclicAMI", "fixedJumpAMI", "jumpCyclicAMI"},
+	"cyclicACMI":         {"cyclicACMI"},
+	"nonConformalCyclic": {"nonConformalCyclic"},
+	"processor":          {"processor"},
+	"processorCyclic":    {"processorCyclic"},
+}
+
+// ReadPatches returns the boundary patches of the case, from
+// constant/polyMesh/boundary or, for cases meshed at run time, from
+// blockMeshDict. It returns nil when the patches aren't known before the
+// run: without a mesh or blockMeshDict, or when snappyHexMesh adds patches.
+func ReadPatches(caseDir string) ([]Patch, error) {
+	const boundaryFile = "constant/polyMesh/boundary"
+	if exists(caseDir, boundaryFile) {
+		return readBoundaryFile(caseDir, boundaryFile)
+	}
+	if exists(caseDir, "system/snappyHexMeshDict") {
+		return nil, nil
+	}
+	for _, file := range []string{"system/blockMeshDict", "constant/polyMesh/blockMeshDict"} {
+		if exists(caseDir, file) {
+			return readBlockMeshPatches(caseDir, file)
+		}
+	}
+	return nil, nil
+}
+
+func exists(caseDir, rel string) bool {
+	_, err := os.Stat(filepath.Join(caseDir, rel))
+	return err == nil
+}
+
+func readBoundaryFile(caseDir, file string) ([]Patch, error) {
+	if binary, err := isBinaryField(filepath.Join(caseDir, file)); err == nil && binary {
+		// The patch list is ASCII even in binary meshes, but be safe.
+		return nil, nil
+	}
+	dict, err := ParseFile(caseDir, file)
+	if err != nil {
+		return nil, err
+	}
+	if len(dict.Items) != 1 || dict.Items[0].Kind != ValueList {
+		return nil, &Error{File: file, Msg: "expected a list of patches"}
+	}
+
+	var patches []Patch
+	for _, item := range dict.Items[0].Items {
+		patch, err := patchFromItem(file, item)
+		if err != nil {
+			return nil, err
+		}
+		patches = append(patches, patch)
+	}
+	return patches, nil
+}
+
+// patchFromItem reads a "name { type wall; inGroups (...); ... }" item.
+func patchFromItem(file string, item Value) (Patch, error) {
+	if item.Kind != ValueDict || item.Text == "" {
+		return Patch{}, &Error{File: file, Line: item.Line, Msg: "expected a patch name followed by a dictionary"}
+	}
+	patch := Patch{Name: item.Text, Type: "patch", File: file, Line: item.Line}
+	if t, ok := item.Dict.Get("type").Word(); ok {
+		patch.Type = t
+	}
+	if groups := item.Dict.Get("inGroups"); groups != nil {
+		patch.Groups = listWords(groups.Values)
+	}
+	return patch, nil
+}
+
+// readBlockMeshPatches reads the "boundary" list of a blockMeshDict or the
+// older "patches" list.
+func readBlockMeshPatches(caseDir, file string) ([]Patch, error) {
+	dict, err := ParseFile(caseDir, file)
+	if err != nil {
+		return nil, err
+	}
+
+	var patches []Patch
+	if e := dict.Get("boundary"); e != nil {
+		if len(e.Values) != 1 || e.Values[0].Kind != ValueList {
+			return nil, &Error{File: file, Line: e.Line, Msg: "boundary must be a list"}
+		}
+		for _, item := range e.Values[0].Items {
+			patch, err := patchFromItem(file, item)
+			if err != nil {
+				return nil, err
+			}
+			patches = append(patches, patch)
+		}
+	} else if e := dict.Get("patches"); e != nil {
+		// patches ( type name ( faces ) ... );
+		if len(e.Values) != 1 || e.Values[0].Kind != ValueList || len(e.Values[0].Items)%3 != 0 {
+			return nil, &Error{File: file, Line: e.Line, Msg: "patches must be a list of type, name and faces"}
+		}
+		items := e.Values[0].Items
+		for i := 0; i < len(items); i += 3 {
+			patches = append(patches, Patch{Name: items[i+1].Text, Type: items[i].Text, File: file, Line: items[i].Line})
+		}
+	}
+
+	// Faces left out of every patch go to the default patch.
+	if def := dict.Sub("defaultPatch"); def != nil {
+		patch := Patch{Name: "defaultFaces", Type: "empty", File: file, Line: dict.Get("defaultPatch").Line}
+		if name, ok := def.Get("name").Word(); ok {
+			patch.Name = name
+		}
+		if t, ok := def.Get("type").Word(); ok {
+			patch.Type = t
+		}
+		patches = append(patches, patch)
+	}
+	return patches, nil
+}
+
+// listWords returns the words of a "List<word> N(a b)" or "(a b)" value.
+func listWords(values []Value) []string {
+	var words []string
+	for _, v := range values {
+		if v.Kind != ValueList {
+			continue
+		}
+		for _, item := range v.Items {
+			words = append(words, item.Text)
+		}
+	}
+	return words
+}
+
+// groups are the patch groups a field can address the patch by; a
+// constraint type is an implicit group.
+func (p Patch) groups() []string {
+	groups := p.Groups
+	if _, ok := constraintTypes[p.Type]; ok {
+		groups = append(append([]string(nil), groups...), p.Type)
+	}
+	return groups
+}
+
+// boundaryCondition finds the entry for patch in a boundaryField the way
+// OpenFOAM does: the patch name, then its groups, then regular expressions.
+func boundaryCondition(boundary *Dict, patch Patch) *Entry {
+	for _, key := range append([]string{patch.Name}, patch.groups()...) {
+		for _, e := range boundary.Entries {
+			if !e.Pattern && e.Key == key {
+				return e
+			}
+		}
+	}
+	return boundary.Get(patch.Name)
+}
+
+// boundaries checks every field against the mesh patches: each patch needs
+// a boundary condition, each named boundary condition a patch, and
+// constraint patches the matching condition. Known fields must also have
+// their usual dimensions.
+func (v *caseValidator) boundaries() {
+	for _, f := range v.fields {
+		v.fieldDimensions(f)
+	}
+
+	patches, err := ReadPatches(v.dir)
+	if err != nil {
+		var perr *Error
+		if errors.As(err, &perr) {
+			v.errs = append(v.errs, perr)
+		} else {
+			v.add("constant/polyMesh/boundary", 0, "%v", err)
+		}
+		return
+	}
+	if patches == nil {
+		return
+	}
+
+	names := make(map[string]bool)
+	for _, patch := range patches {
+		names[patch.Name] = true
+		for _, group := range patch.groups() {
+			names[group] = true
+		}
+	}
+
+	for _, f := range v.fields {
+		boundary := f.dict.Sub("boundaryField")
+		if boundary == nil {
+			continue
+		}
+		constraintsIncluded := includesConstraintTypes(boundary)
+
+		for _, patch := range patches {
+			if patch.Type == "processor" || patch.Type == "processorCyclic" {
+				continue
+			}
+			bc := boundaryCondition(boundary, patch)
+			if bc == nil {
+				if _, ok := constraintTypes[patch.Type]; ok && constraintsIncluded {
+					continue
+				}
+				v.add(f.file, f.dict.Get("boundaryField").Line, "no boundary condition for patch %s (%s:%d)",
+					patch.Name, patch.File, patch.Line)
+				continue
+			}
+			v.checkConstraint(f, patch, bc)
+		}
+
+		for _, e := range boundary.Entries {
+			if _, generic := constraintTypes[e.Key]; generic {
+				// Templates often set every constraint type up front.
+				continue
+			}
+			if !e.Pattern && !names[e.Key] {
+				v.add(f.file, e.Line, "boundary condition for %s matches no patch of the mesh", e.Key)
+			}
+		}
+	}
+}
+
+func includesConstraintTypes(boundary *Dict) bool {
+	for _, include := range boundary.Unresolved {
+		if strings.Contains(include, "setConstraintTypes") {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *caseValidator) checkConstraint(f fieldFile, patch Patch, bc *Entry) {
+	if bc.Dict == nil {
+		return
+	}
+	bcType, ok := bc.Dict.Get("type").Word()
+	if !ok {
+		return
+	}
+
+	if allowed, ok := constraintTypes[patch.Type]; ok {
+		if !contains(allowed, bcType) {
+			v.add(f.file, bc.Line, "patch %s is %s, so its boundary condition must be %s, not %s",
+				patch.Name, patch.Type, patch.Type, bcType)
+		}
+		return
+	}
+	if _, ok := constraintTypes[bcType]; ok {
+		v.add(f.file, bc.Line, "boundary condition %s needs a patch of type %s, but %s is %s",
+			bcType, bcType, patch.Name, patch.Type)
+	}
+}
+
+// knownFields lists the usual dimensions of common fields, in the order
+// mass, length, time, temperature. Pressure may be kinematic or static.
+var knownFields = map[string]struct {
+	components int
+	dimensions [][]float64
+}{
+	"U":       {3, [][]float64{{0, 1, -1, 0}}},
+	"p":       {1, [][]float64{{0, 2, -2, 0}, {1, -1, -2, 0}}},
+	"p_rgh":   {1, [][]float64{{0, 2, -2, 0}, {1, -1, -2, 0}}},
+	"T":       {1, [][]float64{{0, 0, 0, 1}}},
+	"k":       {1, [][]float64{{0, 2, -2, 0}}},
+	"epsilon": {1, [][]float64{{0, 2, -3, 0}}},
+	"omega":   {1, [][]float64{{0, 0, -1, 0}}},
+	"nut":     {1, [][]float64{{0, 2, -1, 0}}},
+	"nuTilda": {1, [][]float64{{0, 2, -1, 0}}},
+	"alphat":  {1, [][]float64{{1, -1, -1, 0}, {0, 2, -1, 0}}},
+	"alpha":   {1, [][]float64{{0, 0, 0, 0}}},
+}
+
+// fieldDimensions checks a well-known field, such as U or alpha.water,
+// against its usual class and dimensions.
+func (v *caseValidator) fieldDimensions(f fieldFile) {
+	base, _, _ := strings.Cut(f.name, ".")
+	want, ok := knownFields[base]
+	if !ok {
+		return
+	}
+	if f.components != want.components {
+		v.add(f.file, 1, "%s must be a %s field", f.name, map[int]string{1: "scalar", 3: "vector"}[want.components])
+		return
+	}
+
+	e := f.dict.Get("dimensions")
+	if e == nil || len(e.Values) != 1 || e.Values[0].Kind != ValueDimensions {
+		return
+	}
+	got, ok := dimensionExponents(e.Values[0])
+	if !ok {
+		return
+	}
+	for _, dims := range want.dimensions {
+		if equalDimensions(got, dims) {
+			return
+		}
+	}
+
+	expected := make([]string, len(want.dimensions))
+	for i, dims := range want.dimensions {
+		expected[i] = formatDimensions(dims)
+	}
+	v.add(f.file, e.Line, "dimensions of %s must be %s", f.name, strings.Join(expected, " or "))
+}
+
+// dimensionExponents returns numeric dimensions padded to seven exponents;
+// named units aren't checked.
+func dimensionExponents(v Value) ([]float64, bool) {
+	dims := make([]float64, 7)
+	if len(v.Items) != 5 && len(v.Items) != 7 {
+		return nil, false
+	}
+	for i, item := range v.Items {
+		n, ok := item.Number()
+		if !ok {
+			return nil, false
+		}
+		dims[i] = n
+	}
+	return dims, true
+}
+
+func equalDimensions(got, want []float64) bool {
+	for i := range got {
+		w := 0.0
+		if i < len(want) {
+			w = want[i]
+		}
+		if got[i] != w {
+			return false
+		}
+	}
+	return true
+}
+
+func formatDimensions(dims []float64) string {
+	parts := make([]string, 7)
+	for i := range parts {
+		w := 0.0
+		if i < len(dims) {
+			w = dims[i]
+		}
+		parts[i] = strconv.FormatFloat(w, 'g', -1, 64)
+	}
+	return fmt.Sprintf("[%s]", strings.Join(parts, " "))
+}
@@ -0,0 +1,297 @@
+package openfoam
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeCaseFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, text := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// boundaryMesh is a constant/polyMesh/boundary with one patch per line,
+// starting at line 11.
+func boundaryMesh(patches ...string) string {
+	return meshHeader("ascii", "polyBoundaryMesh", "boundary") +
+		fmt.Sprintf("%d\n(\n%s\n)\n", len(patches), strings.Join(patches, "\n"))
+}
+
+// field is a field file of the initial time directory.
+func field(class, name, dimensions, boundaryField string) string {
+	value := "0"
+	if strings.HasPrefix(class, "volVector") {
+		value = "(0 0 0)"
+	}
+	return meshHeader("ascii", class, name) +
+		fmt.Sprintf("dimensions %s;\ninternalField uniform %s;\nboundaryField\n{\n%s\n}\n", dimensions, value, boundaryField)
+}
+
+func pressure(boundaryField string) string {
+	return field("volScalarField", "p", "[0 2 -2 0 0 0 0]", boundaryField)
+}
+
+var channel = boundaryMesh(
+	"inlet { type patch; nFaces 1; startFace 0; }",
+	"walls { type wall; inGroups List<word> 1(wall); nFaces 2; startFace 1; }",
+	"frontAndBack { type empty; nFaces 2; startFace 3; }",
+)
+
+// channelBoundaries sets a condition for every patch of channel.
+const channelBoundaries = `inlet { type fixedValue; value uniform 0; }
+walls { type zeroGradient; }
+frontAndBack { type empty; }`
+
+// channelZeroGradient suits fields of any rank.
+const channelZeroGradient = `inlet { type zeroGradient; }
+walls { type zeroGradient; }
+frontAndBack { type empty; }`
+
+func TestBoundaries(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string // messages, in order
+	}{
+		{
+			name: "every patch has a condition",
+			files: map[string]string{
+				"constant/polyMesh/boundary": channel,
+				"0/p":                        pressure(channelBoundaries),
+			},
+		},
+		{
+			name: "missing condition",
+			files: map[string]string{
+				"constant/polyMesh/boundary": channel,
+				"0/p":                        pressure("walls { type zeroGradient; }\nfrontAndBack { type empty; }"),
+			},
+			want: []string{"no boundary condition for patch inlet (constant/polyMesh/boundary:11)"},
+		},
+		{
+			name: "condition without a patch",
+			files: map[string]string{
+				"constant/polyMesh/boundary": channel,
+				"0/p":                        pressure(channelBoundaries + "\noutlet { type zeroGradient; }"),
+			},
+			want: []string{"boundary condition for outlet matches no patch of the mesh"},
+		},
+		{
+			name: "empty patch without empty condition",
+			files: map[string]string{
+				"constant/polyMesh/boundary": channel,
+				"0/p":                        pressure("inlet { type zeroGradient; }\nwalls { type zeroGradient; }\nfrontAndBack { type zeroGradient; }"),
+			},
+			want: []string{"patch frontAndBack is empty, so its boundary condition must be empty, not zeroGradient"},
+		},
+		{
+			name: "wedge condition on a plain patch",
+			files: map[string]string{
+				"constant/polyMesh/boundary": channel,
+				"0/p":                        pressure("inlet { type wedge; }\nwalls { type zeroGradient; }\nfrontAndBack { type empty; }"),
+			},
+			want: []string{"boundary condition wedge needs a patch of type wedge, but inlet is patch"},
+		},
+		{
+			name: "wedge patch",
+			files: map[string]string{
+				"constant/polyMesh/boundary": boundaryMesh(
+					"front { type wedge; nFaces 1; startFace 0; }",
+					"back { type wedge; nFaces 1; startFace 1; }",
+				),
+				"0/p": pressure("front { type wedge; }\nback { type empty; }"),
+			},
+			want: []string{"patch back is wedge, so its boundary condition must be wedge, not empty"},
+		},
+		{
+			name: "condition derived from a constraint",
+			files: map[string]string{
+				"constant/polyMesh/boundary": boundaryMesh("fan_half0 { type cyclic; neighbourPatch fan_half1; nFaces 1; startFace 0; }"),
+				"0/p":                        pressure("fan_half0 { type fan; patchType cyclic; }"),
+			},
+		},
+		{
+			name: "conditions by group",
+			files: map[string]string{
+				"constant/polyMesh/boundary": channel,
+				"0/p":                        pressure("inlet { type zeroGradient; }\nwall { type zeroGradient; }\nempty { type empty; }"),
+			},
+		},
+		{
+			name: "conditions by regular expression",
+			files: map[string]string{
+				"constant/polyMesh/boundary": channel,
+				"0/p":                        pressure(`"(inlet|walls)" { type zeroGradient; }` + "\n\"outlet.*\" { type zeroGradient; }\nfrontAndBack { type empty; }"),
+			},
+		},
+		{
+			name: "name before group before regular expression",
+			files: map[string]string{
+				"constant/polyMesh/boundary": channel,
+				"0/p":                        pressure(`".*" { type empty; }` + "\nwall { type zeroGradient; }\ninlet { type zeroGradient; }\nfrontAndBack { type empty; }"),
+			},
+		},
+		{
+			name: "constraint types from setConstraintTypes",
+			files: map[string]string{
+				"constant/polyMesh/boundary": channel,
+				"0/p":                        pressure("#includeEtc \"caseDicts/setConstraintTypes\"\ninlet { type zeroGradient; }\nwalls { type zeroGradient; }"),
+			},
+		},
+		{
+			name: "processor patches",
+			files: map[string]string{
+				"constant/polyMesh/boundary": boundaryMesh(
+					"walls { type wall; nFaces 1; startFace 0; }",
+					"procBoundary0to1 { type processor; nFaces 1; startFace 1; myProcNo 0; neighbProcNo 1; }",
+				),
+				"0/p": pressure("walls { type zeroGradient; }"),
+			},
+		},
+		{
+			name: "blockMeshDict boundary and defaultPatch",
+			files: map[string]string{
+				"system/blockMeshDict": meshHeader("ascii", "dictionary", "blockMeshDict") +
+					"boundary\n(\n    inlet { type patch; faces ((0 4 7 3)); }\n);\ndefaultPatch { name frontAndBack; type empty; }\n",
+				"0/p": pressure("inlet { type zeroGradient; }"),
+			},
+			want: []string{"no boundary condition for patch frontAndBack (system/blockMeshDict:13)"},
+		},
+		{
+			name: "blockMeshDict patches",
+			files: map[string]string{
+				"system/blockMeshDict": meshHeader("ascii", "dictionary", "blockMeshDict") +
+					"patches\n(\n    wall walls ((0 4 7 3))\n    patch inlet ((1 2 6 5))\n);\ndefaultPatch {}\n",
+				"0/p": pressure("walls { type zeroGradient; }\ninlet { type zeroGradient; }\ndefaultFaces { type zeroGradient; }"),
+			},
+			want: []string{"patch defaultFaces is empty, so its boundary condition must be empty, not zeroGradient"},
+		},
+		{
+			name: "patches made by snappyHexMesh",
+			files: map[string]string{
+				"system/blockMeshDict":     meshHeader("ascii", "dictionary", "blockMeshDict") + "boundary ();\n",
+				"system/snappyHexMeshDict": meshHeader("ascii", "dictionary", "snappyHexMeshDict"),
+				"0/p":                      pressure("motorBike { type zeroGradient; }"),
+			},
+		},
+		{
+			name: "dimensions of known fields",
+			files: map[string]string{
+				"constant/polyMesh/boundary": channel,
+				"0/U":                        field("volVectorField", "U", "[0 2 -1 0 0 0 0]", channelZeroGradient),
+				"0/p":                        field("volScalarField", "p", "[1 -1 -2 0 0 0 0]", channelBoundaries),
+				"0/alpha.water":              field("volScalarField", "alpha.water", "[0 0 0 0 0]", channelBoundaries),
+				"0/k":                        field("volVectorField", "k", "[0 2 -2 0 0 0 0]", channelZeroGradient),
+				"0/T":                        field("volScalarField", "T", "[K]", channelBoundaries),
+			},
+			want: []string{
+				"dimensions of U must be [0 1 -1 0 0 0 0]",
+				"k must be a scalar field",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &caseValidator{dir: writeCaseFiles(t, tt.files)}
+			v.initialFields()
+			v.boundaries()
+
+			var got []string
+			for _, err := range v.errs {
+				got = append(got, err.Msg)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("errors:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestReadPatches(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		want    []Patch
+		wantErr string
+	}{
+		{
+			name:  "mesh",
+			files: map[string]string{"constant/polyMesh/boundary": channel},
+			want: []Patch{
+				{Name: "inlet", Type: "patch", File: "constant/polyMesh/boundary", Line: 11},
+				{Name: "walls", Type: "wall", Groups: []string{"wall"}, File: "constant/polyMesh/boundary", Line: 12},
+				{Name: "frontAndBack", Type: "empty", File: "constant/polyMesh/boundary", Line: 13},
+			},
+		},
+		{
+			name: "mesh before blockMeshDict",
+			files: map[string]string{
+				"constant/polyMesh/boundary": boundaryMesh("walls { type wall; }"),
+				"system/blockMeshDict":       meshHeader("ascii", "dictionary", "blockMeshDict") + "boundary ( inlet { type patch; } );\n",
+			},
+			want: []Patch{{Name: "walls", Type: "wall", File: "constant/polyMesh/boundary", Line: 11}},
+		},
+		{
+			name: "blockMeshDict of older versions",
+			files: map[string]string{
+				"constant/polyMesh/blockMeshDict": meshHeader("ascii", "dictionary", "blockMeshDict") +
+					"patches\n(\n    symmetryPlane axis ()\n);\n",
+			},
+			want: []Patch{{Name: "axis", Type: "symmetryPlane", File: "constant/polyMesh/blockMeshDict", Line: 11}},
+		},
+		{
+			name: "patch without a type",
+			files: map[string]string{
+				"system/blockMeshDict": meshHeader("ascii", "dictionary", "blockMeshDict") + "boundary ( inlet { faces (); } );\n",
+			},
+			want: []Patch{{Name: "inlet", Type: "patch", File: "system/blockMeshDict", Line: 9}},
+		},
+		{
+			name:  "no mesh yet",
+			files: map[string]string{"system/controlDict": meshHeader("ascii", "dictionary", "controlDict")},
+		},
+		{
+			name:    "not a patch list",
+			files:   map[string]string{"constant/polyMesh/boundary": meshHeader("ascii", "polyBoundaryMesh", "boundary") + "walls wall;\n"},
+			wantErr: "expected a list of patches",
+		},
+		{
+			name:    "malformed patches",
+			files:   map[string]string{"system/blockMeshDict": meshHeader("ascii", "dictionary", "blockMeshDict") + "patches ( wall walls );\n"},
+			wantErr: "patches must be a list of type, name and faces",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadPatches(writeCaseFiles(t, tt.files))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("patches = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
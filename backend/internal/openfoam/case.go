@@ -0,0 +1,169 @@
+package openfoam
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrNoCase is returned when a directory tree contains no system/controlDict.
+var ErrNoCase = errors.New("no OpenFOAM case found")
+
+// FindCaseDir returns the shallowest directory under root that contains
+// system/controlDict. Uploaded archives often wrap the case in a top-level
+// folder, so the case is not necessarily root itself.
+func FindCaseDir(root string) (string, error) {
+	best := ""
+	bestDepth := -1
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() != "controlDict" || filepath.Base(filepath.Dir(path)) != "system" {
+			return nil
+		}
+
+		caseDir := filepath.Dir(filepath.Dir(path))
+		depth := strings.Count(caseDir, string(filepath.Separator))
+		if bestDepth < 0 || depth < bestDepth {
+			best, bestDepth = caseDir, depth
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if best == "" {
+		return "", ErrNoCase
+	}
+	return best, nil
+}
+
+// LatestTime returns the name and value of the highest numeric time
+// directory in caseDir.
+func LatestTime(caseDir string) (string, float64, error) {
+	entries, err := os.ReadDir(caseDir)
+	if err != nil {
+		return "", 0, err
+	}
+
+	name, latest := "", -1.0
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		t, err := strconv.ParseFloat(entry.Name(), 64)
+		if err != nil {
+			continue
+		}
+		if t > latest {
+			name, latest = entry.Name(), t
+		}
+	}
+	if name == "" {
+		return "", 0, fmt.Errorf("no time directories in %s", caseDir)
+	}
+	return name, latest, nil
+}
+
+// SetTopLevelEntry replaces the value of a top-level "key value;" entry in a
+// dictionary file, or appends the entry when the key is absent. Entries
+// inside sub-dictionaries (including the FoamFile header) are left alone.
+func SetTopLevelEntry(content []byte, key, value string) []byte {
+	entryRe := regexp.MustCompile(`^(\s*)` + regexp.QuoteMeta(key) + `(\s+)[^;]*;`)
+
+	lines := strings.Split(string(content), "\n")
+	depth := 0
+	inBlockComment := false
+	for i, line := range lines {
+		if depth == 0 && !inBlockComment && entryRe.MatchString(line) {
+			lines[i] = entryRe.ReplaceAllString(line, "${1}"+key+"${2}"+value+";")
+			return []byte(strings.Join(lines, "\n"))
+		}
+		depth, inBlockComment = braceDepth(line, depth, inBlockComment)
+	}
+
+	out := strings.TrimRight(string(content), "\n")
+	return []byte(fmt.Sprintf("%s\n\n%s %s;\n", out, key, value))
+}
+
+// braceDepth updates the dictionary nesting depth after line, ignoring
+// braces inside comments and strings.
+func braceDepth(line string, depth int, inBlockComment bool) (int, bool) {
+	inString := false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case inBlockComment:
+			if c == '*' && i+1 < len(line) && line[i+1] == '/' {
+				inBlockComment = false
+				i++
+			}
+		case inString:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '/' && i+1 < len(line) && line[i+1] == '/':
+			return depth, false
+		case c == '/' && i+1 < len(line) && line[i+1] == '*':
+			inBlockComment = true
+			i++
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+		}
+	}
+	return depth, inBlockComment
+}
+
+// TopLevelEntry returns the value of a top-level "key value;" entry of a
+// dictionary file.
+func TopLevelEntry(content []byte, key string) (string, bool) {
+	entryRe := regexp.MustCompile(`^\s*` + regexp.QuoteMeta(key) + `\s+([^;]*);`)
+
+	depth := 0
+	inBlockComment := false
+	for _, line := range strings.Split(string(content), "\n") {
+		if depth == 0 && !inBlockComment {
+			if m := entryRe.FindStringSubmatch(line); m != nil {
+				return strings.TrimSpace(m[1]), true
+			}
+		}
+		depth, inBlockComment = braceDepth(line, depth, inBlockComment)
+	}
+	return "", false
+}
+
+// applicationName is a solver the pod can find on its PATH.
+var applicationName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.-]*$`)
+
+// Application returns the solver named by the application entry of
+// system/controlDict, with macros and includes resolved.
+func Application(caseDir string) (string, error) {
+	const file = "system/controlDict"
+	dict, err := ParseFile(caseDir, file)
+	if err != nil {
+		return "", err
+	}
+	e := dict.Get("application")
+	app, ok := e.Word()
+	if !ok || !applicationName.MatchString(app) {
+		line := 0
+		if e != nil {
+			line = e.Line
+		}
+		return "", &Error{File: file, Line: line, Msg: "application must be a solver name"}
+	}
+	return app, nil
+}
@@ -0,0 +1,696 @@
+package openfoam
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/theweirdfulmurk/cfd-platform/internal/casefile"
+)
+
+// Error is a problem in a case file, reported with its line number.
+type Error = casefile.Error
+
+// ValueKind tells the kinds of dictionary values apart.
+type ValueKind int
+
+const (
+	ValueWord ValueKind = iota
+	ValueNumber
+	ValueString
+	ValueList       // ( ... ), N( ... ) or N{ ... }
+	ValueDimensions // [ ... ]
+	ValueDict       // a dictionary inside a list, optionally named
+	ValueDirective  // #calc, #codeStream and friends, kept unevaluated
+	ValueVerbatim   // #{ ... #}
+)
+
+// Value is one item of an entry's value.
+type Value struct {
+	Kind  ValueKind
+	Text  string  // words, numbers, strings, directive names, list item names
+	Items []Value // list and dimension items; a N{v} list holds just v
+	Count int     // the N of a N( ... ) or N{ ... } list, -1 when absent
+	Dict  *Dict
+	Line  int
+}
+
+// Number returns the value as a number.
+func (v Value) Number() (float64, bool) {
+	if v.Kind != ValueNumber {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(v.Text, 64)
+	return f, err == nil
+}
+
+// Entry is a keyword with either a sub-dictionary or a list of values.
+type Entry struct {
+	Key     string
+	Pattern bool // a quoted key, matched as a regular expression
+	File    string
+	Line    int
+	Dict    *Dict
+	Values  []Value
+}
+
+// Word returns the entry's value when it is a single word.
+func (e *Entry) Word() (string, bool) {
+	if e == nil || len(e.Values) != 1 || e.Values[0].Kind != ValueWord {
+		return "", false
+	}
+	return e.Values[0].Text, true
+}
+
+// Number returns the entry's value when it is a single number.
+func (e *Entry) Number() (float64, bool) {
+	if e == nil || len(e.Values) != 1 {
+		return 0, false
+	}
+	return e.Values[0].Number()
+}
+
+// Dict is an OpenFOAM dictionary. Entries keep their file order; a later
+// entry with the same key replaces an earlier one, and two sub-dictionaries
+// with the same key are merged, as with OpenFOAM's default #inputMode.
+type Dict struct {
+	Entries []*Entry
+	// Items holds a list written without a keyword, as in polyMesh files.
+	Items []Value
+	// Unresolved lists #includeEtc and #includeFunc targets, which refer
+	// to the OpenFOAM installation rather than the case.
+	Unresolved []string
+
+	parent *Dict
+}
+
+// Get returns the entry for key. Exact keys win over pattern keys; among
+// patterns the last matching one wins.
+func (d *Dict) Get(key string) *Entry {
+	if d == nil {
+		return nil
+	}
+	for _, e := range d.Entries {
+		if !e.Pattern && e.Key == key {
+			return e
+		}
+	}
+	for i := len(d.Entries) - 1; i >= 0; i-- {
+		e := d.Entries[i]
+		if !e.Pattern {
+			continue
+		}
+		if re, err := regexp.Compile("^(?:" + e.Key + ")$"); err == nil && re.MatchString(key) {
+			return e
+		}
+	}
+	return nil
+}
+
+// Sub returns the sub-dictionary for key, or nil.
+func (d *Dict) Sub(key string) *Dict {
+	if e := d.Get(key); e != nil {
+		return e.Dict
+	}
+	return nil
+}
+
+func (d *Dict) add(e *Entry) {
+	for i, existing := range d.Entries {
+		if existing.Key != e.Key || existing.Pattern != e.Pattern {
+			continue
+		}
+		if existing.Dict != nil && e.Dict != nil {
+			for _, sub := range e.Dict.Entries {
+				existing.Dict.add(sub)
+			}
+			return
+		}
+		d.Entries[i] = e
+		return
+	}
+	d.Entries = append(d.Entries, e)
+}
+
+func (d *Dict) remove(key string) {
+	kept := d.Entries[:0]
+	for _, e := range d.Entries {
+		if e.Key != key {
+			kept = append(kept, e)
+		}
+	}
+	d.Entries = kept
+}
+
+func (d *Dict) root() *Dict {
+	for d.parent != nil {
+		d = d.parent
+	}
+	return d
+}
+
+// lookupVariable resolves a $ macro: "name" searches this dictionary and
+// then its parents, ":a.b" starts at the top level, "..name" starts one
+// level up per extra dot and "a.b" descends into sub-dictionaries.
+func (d *Dict) lookupVariable(name string) *Entry {
+	scope := d
+	recursive := true
+	switch {
+	case strings.HasPrefix(name, ":"):
+		scope = d.root()
+		name = name[1:]
+		recursive = false
+	case strings.HasPrefix(name, ".."):
+		dots := len(name) - len(strings.TrimLeft(name, "."))
+		for i := 1; i < dots && scope.parent != nil; i++ {
+			scope = scope.parent
+		}
+		name = name[dots:]
+		recursive = false
+	}
+
+	parts := strings.Split(name, ".")
+	var entry *Entry
+	for s := scope; s != nil; s = s.parent {
+		if entry = s.Get(parts[0]); entry != nil || !recursive {
+			break
+		}
+	}
+	for _, part := range parts[1:] {
+		if entry == nil || entry.Dict == nil {
+			return nil
+		}
+		entry = entry.Dict.Get(part)
+	}
+	return entry
+}
+
+const (
+	// maxIncludeDepth stops #include cycles.
+	maxIncludeDepth = 8
+	// maxNesting bounds how deeply lists and dictionaries nest.
+	maxNesting = 256
+	// maxExpanded bounds the values and entries $ macros copy; macros
+	// that repeat each other grow exponentially.
+	maxExpanded = 100000
+	// maxFiles and maxBytes bound the files a parse reads, counting a
+	// file each time it is included: files that include the next one
+	// several times grow exponentially too.
+	maxFiles = 200
+	maxBytes = 256 << 20
+)
+
+// budget counts the work of one ParseFile or ParseDict call, shared by
+// the parsers of all the files it includes.
+type budget struct {
+	files    int
+	bytes    int64
+	expanded int
+}
+
+// parser builds dictionaries from one file and the files it includes.
+type parser struct {
+	caseDir string // #include targets must stay inside; empty disables includes
+	file    string // relative to caseDir, for error messages
+	lex     *lexer
+	depth   int
+	budget  *budget
+
+	nesting int // lists and dictionaries open around the current token
+}
+
+// ParseFile parses the dictionary file rel of the case in caseDir.
+// #include directives are resolved relative to the including file and may
+// not leave the case.
+func ParseFile(caseDir, rel string) (*Dict, error) {
+	return parseFile(caseDir, rel, nil, 0, &budget{})
+}
+
+// ParseDict parses dictionary text; #include directives are not followed.
+func ParseDict(name string, data []byte) (*Dict, error) {
+	p := &parser{file: name, lex: newLexer(name, data), budget: &budget{}}
+	dict := &Dict{}
+	if err := p.parseEntries(dict, false); err != nil {
+		return nil, err
+	}
+	return dict, nil
+}
+
+// readFile reads the file rel of the case, or fails once the files read
+// so far pass maxFiles or maxBytes.
+func (b *budget) readFile(caseDir, rel string) ([]byte, error) {
+	f, err := casefile.Open(caseDir, rel)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	b.files++
+	if b.files > maxFiles {
+		return nil, fmt.Errorf("more than %d files to read", maxFiles)
+	}
+	data, err := io.ReadAll(io.LimitReader(f, maxBytes-b.bytes+1))
+	if err != nil {
+		return nil, err
+	}
+	b.bytes += int64(len(data))
+	if b.bytes > maxBytes {
+		return nil, fmt.Errorf("more than %d MiB to read", maxBytes>>20)
+	}
+	return data, nil
+}
+
+func parseFile(caseDir, rel string, into *Dict, depth int, b *budget) (*Dict, error) {
+	data, err := b.readFile(caseDir, rel)
+	if err != nil {
+		return nil, &Error{File: rel, Msg: err.Error()}
+	}
+
+	p := &parser{caseDir: caseDir, file: rel, lex: newLexer(rel, data), depth: depth, budget: b}
+	dict := into
+	if dict == nil {
+		dict = &Dict{}
+	}
+	if err := p.parseEntries(dict, false); err != nil {
+		return nil, err
+	}
+	return dict, nil
+}
+
+func (p *parser) errorf(line int, format string, args ...any) error {
+	return &Error{File: p.file, Line: line, Msg: fmt.Sprintf(format, args...)}
+}
+
+// enter opens a list or dictionary started on line; leave closes it.
+func (p *parser) enter(line int) error {
+	p.nesting++
+	if p.nesting > maxNesting {
+		return p.errorf(line, "lists and dictionaries nested more than %d deep", maxNesting)
+	}
+	return nil
+}
+
+func (p *parser) leave() { p.nesting-- }
+
+// expand accounts for n values or entries copied by the macro t.
+func (p *parser) expand(t token, n int) error {
+	p.budget.expanded += n
+	if p.budget.expanded > maxExpanded {
+		return p.errorf(t.line, "$%s expands the file past %d values", t.text, maxExpanded)
+	}
+	return nil
+}
+
+func (p *parser) next() (token, error) {
+	t := p.lex.Next()
+	if p.lex.err != nil {
+		return t, p.lex.err
+	}
+	return t, nil
+}
+
+func (p *parser) peek() (token, error) {
+	t := p.lex.Peek()
+	if p.lex.err != nil {
+		return t, p.lex.err
+	}
+	return t, nil
+}
+
+// parseEntries reads entries into dict until EOF or, when closed is set, the
+// matching "}".
+func (p *parser) parseEntries(dict *Dict, closed bool) error {
+	if closed {
+		// The opening "{" was the last token read.
+		if err := p.enter(p.lex.line); err != nil {
+			return err
+		}
+		defer p.leave()
+	}
+	for {
+		t, err := p.next()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case t.kind == tokEOF:
+			if closed {
+				return p.errorf(t.line, "unexpected end of file, missing }")
+			}
+			return nil
+		case t.kind == tokPunct && t.text == "}":
+			if closed {
+				return nil
+			}
+			return p.errorf(t.line, "unexpected }")
+		case t.kind == tokPunct && t.text == ";":
+			// A stray ";" after a sub-dictionary is harmless.
+		case t.kind == tokDirective:
+			if err := p.parseDirective(dict, t); err != nil {
+				return err
+			}
+		case t.kind == tokVariable:
+			// "$name;" pulls in the entries of another dictionary.
+			ref := dict.lookupVariable(t.text)
+			if ref == nil {
+				return p.errorf(t.line, "undefined variable $%s", t.text)
+			}
+			if ref.Dict == nil {
+				return p.errorf(t.line, "$%s is not a dictionary", t.text)
+			}
+			if err := p.expand(t, countEntries(ref.Dict)); err != nil {
+				return err
+			}
+			for _, e := range ref.Dict.Entries {
+				dict.add(copyEntry(e, dict))
+			}
+			if next, _ := p.peek(); next.kind == tokPunct && next.text == ";" {
+				p.next()
+			}
+		case t.kind == tokWord && isCount(t.text) && p.startsList():
+			// A list without a keyword, as in constant/polyMesh/boundary.
+			v, err := p.parseValue(t, dict)
+			if err != nil {
+				return err
+			}
+			dict.Items = append(dict.Items, v)
+		case t.kind == tokPunct && t.text == "(" && len(dict.Entries) == 0:
+			v, err := p.parseValue(t, dict)
+			if err != nil {
+				return err
+			}
+			dict.Items = append(dict.Items, v)
+		case t.kind == tokWord || t.kind == tokString:
+			if err := p.parseEntry(dict, t); err != nil {
+				return err
+			}
+		default:
+			return p.errorf(t.line, "unexpected %q, expected a keyword", t.text)
+		}
+	}
+}
+
+func (p *parser) startsList() bool {
+	next, err := p.peek()
+	return err == nil && next.kind == tokPunct && (next.text == "(" || next.text == "{")
+}
+
+func (p *parser) parseEntry(dict *Dict, key token) error {
+	entry := &Entry{Key: key.text, Pattern: key.kind == tokString, File: p.file, Line: key.line}
+
+	next, err := p.peek()
+	if err != nil {
+		return err
+	}
+	if next.kind == tokPunct && next.text == "{" {
+		p.next()
+		entry.Dict = &Dict{parent: dict}
+		if err := p.parseEntries(entry.Dict, true); err != nil {
+			return err
+		}
+		dict.add(entry)
+		return nil
+	}
+
+	for {
+		t, err := p.next()
+		if err != nil {
+			return err
+		}
+		if t.kind == tokEOF {
+			return p.errorf(key.line, "entry %s is missing a ;", key.text)
+		}
+		if t.kind == tokPunct && t.text == ";" {
+			break
+		}
+		if t.kind == tokPunct && t.text == "}" {
+			return p.errorf(t.line, "entry %s is missing a ;", key.text)
+		}
+		values, err := p.parseValues(t, dict)
+		if err != nil {
+			return err
+		}
+		entry.Values = append(entry.Values, values...)
+	}
+
+	dict.add(entry)
+	return nil
+}
+
+// parseValues parses the value starting at t; a variable expands to the
+// values it refers to.
+func (p *parser) parseValues(t token, scope *Dict) ([]Value, error) {
+	if t.kind != tokVariable {
+		v, err := p.parseValue(t, scope)
+		return []Value{v}, err
+	}
+
+	ref := scope.lookupVariable(t.text)
+	if ref == nil {
+		return nil, p.errorf(t.line, "undefined variable $%s", t.text)
+	}
+	if ref.Dict != nil {
+		return []Value{{Kind: ValueDict, Dict: ref.Dict, Count: -1, Line: t.line}}, p.expand(t, 1)
+	}
+	// Nested lists are shared with the referenced entry, so only its
+	// top-level values are copied.
+	return ref.Values, p.expand(t, len(ref.Values))
+}
+
+func (p *parser) parseValue(t token, scope *Dict) (Value, error) {
+	v := Value{Text: t.text, Count: -1, Line: t.line}
+
+	switch t.kind {
+	case tokString:
+		v.Kind = ValueString
+	case tokVerbatim:
+		v.Kind = ValueVerbatim
+	case tokDirective:
+		// Inline directives such as #calc "..." or #codeStream { ... } are
+		// kept as they are; evaluating them needs a compiler.
+		v.Kind = ValueDirective
+		arg, err := p.next()
+		if err != nil {
+			return v, err
+		}
+		if arg.kind == tokPunct && arg.text == "{" {
+			v.Dict = &Dict{parent: scope}
+			return v, p.parseEntries(v.Dict, true)
+		}
+		v.Items = []Value{{Kind: ValueString, Text: arg.text, Count: -1, Line: arg.line}}
+	case tokWord:
+		if _, err := strconv.ParseFloat(t.text, 64); err == nil {
+			v.Kind = ValueNumber
+		} else {
+			v.Kind = ValueWord
+		}
+		if isCount(t.text) && p.startsList() {
+			n, _ := strconv.Atoi(t.text)
+			open, _ := p.next()
+			list, err := p.parseList(open, scope)
+			if err != nil {
+				return v, err
+			}
+			list.Count = n
+			list.Text = ""
+			if open.text == "(" && len(list.Items) != n {
+				return list, p.errorf(t.line, "list declares %d items but has %d", n, len(list.Items))
+			}
+			return list, nil
+		}
+	case tokPunct:
+		switch t.text {
+		case "(", "[":
+			return p.parseList(t, scope)
+		case "{":
+			v.Kind = ValueDict
+			v.Dict = &Dict{parent: scope}
+			return v, p.parseEntries(v.Dict, true)
+		default:
+			return v, p.errorf(t.line, "unexpected %q", t.text)
+		}
+	default:
+		return v, p.errorf(t.line, "unexpected %q", t.text)
+	}
+	return v, nil
+}
+
+// parseList reads the items after an opening "(", "[" or, for N{v} lists,
+// "{".
+func (p *parser) parseList(open token, scope *Dict) (Value, error) {
+	v := Value{Kind: ValueList, Count: -1, Line: open.line}
+	closing := ")"
+	switch open.text {
+	case "[":
+		v.Kind = ValueDimensions
+		closing = "]"
+	case "{":
+		closing = "}"
+	}
+	if err := p.enter(open.line); err != nil {
+		return v, err
+	}
+	defer p.leave()
+
+	for {
+		t, err := p.next()
+		if err != nil {
+			return v, err
+		}
+		switch {
+		case t.kind == tokEOF:
+			return v, p.errorf(open.line, "unclosed %s", open.text)
+		case t.kind == tokPunct && t.text == closing:
+			return v, nil
+		case t.kind == tokPunct && (t.text == ")" || t.text == "]" || t.text == "}" || t.text == ";"):
+			return v, p.errorf(t.line, "unexpected %q in list opened on line %d", t.text, open.line)
+		case t.kind == tokWord && p.peekIs("{") && !isCount(t.text):
+			// A named dictionary inside a list, as in blockMeshDict.
+			p.next()
+			item := Value{Kind: ValueDict, Text: t.text, Count: -1, Line: t.line, Dict: &Dict{parent: scope}}
+			if err := p.parseEntries(item.Dict, true); err != nil {
+				return v, err
+			}
+			v.Items = append(v.Items, item)
+		default:
+			items, err := p.parseValues(t, scope)
+			if err != nil {
+				return v, err
+			}
+			v.Items = append(v.Items, items...)
+		}
+	}
+}
+
+func (p *parser) peekIs(punct string) bool {
+	next, err := p.peek()
+	return err == nil && next.kind == tokPunct && next.text == punct
+}
+
+func (p *parser) parseDirective(dict *Dict, t token) error {
+	switch t.text {
+	case "include", "includeIfPresent":
+		arg, err := p.next()
+		if err != nil {
+			return err
+		}
+		if arg.kind != tokString {
+			return p.errorf(t.line, "#%s needs a quoted file name", t.text)
+		}
+		return p.include(dict, t, arg.text)
+	case "includeEtc", "includeFunc", "includeModel":
+		arg, err := p.next()
+		if err != nil {
+			return err
+		}
+		dict.Unresolved = append(dict.Unresolved, "#"+t.text+" "+arg.text)
+		// #includeFunc may carry arguments: #includeFunc probes(U, p)
+		return nil
+	case "remove":
+		arg, err := p.next()
+		if err != nil {
+			return err
+		}
+		if arg.kind == tokPunct && arg.text == "(" {
+			list, err := p.parseList(arg, dict)
+			if err != nil {
+				return err
+			}
+			for _, item := range list.Items {
+				dict.remove(item.Text)
+			}
+			return nil
+		}
+		dict.remove(arg.text)
+		return nil
+	case "inputMode", "default", "merge", "overwrite", "warn", "error":
+		if t.text == "inputMode" {
+			_, err := p.next()
+			return err
+		}
+		return nil
+	default:
+		// Unknown directives take one argument, possibly a dictionary.
+		arg, err := p.next()
+		if err != nil {
+			return err
+		}
+		if arg.kind == tokPunct && arg.text == "{" {
+			return p.parseEntries(&Dict{parent: dict}, true)
+		}
+		return nil
+	}
+}
+
+func (p *parser) include(dict *Dict, directive token, name string) error {
+	if p.caseDir == "" {
+		dict.Unresolved = append(dict.Unresolved, "#include "+name)
+		return nil
+	}
+	if p.depth >= maxIncludeDepth {
+		return p.errorf(directive.line, "#include nested too deeply")
+	}
+
+	var rel string
+	switch {
+	case strings.HasPrefix(name, "$FOAM_CASE/"):
+		rel = strings.TrimPrefix(name, "$FOAM_CASE/")
+	case strings.HasPrefix(name, "<case>/"):
+		rel = strings.TrimPrefix(name, "<case>/")
+	case strings.HasPrefix(name, "<system>/"):
+		rel = filepath.Join("system", strings.TrimPrefix(name, "<system>/"))
+	case strings.HasPrefix(name, "<constant>/"):
+		rel = filepath.Join("constant", strings.TrimPrefix(name, "<constant>/"))
+	case strings.HasPrefix(name, "$") || strings.HasPrefix(name, "<") || filepath.IsAbs(name):
+		return p.errorf(directive.line, "#%s %q points outside the case", directive.text, name)
+	default:
+		rel = filepath.Join(filepath.Dir(p.file), name)
+	}
+	rel = filepath.Clean(rel)
+	if !filepath.IsLocal(rel) {
+		return p.errorf(directive.line, "#%s %q points outside the case", directive.text, name)
+	}
+
+	if directive.text == "includeIfPresent" {
+		if _, err := os.Stat(filepath.Join(p.caseDir, rel)); err != nil {
+			return nil
+		}
+	}
+
+	_, err := parseFile(p.caseDir, rel, dict, p.depth+1, p.budget)
+	if err != nil {
+		if e, ok := err.(*Error); ok && e.Line == 0 {
+			return p.errorf(directive.line, "cannot include %q: %s", name, e.Msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// countEntries counts the entries copyEntry copies for d.
+func countEntries(d *Dict) int {
+	n := len(d.Entries)
+	for _, e := range d.Entries {
+		if e.Dict != nil {
+			n += countEntries(e.Dict)
+		}
+	}
+	return n
+}
+
+// copyEntry copies e for use under another parent, as "$name;" does.
+func copyEntry(e *Entry, parent *Dict) *Entry {
+	c := *e
+	if e.Dict != nil {
+		c.Dict = &Dict{parent: parent, Items: e.Dict.Items, Unresolved: e.Dict.Unresolved}
+		for _, sub := range e.Dict.Entries {
+			c.Dict.Entries = append(c.Dict.Entries, copyEntry(sub, c.Dict))
+		}
+	}
+	return &c
+}
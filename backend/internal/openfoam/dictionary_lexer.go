@@ -0,0 +1,213 @@
+package openfoam
+
+import (
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokString
+	tokPunct     // { } ( ) [ ] ;
+	tokDirective // #include, #calc, ...
+	tokVariable  // $var, ${var}, $:a.b
+	tokVerbatim  // #{ ... #}
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	line int
+}
+
+// lexer splits OpenFOAM dictionary text into tokens. Words may carry
+// balanced parentheses, as in "div(phi,U)", but a bare count before a list,
+// as in "3(1 2 3)", is a word of its own.
+type lexer struct {
+	src  string
+	pos  int
+	line int
+	peek *token
+	err  *Error
+	file string
+}
+
+func newLexer(file string, src []byte) *lexer {
+	return &lexer{src: string(src), line: 1, file: file}
+}
+
+func (l *lexer) fail(line int, msg string) token {
+	if l.err == nil {
+		l.err = &Error{File: l.file, Line: line, Msg: msg}
+	}
+	return token{kind: tokEOF, line: line}
+}
+
+func (l *lexer) Peek() token {
+	if l.peek == nil {
+		t := l.scan()
+		l.peek = &t
+	}
+	return *l.peek
+}
+
+func (l *lexer) Next() token {
+	t := l.Peek()
+	l.peek = nil
+	return t
+}
+
+func (l *lexer) scan() token {
+	l.skipSpaceAndComments()
+	if l.err != nil || l.pos >= len(l.src) {
+		return token{kind: tokEOF, line: l.line}
+	}
+
+	line := l.line
+	c := l.src[l.pos]
+	switch {
+	case strings.IndexByte("{}()[];", c) >= 0:
+		l.pos++
+		return token{kind: tokPunct, text: string(c), line: line}
+	case c == '"':
+		return l.scanString()
+	case c == '#' && l.pos+1 < len(l.src) && l.src[l.pos+1] == '{':
+		end := strings.Index(l.src[l.pos+2:], "#}")
+		if end < 0 {
+			return l.fail(line, "unterminated #{ block")
+		}
+		text := l.src[l.pos+2 : l.pos+2+end]
+		l.line += strings.Count(text, "\n")
+		l.pos += end + 4
+		return token{kind: tokVerbatim, text: text, line: line}
+	case c == '#':
+		l.pos++
+		return token{kind: tokDirective, text: l.scanWordText(), line: line}
+	case c == '$':
+		l.pos++
+		if l.pos < len(l.src) && l.src[l.pos] == '{' {
+			end := strings.IndexByte(l.src[l.pos:], '}')
+			if end < 0 {
+				return l.fail(line, "unterminated ${")
+			}
+			name := l.src[l.pos+1 : l.pos+end]
+			l.pos += end + 1
+			return token{kind: tokVariable, text: name, line: line}
+		}
+		name := l.scanWordText()
+		if name == "" {
+			return l.fail(line, "$ without a variable name")
+		}
+		return token{kind: tokVariable, text: name, line: line}
+	default:
+		text := l.scanWordText()
+		if text == "" {
+			return l.fail(line, "unexpected character "+string(c))
+		}
+		return token{kind: tokWord, text: text, line: line}
+	}
+}
+
+func (l *lexer) skipSpaceAndComments() {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '\n':
+			l.line++
+			l.pos++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			l.pos++
+		case strings.HasPrefix(l.src[l.pos:], "//"):
+			end := strings.IndexByte(l.src[l.pos:], '\n')
+			if end < 0 {
+				l.pos = len(l.src)
+			} else {
+				l.pos += end
+			}
+		case strings.HasPrefix(l.src[l.pos:], "/*"):
+			end := strings.Index(l.src[l.pos+2:], "*/")
+			if end < 0 {
+				l.fail(l.line, "unterminated comment")
+				l.pos = len(l.src)
+				return
+			}
+			l.line += strings.Count(l.src[l.pos:l.pos+2+end], "\n")
+			l.pos += end + 4
+		default:
+			return
+		}
+	}
+}
+
+func (l *lexer) scanString() token {
+	line := l.line
+	var b strings.Builder
+	for i := l.pos + 1; i < len(l.src); i++ {
+		c := l.src[i]
+		switch c {
+		case '\\':
+			if i+1 < len(l.src) {
+				i++
+				if l.src[i] == '\n' {
+					l.line++
+				} else if l.src[i] != '"' {
+					b.WriteByte('\\')
+				}
+				b.WriteByte(l.src[i])
+			}
+		case '"':
+			l.pos = i + 1
+			return token{kind: tokString, text: b.String(), line: line}
+		case '\n':
+			l.line++
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return l.fail(line, "unterminated string")
+}
+
+// scanWordText reads a word. Parentheses inside a word are kept when they
+// balance; a closing one without an opening one ends the word.
+func (l *lexer) scanWordText() string {
+	start := l.pos
+	depth := 0
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || strings.IndexByte("{}[];\"", c) >= 0 {
+			break
+		}
+		if c == '(' {
+			if l.pos == start || (depth == 0 && isCount(l.src[start:l.pos])) {
+				break
+			}
+			depth++
+		}
+		if c == ')' {
+			if depth == 0 {
+				break
+			}
+			depth--
+		}
+		if c == '/' && l.pos+1 < len(l.src) && (l.src[l.pos+1] == '/' || l.src[l.pos+1] == '*') && depth == 0 {
+			break
+		}
+		l.pos++
+	}
+	return l.src[start:l.pos]
+}
+
+func isCount(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,197 @@
+package openfoam
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDict(t *testing.T) {
+	dict, err := ParseDict("system/fvSchemes", []byte(`
+FoamFile { version 2.0; format ascii; class dictionary; object fvSchemes; }
+
+nu          1e-05;
+solver      "PCG";
+dimensions  [0 2 -1 0 0 0 0];
+points      3((0 0 0) (1 0 0) (1 1 0));
+uniform     3{0};
+convection  Gauss linearUpwind grad(U);
+
+divSchemes
+{
+    default         none;
+    div(phi,U)      $convection;
+}
+"(U|k)Final"    { relTol 0; }
+copy            { $divSchemes; }
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v, ok := dict.Get("nu").Number(); !ok || v != 1e-05 {
+		t.Errorf("nu = %v, %v", v, ok)
+	}
+	if e := dict.Get("solver"); e == nil || e.Values[0].Kind != ValueString || e.Values[0].Text != "PCG" {
+		t.Errorf("solver = %+v", e)
+	}
+	if e := dict.Get("dimensions"); e == nil || e.Values[0].Kind != ValueDimensions || len(e.Values[0].Items) != 7 {
+		t.Errorf("dimensions = %+v", e)
+	}
+	if e := dict.Get("points"); e == nil || e.Values[0].Count != 3 || len(e.Values[0].Items) != 3 {
+		t.Errorf("points = %+v", e)
+	}
+	if e := dict.Get("uniform"); e == nil || e.Values[0].Count != 3 || len(e.Values[0].Items) != 1 {
+		t.Errorf("uniform = %+v", e)
+	}
+	if e := dict.Sub("divSchemes").Get("div(phi,U)"); e == nil || len(e.Values) != 3 || e.Values[2].Text != "grad(U)" {
+		t.Errorf("div(phi,U) = %+v", e)
+	}
+	if e := dict.Get("UFinal"); e == nil || e.Key != "(U|k)Final" {
+		t.Errorf("UFinal matched %+v", e)
+	}
+	if w, ok := dict.Sub("copy").Get("default").Word(); !ok || w != "none" {
+		t.Errorf("copy.default = %q, %v", w, ok)
+	}
+}
+
+func TestParseDictErrors(t *testing.T) {
+	// doubling defines n macros that each repeat the previous one twice.
+	doubling := func(n int) string {
+		var b strings.Builder
+		b.WriteString("v0 1;\n")
+		for i := 1; i <= n; i++ {
+			fmt.Fprintf(&b, "v%d $v%d $v%d;\n", i, i-1, i-1)
+		}
+		return b.String()
+	}
+	// doublingDicts does the same with sub-dictionaries.
+	doublingDicts := func(n int) string {
+		var b strings.Builder
+		b.WriteString("d0 { a 1; }\n")
+		for i := 1; i <= n; i++ {
+			fmt.Fprintf(&b, "d%d { a { $d%d; } b { $d%d; } }\n", i, i-1, i-1)
+		}
+		return b.String()
+	}
+
+	tests := []struct {
+		name string
+		text string
+		line int
+		msg  string // part of the message; empty accepts the text
+	}{
+		{name: "missing semicolon", text: "a 1;\nb 2\n}", line: 3, msg: "missing a ;"},
+		{name: "undefined variable", text: "a 1;\nb $c;", line: 2, msg: "undefined variable $c"},
+		{name: "unclosed dictionary", text: "a {\n b 1;\n", line: 3, msg: "missing }"},
+		{name: "count mismatch", text: "a\n3(1 2);", line: 2, msg: "declares 3 items but has 2"},
+		{name: "few macro expansions", text: doubling(10)},
+		{name: "exponential macros", text: doubling(64), line: 17, msg: "expands the file past"},
+		{name: "exponential dictionary macros", text: doublingDicts(64), msg: "expands the file past"},
+		{name: "nesting at the limit", text: "a " + strings.Repeat("(", maxNesting) + strings.Repeat(")", maxNesting) + ";"},
+		{name: "deeply nested lists", text: "a\n" + strings.Repeat("(", 100000) + ";", line: 2, msg: "nested more than 256 deep"},
+		{name: "deeply nested dictionaries", text: strings.Repeat("a {\n", 100000), line: maxNesting + 1, msg: "nested more than 256 deep"},
+		{name: "deeply nested list dictionaries", text: "a " + strings.Repeat("( b {", 100000), line: 1, msg: "nested more than 256 deep"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseDict("system/controlDict", []byte(tt.text))
+			if tt.msg == "" {
+				if err != nil {
+					t.Fatalf("err = %v", err)
+				}
+				return
+			}
+			var e *Error
+			if !errors.As(err, &e) {
+				t.Fatalf("err = %v, want an *Error", err)
+			}
+			if !strings.Contains(e.Msg, tt.msg) {
+				t.Errorf("err = %v, want %q", err, tt.msg)
+			}
+			if tt.line > 0 && e.Line != tt.line {
+				t.Errorf("err = %v, want line %d", err, tt.line)
+			}
+		})
+	}
+}
+
+func TestParseFileInclude(t *testing.T) {
+	dir := t.TempDir()
+	write := func(rel, text string) {
+		t.Helper()
+		path := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("system/controlDict", "#include \"common\"\n#includeIfPresent \"missing\"\nendTime 10;\n")
+	write("system/common", "deltaT 0.1;\n")
+	write("system/loop", "#include \"loop\"\n")
+	write("system/escape", "#include \"../../outside\"\n")
+
+	dict, err := ParseFile(dir, "system/controlDict")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := dict.Get("deltaT").Number(); !ok || v != 0.1 {
+		t.Errorf("deltaT = %v, %v", v, ok)
+	}
+
+	if _, err := ParseFile(dir, "system/loop"); err == nil || !strings.Contains(err.Error(), "nested too deeply") {
+		t.Errorf("include cycle: %v", err)
+	}
+	if _, err := ParseFile(dir, "system/escape"); err == nil || !strings.Contains(err.Error(), "outside the case") {
+		t.Errorf("include outside the case: %v", err)
+	}
+
+	if err := os.Symlink("/etc/passwd", filepath.Join(dir, "system", "link")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseFile(dir, "system/link"); err == nil || !strings.Contains(err.Error(), "leaves the case") {
+		t.Errorf("symlink out of the case: %v", err)
+	}
+}
+
+// Files that include the next one many times would be parsed
+// 30^maxIncludeDepth times without a budget shared across the includes.
+func TestParseFileIncludeFanOut(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < maxIncludeDepth; i++ {
+		text := strings.Repeat(fmt.Sprintf("#include \"level%d\"\n", i+1), 30)
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("level%d", i)), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("level%d", maxIncludeDepth)), []byte("a 1;\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	_, err := ParseFile(dir, "level0")
+	if err == nil || !strings.Contains(err.Error(), "more than 200 files to read") {
+		t.Errorf("err = %v, want the include budget exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("rejecting the case took %v", elapsed)
+	}
+
+	// A large file included repeatedly runs out of bytes instead.
+	big := "// " + strings.Repeat("x", 32<<20) + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "big"), []byte(big), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "bigs"), []byte(strings.Repeat("#include \"big\"\n", 10)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseFile(dir, "bigs"); err == nil || !strings.Contains(err.Error(), "MiB to read") {
+		t.Errorf("err = %v, want the byte budget exceeded", err)
+	}
+}
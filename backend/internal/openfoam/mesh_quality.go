@@ -0,0 +1,329 @@
+package openfoam
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// MeshReportFile is where the mesh report is kept in a simulation's
+// results directory.
+const MeshReportFile = "mesh-report.json"
+
+// Limits above which checkMesh reports a failed mesh check.
+const (
+	maxNonOrthogonality = 70   // degrees
+	maxSkewness         = 4    // checkMesh's default
+	maxAspectRatio      = 1000 // checkMesh's default
+	closedTolerance     = 1e-6 // relative, for open cells
+)
+
+// MeshReport summarises the size and quality of a polyMesh, along the lines
+// of OpenFOAM's checkMesh.
+type MeshReport struct {
+	Points        int
+	Faces         int
+	InternalFaces int
+	Cells         int
+	Patches       int
+
+	BoundsMin [3]Float
+	BoundsMax [3]Float
+
+	TotalVolume Float
+	MinVolume   Float
+	MaxVolume   Float
+
+	MaxNonOrthogonality  Float // degrees
+	AvgNonOrthogonality  Float // degrees
+	NonOrthogonalFaces   int   // above 70 degrees
+	MaxSkewness          Float
+	SkewFaces            int // above 4
+	MaxAspectRatio       Float
+	HighAspectRatioCells int // above 1000
+	OpenCells            int
+	NegativeVolumeCells  int
+	FailedChecks         []string
+}
+
+// OK reports whether the mesh passed every check.
+func (r *MeshReport) OK() bool { return len(r.FailedChecks) == 0 }
+
+// meshGeometry holds the face and cell centres, area vectors and volumes,
+// computed the way OpenFOAM's primitiveMesh does.
+type meshGeometry struct {
+	faceCentres [][3]float64
+	faceAreas   [][3]float64
+	cellCentres [][3]float64
+	cellVolumes []float64
+}
+
+// AnalyzeMesh computes the report for mesh.
+func AnalyzeMesh(mesh *PolyMesh) *MeshReport {
+	report := &MeshReport{
+		Points:        len(mesh.Points),
+		Faces:         mesh.NumFaces(),
+		InternalFaces: len(mesh.Neighbour),
+		Cells:         mesh.Cells,
+	}
+	if len(mesh.Points) == 0 || mesh.Cells == 0 {
+		report.FailedChecks = append(report.FailedChecks, "mesh has no cells")
+		return report
+	}
+
+	lo, hi := mesh.Points[0], mesh.Points[0]
+	for _, p := range mesh.Points {
+		for j := range p {
+			lo[j] = math.Min(lo[j], p[j])
+			hi[j] = math.Max(hi[j], p[j])
+		}
+	}
+	for j := range lo {
+		report.BoundsMin[j], report.BoundsMax[j] = Float(lo[j]), Float(hi[j])
+	}
+
+	geo := computeGeometry(mesh)
+	report.cellChecks(mesh, geo)
+	report.faceChecks(mesh, geo)
+
+	if report.OpenCells > 0 {
+		report.FailedChecks = append(report.FailedChecks, "open cells")
+	}
+	if report.NegativeVolumeCells > 0 {
+		report.FailedChecks = append(report.FailedChecks, "zero or negative cell volumes")
+	}
+	if report.NonOrthogonalFaces > 0 {
+		report.FailedChecks = append(report.FailedChecks, "severely non-orthogonal faces")
+	}
+	if report.SkewFaces > 0 {
+		report.FailedChecks = append(report.FailedChecks, "highly skewed faces")
+	}
+	if report.HighAspectRatioCells > 0 {
+		report.FailedChecks = append(report.FailedChecks, "high aspect ratio cells")
+	}
+	return report
+}
+
+func computeGeometry(mesh *PolyMesh) *meshGeometry {
+	faces := mesh.NumFaces()
+	geo := &meshGeometry{
+		faceCentres: make([][3]float64, faces),
+		faceAreas:   make([][3]float64, faces),
+		cellCentres: make([][3]float64, mesh.Cells),
+		cellVolumes: make([]float64, mesh.Cells),
+	}
+
+	// Faces are split into triangles around the average of their points.
+	for i := 0; i < faces; i++ {
+		face := mesh.Face(i)
+		var centre [3]float64
+		for _, p := range face {
+			centre = add(centre, mesh.Points[p])
+		}
+		centre = scale(centre, 1/float64(len(face)))
+
+		var area, weighted [3]float64
+		sumMag := 0.0
+		for j, p := range face {
+			a, b := mesh.Points[p], mesh.Points[face[(j+1)%len(face)]]
+			n := cross(sub(a, centre), sub(b, centre))
+			mag := norm(n)
+			area = add(area, n)
+			weighted = add(weighted, scale(add(add(a, b), centre), mag/3))
+			sumMag += mag
+		}
+		geo.faceAreas[i] = scale(area, 0.5)
+		if sumMag > 0 {
+			geo.faceCentres[i] = scale(weighted, 1/sumMag)
+		} else {
+			geo.faceCentres[i] = centre
+		}
+	}
+
+	// Cells are split into pyramids from their faces to the average of
+	// their face centres.
+	estimate := make([][3]float64, mesh.Cells)
+	count := make([]int, mesh.Cells)
+	for i := 0; i < faces; i++ {
+		c := mesh.Owner[i]
+		estimate[c] = add(estimate[c], geo.faceCentres[i])
+		count[c]++
+		if i < len(mesh.Neighbour) {
+			c = mesh.Neighbour[i]
+			estimate[c] = add(estimate[c], geo.faceCentres[i])
+			count[c]++
+		}
+	}
+	for c := range estimate {
+		if count[c] > 0 {
+			estimate[c] = scale(estimate[c], 1/float64(count[c]))
+		}
+	}
+
+	weighted := make([][3]float64, mesh.Cells)
+	pyramid := func(c int32, face int, sign float64) {
+		vol3 := sign * dot(geo.faceAreas[face], sub(geo.faceCentres[face], estimate[c]))
+		centre := add(scale(geo.faceCentres[face], 0.75), scale(estimate[c], 0.25))
+		geo.cellVolumes[c] += vol3
+		weighted[c] = add(weighted[c], scale(centre, vol3))
+	}
+	for i := 0; i < faces; i++ {
+		pyramid(mesh.Owner[i], i, 1)
+		if i < len(mesh.Neighbour) {
+			pyramid(mesh.Neighbour[i], i, -1)
+		}
+	}
+	for c := range geo.cellVolumes {
+		if math.Abs(geo.cellVolumes[c]) > 1e-300 {
+			geo.cellCentres[c] = scale(weighted[c], 1/geo.cellVolumes[c])
+		} else {
+			geo.cellCentres[c] = estimate[c]
+		}
+		geo.cellVolumes[c] /= 3
+	}
+	return geo
+}
+
+// cellChecks covers volumes, closedness and aspect ratio.
+func (r *MeshReport) cellChecks(mesh *PolyMesh, geo *meshGeometry) {
+	sumClosed := make([][3]float64, mesh.Cells)
+	sumMagClosed := make([][3]float64, mesh.Cells)
+	for i := 0; i < mesh.NumFaces(); i++ {
+		area := geo.faceAreas[i]
+		o := mesh.Owner[i]
+		sumClosed[o] = add(sumClosed[o], area)
+		sumMagClosed[o] = add(sumMagClosed[o], abs(area))
+		if i < len(mesh.Neighbour) {
+			n := mesh.Neighbour[i]
+			sumClosed[n] = sub(sumClosed[n], area)
+			sumMagClosed[n] = add(sumMagClosed[n], abs(area))
+		}
+	}
+
+	r.MinVolume, r.MaxVolume = Float(math.Inf(1)), Float(math.Inf(-1))
+	for c, vol := range geo.cellVolumes {
+		r.TotalVolume += Float(vol)
+		r.MinVolume = Float(math.Min(float64(r.MinVolume), vol))
+		r.MaxVolume = Float(math.Max(float64(r.MaxVolume), vol))
+		if vol <= 0 {
+			r.NegativeVolumeCells++
+			continue
+		}
+
+		magClosed := sumMagClosed[c]
+		openness := 0.0
+		for j := range magClosed {
+			if magClosed[j] > 0 {
+				openness = math.Max(openness, math.Abs(sumClosed[c][j])/magClosed[j])
+			}
+		}
+		if openness > closedTolerance {
+			r.OpenCells++
+		}
+
+		// The larger of the area-to-volume ratio, normalised to 1 for a
+		// cube, and the ratio of the largest to the smallest projected area.
+		aspect := (magClosed[0] + magClosed[1] + magClosed[2]) / (6 * math.Pow(vol, 2.0/3.0))
+		lo := math.Min(magClosed[0], math.Min(magClosed[1], magClosed[2]))
+		hi := math.Max(magClosed[0], math.Max(magClosed[1], magClosed[2]))
+		if lo > 0 {
+			aspect = math.Max(aspect, hi/lo)
+		}
+		r.MaxAspectRatio = Float(math.Max(float64(r.MaxAspectRatio), aspect))
+		if aspect > maxAspectRatio {
+			r.HighAspectRatioCells++
+		}
+	}
+}
+
+// faceChecks covers non-orthogonality and skewness of internal faces.
+func (r *MeshReport) faceChecks(mesh *PolyMesh, geo *meshGeometry) {
+	sumNonOrth := 0.0
+	for i, n := range mesh.Neighbour {
+		o := mesh.Owner[i]
+		d := sub(geo.cellCentres[n], geo.cellCentres[o])
+		area := geo.faceAreas[i]
+		magD, magA := norm(d), norm(area)
+		if magD == 0 || magA == 0 {
+			continue
+		}
+
+		cos := math.Max(-1, math.Min(1, dot(d, area)/(magD*magA)))
+		angle := math.Acos(cos) * 180 / math.Pi
+		sumNonOrth += angle
+		r.MaxNonOrthogonality = Float(math.Max(float64(r.MaxNonOrthogonality), angle))
+		if angle > maxNonOrthogonality {
+			r.NonOrthogonalFaces++
+		}
+
+		// Distance from the face centre to where the line between the cell
+		// centres crosses the face, relative to the distance between them.
+		denom := dot(area, d)
+		if denom == 0 {
+			continue
+		}
+		cpf := sub(geo.faceCentres[i], geo.cellCentres[o])
+		intersection := add(geo.cellCentres[o], scale(d, dot(area, cpf)/denom))
+		skew := norm(sub(geo.faceCentres[i], intersection)) / magD
+		r.MaxSkewness = Float(math.Max(float64(r.MaxSkewness), skew))
+		if skew > maxSkewness {
+			r.SkewFaces++
+		}
+	}
+	if len(mesh.Neighbour) > 0 {
+		r.AvgNonOrthogonality = Float(sumNonOrth / float64(len(mesh.Neighbour)))
+	}
+}
+
+// AnalyzeCaseMesh reads the polyMesh of caseDir and reports on it.
+func AnalyzeCaseMesh(caseDir string) (*MeshReport, error) {
+	mesh, err := ReadPolyMesh(caseDir)
+	if err != nil {
+		return nil, err
+	}
+	report := AnalyzeMesh(mesh)
+	if patches, err := ReadPatches(caseDir); err == nil {
+		report.Patches = len(patches)
+	}
+	return report, nil
+}
+
+// WriteMeshReport stores report in dir.
+func WriteMeshReport(dir string, report *MeshReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, MeshReportFile), data, 0644)
+}
+
+// ReadMeshReport loads the report stored in dir.
+func ReadMeshReport(dir string) (*MeshReport, error) {
+	data, err := os.ReadFile(filepath.Join(dir, MeshReportFile))
+	if err != nil {
+		return nil, err
+	}
+	report := &MeshReport{}
+	if err := json.Unmarshal(data, report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func add(a, b [3]float64) [3]float64 { return [3]float64{a[0] + b[0], a[1] + b[1], a[2] + b[2]} }
+func sub(a, b [3]float64) [3]float64 { return [3]float64{a[0] - b[0], a[1] - b[1], a[2] - b[2]} }
+func scale(a [3]float64, s float64) [3]float64 {
+	return [3]float64{a[0] * s, a[1] * s, a[2] * s}
+}
+func dot(a, b [3]float64) float64 { return a[0]*b[0] + a[1]*b[1] + a[2]*b[2] }
+func norm(a [3]float64) float64   { return math.Sqrt(dot(a, a)) }
+func abs(a [3]float64) [3]float64 {
+	return [3]float64{math.Abs(a[0]), math.Abs(a[1]), math.Abs(a[2])}
+}
+func cross(a, b [3]float64) [3]float64 {
+	return [3]float64{a[1]*b[2] - a[2]*b[1], a[2]*b[0] - a[0]*b[2], a[0]*b[1] - a[1]*b[0]}
+}
@@ -0,0 +1,570 @@
+package openfoam
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/theweirdfulmurk/cfd-platform/internal/casefile"
+)
+
+// PolyMeshDir is where a case keeps its mesh.
+const PolyMeshDir = "constant/polyMesh"
+
+// PolyMesh is the face-based mesh of constant/polyMesh. Faces are stored
+// flat: face i has the points FaceLabels[FaceOffsets[i]:FaceOffsets[i+1]].
+type PolyMesh struct {
+	Points      [][3]float64
+	FaceOffsets []int
+	FaceLabels  []int32
+	Owner       []int32
+	Neighbour   []int32
+	Cells       int
+}
+
+// NumFaces returns the number of faces.
+func (m *PolyMesh) NumFaces() int { return len(m.FaceOffsets) - 1 }
+
+// Face returns the point labels of face i.
+func (m *PolyMesh) Face(i int) []int32 {
+	return m.FaceLabels[m.FaceOffsets[i]:m.FaceOffsets[i+1]]
+}
+
+// HasPolyMesh reports whether caseDir ships a mesh.
+func HasPolyMesh(caseDir string) bool {
+	_, err := os.Stat(filepath.Join(caseDir, PolyMeshDir, "faces"))
+	if err != nil {
+		_, err = os.Stat(filepath.Join(caseDir, PolyMeshDir, "faces.gz"))
+	}
+	return err == nil
+}
+
+// ReadPolyMesh reads points, faces, owner and neighbour, ASCII or binary
+// and optionally gzipped, from constant/polyMesh of caseDir.
+func ReadPolyMesh(caseDir string) (*PolyMesh, error) {
+	mesh := &PolyMesh{}
+
+	err := readMeshFile(caseDir, "points", func(l *listReader) (err error) {
+		mesh.Points, err = l.vectors()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = readMeshFile(caseDir, "faces", func(l *listReader) (err error) {
+		mesh.FaceOffsets, mesh.FaceLabels, err = l.faces()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = readMeshFile(caseDir, "owner", func(l *listReader) (err error) {
+		mesh.Owner, err = l.labels()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = readMeshFile(caseDir, "neighbour", func(l *listReader) (err error) {
+		mesh.Neighbour, err = l.labels()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := mesh.check(); err != nil {
+		return nil, err
+	}
+	return mesh, nil
+}
+
+// check makes sure the labels are in range, so the analysis can index
+// without bounds checks failing.
+func (m *PolyMesh) check() error {
+	faces := m.NumFaces()
+	if len(m.Owner) != faces {
+		return &Error{File: PolyMeshDir + "/owner", Msg: fmt.Sprintf("%d owners for %d faces", len(m.Owner), faces)}
+	}
+	if len(m.Neighbour) > faces {
+		return &Error{File: PolyMeshDir + "/neighbour", Msg: fmt.Sprintf("%d neighbours for %d faces", len(m.Neighbour), faces)}
+	}
+	for _, label := range m.FaceLabels {
+		if label < 0 || int(label) >= len(m.Points) {
+			return &Error{File: PolyMeshDir + "/faces", Msg: fmt.Sprintf("point label %d out of range", label)}
+		}
+	}
+	for i := 0; i < faces; i++ {
+		if m.FaceOffsets[i+1]-m.FaceOffsets[i] < 3 {
+			return &Error{File: PolyMeshDir + "/faces", Msg: fmt.Sprintf("face %d has fewer than 3 points", i)}
+		}
+	}
+	for _, cells := range [][]int32{m.Owner, m.Neighbour} {
+		for _, c := range cells {
+			if c < 0 {
+				return &Error{File: PolyMeshDir + "/owner", Msg: fmt.Sprintf("negative cell label %d", c)}
+			}
+			m.Cells = max(m.Cells, int(c)+1)
+		}
+	}
+	return nil
+}
+
+func readMeshFile(caseDir, name string, read func(*listReader) error) error {
+	rel := PolyMeshDir + "/" + name
+	f, err := casefile.Open(caseDir, rel)
+	var r io.Reader = f
+	compressed := false
+	if errors.Is(err, fs.ErrNotExist) {
+		rel += ".gz"
+		if f, err = casefile.Open(caseDir, rel); err == nil {
+			gz, gzErr := gzip.NewReader(f)
+			if gzErr != nil {
+				f.Close()
+				return &Error{File: rel, Msg: gzErr.Error()}
+			}
+			r = gz
+			compressed = true
+		}
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Error{File: rel, Msg: "missing"}
+	}
+	if err != nil {
+		return &Error{File: rel, Msg: err.Error()}
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return &Error{File: rel, Msg: err.Error()}
+	}
+	size := info.Size()
+	if compressed {
+		size *= maxDeflateRatio
+	}
+
+	l := &listReader{r: bufio.NewReaderSize(r, 1<<16), file: rel, line: 1, size: size, labelBytes: 4, scalarBytes: 8}
+	if err := l.header(); err != nil {
+		return err
+	}
+	if err := read(l); err != nil {
+		if _, ok := err.(*Error); ok {
+			return err
+		}
+		return l.errorf("%v", err)
+	}
+	return nil
+}
+
+// maxPrealloc keeps a bogus list size in a file header from allocating
+// more than the data it actually holds.
+const maxPrealloc = 1 << 20
+
+// maxDeflateRatio is the most gzip can shrink its input, bounding what a
+// compressed mesh file can hold.
+const maxDeflateRatio = 1032
+
+// listReader reads the single list that follows the FoamFile header of a
+// mesh file.
+type listReader struct {
+	r           *bufio.Reader
+	file        string
+	line        int
+	size        int64 // bytes of list data the file can hold, at most
+	class       string
+	binary      bool
+	labelBytes  int
+	scalarBytes int
+}
+
+func (l *listReader) errorf(format string, args ...any) error {
+	return &Error{File: l.file, Line: l.line, Msg: fmt.Sprintf(format, args...)}
+}
+
+// skipSpace skips whitespace and comments.
+func (l *listReader) skipSpace() error {
+	for {
+		c, err := l.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		switch {
+		case c == '\n':
+			l.line++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+		case c == '/':
+			next, err := l.r.ReadByte()
+			if err != nil {
+				return err
+			}
+			switch next {
+			case '/':
+				rest, err := l.r.ReadString('\n')
+				l.line += strings.Count(rest, "\n")
+				if err != nil {
+					return err
+				}
+			case '*':
+				if err := l.skipBlockComment(); err != nil {
+					return err
+				}
+			default:
+				return l.errorf("unexpected /")
+			}
+		default:
+			return l.r.UnreadByte()
+		}
+	}
+}
+
+func (l *listReader) skipBlockComment() error {
+	star := false
+	for {
+		c, err := l.r.ReadByte()
+		if err != nil {
+			return l.errorf("unterminated comment")
+		}
+		if c == '\n' {
+			l.line++
+		}
+		if star && c == '/' {
+			return nil
+		}
+		star = c == '*'
+	}
+}
+
+// endOfFile turns io.EOF from skipSpace into a positioned error.
+func (l *listReader) endOfFile(err error) error {
+	if err == io.EOF {
+		return l.errorf("unexpected end of file")
+	}
+	return err
+}
+
+// word reads a number or keyword.
+func (l *listReader) word() (string, error) {
+	if err := l.skipSpace(); err != nil {
+		return "", l.endOfFile(err)
+	}
+	var b strings.Builder
+	for {
+		c, err := l.r.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || strings.IndexByte("(){};", c) >= 0 {
+			l.r.UnreadByte()
+			break
+		}
+		b.WriteByte(c)
+	}
+	if b.Len() == 0 {
+		c, _ := l.r.ReadByte()
+		return "", l.errorf("unexpected %q", c)
+	}
+	return b.String(), nil
+}
+
+func (l *listReader) expect(c byte) error {
+	if err := l.skipSpace(); err != nil {
+		return l.endOfFile(err)
+	}
+	got, _ := l.r.ReadByte()
+	if got != c {
+		return l.errorf("expected %q, got %q", c, got)
+	}
+	return nil
+}
+
+// header reads the FoamFile dictionary for the format, class and, for
+// binary files, the label and scalar sizes.
+func (l *listReader) header() error {
+	key, err := l.word()
+	if err != nil || key != "FoamFile" {
+		return l.errorf("missing FoamFile header")
+	}
+	start := l.line
+	if err := l.expect('{'); err != nil {
+		return err
+	}
+	body, err := l.r.ReadString('}')
+	if err != nil {
+		return l.errorf("unterminated FoamFile header")
+	}
+	l.line += strings.Count(body, "\n")
+
+	header, err := ParseDict(l.file, []byte(strings.Repeat("\n", start-1)+"FoamFile {"+body))
+	if err != nil {
+		return err
+	}
+	fields := header.Sub("FoamFile")
+	l.class, _ = fields.Get("class").Word()
+	format, _ := fields.Get("format").Word()
+	l.binary = format == "binary"
+	if e := fields.Get("arch"); e != nil && len(e.Values) == 1 {
+		for _, part := range strings.Split(e.Values[0].Text, ";") {
+			key, value, _ := strings.Cut(part, "=")
+			bits, err := strconv.Atoi(value)
+			if err != nil {
+				continue
+			}
+			switch key {
+			case "label":
+				l.labelBytes = bits / 8
+			case "scalar":
+				l.scalarBytes = bits / 8
+			}
+		}
+	}
+	if l.labelBytes != 4 && l.labelBytes != 8 || l.scalarBytes != 4 && l.scalarBytes != 8 {
+		return l.errorf("unsupported arch %d-bit labels, %d-bit scalars", l.labelBytes*8, l.scalarBytes*8)
+	}
+	return nil
+}
+
+// count reads the size that starts a list, and the opening bracket.
+func (l *listReader) count() (int, byte, error) {
+	w, err := l.word()
+	if err != nil {
+		return 0, 0, err
+	}
+	n, err := strconv.Atoi(w)
+	if err != nil || n < 0 {
+		return 0, 0, l.errorf("expected a list size, got %q", w)
+	}
+	// Lists are expanded in memory, so one that would take more than a
+	// byte per item written out is rejected. That bounds uniform N{v}
+	// lists, which otherwise take N from the file alone.
+	if int64(n) > l.size {
+		return 0, 0, l.errorf("list size %d is more than the file can hold", n)
+	}
+	if err := l.skipSpace(); err != nil {
+		return 0, 0, l.endOfFile(err)
+	}
+	open, _ := l.r.ReadByte()
+	if open != '(' && open != '{' {
+		return 0, 0, l.errorf("expected ( after the list size, got %q", open)
+	}
+	return n, open, nil
+}
+
+func (l *listReader) number() (float64, error) {
+	w, err := l.word()
+	if err != nil {
+		return 0, err
+	}
+	f, err := strconv.ParseFloat(w, 64)
+	if err != nil {
+		return 0, l.errorf("expected a number, got %q", w)
+	}
+	return f, nil
+}
+
+func (l *listReader) label() (int32, error) {
+	w, err := l.word()
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(w, 10, 32)
+	if err != nil {
+		return 0, l.errorf("expected a label, got %q", w)
+	}
+	return int32(n), nil
+}
+
+func (l *listReader) binaryBlock(n, size int) ([]byte, error) {
+	if n > math.MaxInt/size || int64(n*size) > l.size {
+		return nil, l.errorf("binary list of %d items is more than the file can hold", n)
+	}
+	data := make([]byte, 0, min(n*size, maxPrealloc))
+	buf := make([]byte, 1<<16)
+	for remaining := n * size; remaining > 0; {
+		chunk := buf[:min(remaining, len(buf))]
+		if _, err := io.ReadFull(l.r, chunk); err != nil {
+			return nil, l.errorf("binary list ends early")
+		}
+		data = append(data, chunk...)
+		remaining -= len(chunk)
+	}
+	if c, err := l.r.ReadByte(); err != nil || c != ')' {
+		return nil, l.errorf("binary list does not end with )")
+	}
+	return data, nil
+}
+
+func (l *listReader) decodeLabel(b []byte) int32 {
+	if l.labelBytes == 8 {
+		return int32(binary.LittleEndian.Uint64(b))
+	}
+	return int32(binary.LittleEndian.Uint32(b))
+}
+
+func (l *listReader) decodeScalar(b []byte) float64 {
+	if l.scalarBytes == 4 {
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(b))
+}
+
+func (l *listReader) vectors() ([][3]float64, error) {
+	n, open, err := l.count()
+	if err != nil {
+		return nil, err
+	}
+	if open == '{' {
+		return nil, l.errorf("uniform point lists are not supported")
+	}
+
+	points := make([][3]float64, 0, min(n, maxPrealloc))
+	if l.binary {
+		data, err := l.binaryBlock(n, 3*l.scalarBytes)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < n; i++ {
+			var p [3]float64
+			for j := range p {
+				off := (3*i + j) * l.scalarBytes
+				p[j] = l.decodeScalar(data[off:])
+			}
+			points = append(points, p)
+		}
+		return points, nil
+	}
+
+	for i := 0; i < n; i++ {
+		if err := l.expect('('); err != nil {
+			return nil, err
+		}
+		var p [3]float64
+		for j := range p {
+			if p[j], err = l.number(); err != nil {
+				return nil, err
+			}
+		}
+		if err := l.expect(')'); err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+	return points, l.expect(')')
+}
+
+func (l *listReader) labels() ([]int32, error) {
+	n, open, err := l.count()
+	if err != nil {
+		return nil, err
+	}
+	labels := make([]int32, 0, min(n, maxPrealloc))
+
+	switch {
+	case open == '{':
+		var v int32
+		if l.binary {
+			b := make([]byte, l.labelBytes)
+			if _, err := io.ReadFull(l.r, b); err != nil {
+				return nil, l.errorf("binary list ends early")
+			}
+			v = l.decodeLabel(b)
+		} else if v, err = l.label(); err != nil {
+			return nil, err
+		}
+		for i := 0; i < n; i++ {
+			labels = append(labels, v)
+		}
+		return labels, l.expect('}')
+	case l.binary:
+		data, err := l.binaryBlock(n, l.labelBytes)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < n; i++ {
+			labels = append(labels, l.decodeLabel(data[i*l.labelBytes:]))
+		}
+		return labels, nil
+	}
+
+	for i := 0; i < n; i++ {
+		v, err := l.label()
+		if err != nil {
+			return nil, err
+		}
+		labels = append(labels, v)
+	}
+	return labels, l.expect(')')
+}
+
+// faces reads a faceList, "N(3(a b c) 4(a b c d) ...)", or the
+// faceCompactList OpenFOAM writes in binary: a list of offsets followed by
+// a list of point labels.
+func (l *listReader) faces() ([]int, []int32, error) {
+	if l.class == "faceCompactList" {
+		offsets32, err := l.labels()
+		if err != nil {
+			return nil, nil, err
+		}
+		labels, err := l.labels()
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(offsets32) == 0 {
+			return []int{0}, nil, nil
+		}
+		offsets := make([]int, len(offsets32))
+		for i, o := range offsets32 {
+			offsets[i] = int(o)
+			if o < 0 || int(o) > len(labels) || i > 0 && offsets[i] < offsets[i-1] {
+				return nil, nil, l.errorf("face offsets are not increasing")
+			}
+		}
+		return offsets, labels, nil
+	}
+
+	if l.binary {
+		return nil, nil, l.errorf("binary faces must be a faceCompactList")
+	}
+	n, open, err := l.count()
+	if err != nil {
+		return nil, nil, err
+	}
+	if open == '{' {
+		return nil, nil, l.errorf("uniform face lists are not supported")
+	}
+
+	offsets := make([]int, 1, min(n+1, maxPrealloc))
+	labels := make([]int32, 0, min(4*n, maxPrealloc))
+	for i := 0; i < n; i++ {
+		k, open, err := l.count()
+		if err != nil {
+			return nil, nil, err
+		}
+		if open != '(' {
+			return nil, nil, l.errorf("expected ( after the face size")
+		}
+		for j := 0; j < k; j++ {
+			v, err := l.label()
+			if err != nil {
+				return nil, nil, err
+			}
+			labels = append(labels, v)
+		}
+		if err := l.expect(')'); err != nil {
+			return nil, nil, err
+		}
+		offsets = append(offsets, len(labels))
+	}
+	return offsets, labels, l.expect(')')
+}
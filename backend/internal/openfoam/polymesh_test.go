@@ -0,0 +1,140 @@
+package openfoam
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func meshHeader(format, class, object string) string {
+	return fmt.Sprintf("FoamFile\n{\n    version 2.0;\n    format %s;\n    arch \"LSB;label=32;scalar=64\";\n    class %s;\n    object %s;\n}\n", format, class, object)
+}
+
+var cubePoints = [][3]float64{{0, 0, 0}, {1, 0, 0}, {1, 1, 0}, {0, 1, 0}, {0, 0, 1}, {1, 0, 1}, {1, 1, 1}, {0, 1, 1}}
+
+// cube is a single hexahedral cell with its faces pointing outwards.
+var cube = map[string]string{
+	"points": meshHeader("ascii", "vectorField", "points") + "8\n(\n(0 0 0) (1 0 0) (1 1 0) (0 1 0)\n(0 0 1) (1 0 1) (1 1 1) (0 1 1)\n)\n",
+	"faces": meshHeader("ascii", "faceList", "faces") +
+		"// bottom, top, front, back, left, right\n6\n(\n4(0 3 2 1)\n4(4 5 6 7)\n4(0 1 5 4)\n4(3 7 6 2)\n4(0 4 7 3)\n4(1 2 6 5)\n)\n",
+	"owner":     meshHeader("ascii", "labelList", "owner") + "6{0}\n",
+	"neighbour": meshHeader("ascii", "labelList", "neighbour") + "0()\n",
+}
+
+func binaryPoints(count int, points [][3]float64) string {
+	var b bytes.Buffer
+	b.WriteString(meshHeader("binary", "vectorField", "points"))
+	fmt.Fprintf(&b, "%d\n(", count)
+	for _, p := range points {
+		for _, v := range p {
+			binary.Write(&b, binary.LittleEndian, math.Float64bits(v))
+		}
+	}
+	b.WriteString(")\n")
+	return b.String()
+}
+
+func writeMesh(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	meshDir := filepath.Join(dir, PolyMeshDir)
+	if err := os.MkdirAll(meshDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, text := range files {
+		data := []byte(text)
+		if strings.HasSuffix(name, ".gz") {
+			var b bytes.Buffer
+			gz := gzip.NewWriter(&b)
+			gz.Write(data)
+			gz.Close()
+			data = b.Bytes()
+		}
+		if err := os.WriteFile(filepath.Join(meshDir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// with returns cube with some files replaced; an empty text removes one.
+func with(replace map[string]string) map[string]string {
+	files := make(map[string]string)
+	for name, text := range cube {
+		files[name] = text
+	}
+	for name, text := range replace {
+		delete(files, name)
+		if text != "" {
+			files[name] = text
+		}
+	}
+	return files
+}
+
+func TestReadPolyMesh(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+	}{
+		{name: "ascii", files: cube},
+		{name: "gzipped", files: with(map[string]string{"faces": "", "faces.gz": cube["faces"]})},
+		{name: "binary", files: with(map[string]string{"points": binaryPoints(8, cubePoints)})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mesh, err := ReadPolyMesh(writeMesh(t, tt.files))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if mesh.Cells != 1 || mesh.NumFaces() != 6 || len(mesh.Points) != 8 {
+				t.Fatalf("mesh has %d cells, %d faces, %d points", mesh.Cells, mesh.NumFaces(), len(mesh.Points))
+			}
+			report := AnalyzeMesh(mesh)
+			if !report.OK() || math.Abs(float64(report.TotalVolume)-1) > 1e-12 {
+				t.Errorf("report = %+v", report)
+			}
+		})
+	}
+}
+
+func TestReadPolyMeshErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		msg   string
+	}{
+		{name: "missing file", files: with(map[string]string{"owner": ""}), msg: "constant/polyMesh/owner.gz: missing"},
+		{name: "no header", files: with(map[string]string{"owner": "6{0}\n"}), msg: "missing FoamFile header"},
+		{name: "too few owners", files: with(map[string]string{"owner": meshHeader("ascii", "labelList", "owner") + "5{0}\n"}), msg: "5 owners for 6 faces"},
+		{name: "point out of range", files: with(map[string]string{"faces": strings.Replace(cube["faces"], "4(1 2 6 5)", "4(1 2 6 8)", 1)}), msg: "point label 8 out of range"},
+		{name: "list size mismatch", files: with(map[string]string{"faces": strings.Replace(cube["faces"], "6\n(", "7\n(", 1)}), msg: "expected"},
+		{name: "huge uniform list", files: with(map[string]string{"owner": meshHeader("ascii", "labelList", "owner") + "2000000000{0}\n"}), msg: "list size 2000000000 is more than the file can hold"},
+		{name: "huge gzipped uniform list", files: with(map[string]string{"owner": "", "owner.gz": meshHeader("ascii", "labelList", "owner") + "2000000000{0}\n"}), msg: "more than the file can hold"},
+		{name: "huge binary list", files: with(map[string]string{"points": binaryPoints(math.MaxInt/8, cubePoints)}), msg: "more than the file can hold"},
+		{name: "short binary list", files: with(map[string]string{"points": binaryPoints(9, cubePoints)}), msg: "binary list"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadPolyMesh(writeMesh(t, tt.files))
+			if _, ok := err.(*Error); !ok || !strings.Contains(err.Error(), tt.msg) {
+				t.Errorf("err = %v, want an *Error with %q", err, tt.msg)
+			}
+		})
+	}
+}
+
+// A count that overflows when multiplied by the item size must not get
+// past binaryBlock, whatever the file claims to hold.
+func TestBinaryBlockOverflow(t *testing.T) {
+	l := &listReader{file: "points", line: 1, size: math.MaxInt64}
+	if _, err := l.binaryBlock(math.MaxInt/8, 24); err == nil {
+		t.Fatal("binaryBlock accepted a size that overflows")
+	}
+}
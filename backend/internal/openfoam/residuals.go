@@ -0,0 +1,219 @@
+package openfoam
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Float is a float64 that survives JSON encoding when it is NaN or infinite,
+// which is exactly what a diverging solver prints.
+type Float float64
+
+func (f Float) MarshalJSON() ([]byte, error) {
+	v := float64(f)
+	switch {
+	case math.IsNaN(v):
+		return []byte(`"NaN"`), nil
+	case math.IsInf(v, 1):
+		return []byte(`"Infinity"`), nil
+	case math.IsInf(v, -1):
+		return []byte(`"-Infinity"`), nil
+	}
+	return json.Marshal(v)
+}
+
+func (f *Float) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		v, err := parseFloat(s)
+		if err != nil {
+			return err
+		}
+		*f = Float(v)
+		return nil
+	}
+
+	var v float64
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*f = Float(v)
+	return nil
+}
+
+// FieldResidual is the first linear solve of a field within one time step.
+type FieldResidual struct {
+	Time       Float
+	Initial    Float
+	Final      Float
+	Iterations int
+}
+
+type CourantNumber struct {
+	Time Float
+	Mean Float
+	Max  Float
+}
+
+type ContinuityError struct {
+	Time       Float
+	SumLocal   Float
+	Global     Float
+	Cumulative Float
+}
+
+// Residuals holds the convergence history extracted from a solver log.
+type Residuals struct {
+	Fields     map[string][]FieldResidual
+	Courant    []CourantNumber
+	Continuity []ContinuityError
+}
+
+func NewResiduals() *Residuals {
+	return &Residuals{Fields: make(map[string][]FieldResidual)}
+}
+
+var (
+	solvingRe    = regexp.MustCompile(`Solving for ([^,]+), Initial residual = ([^,]+), Final residual = ([^,]+), No Iterations (\d+)`)
+	courantRe    = regexp.MustCompile(`^Courant Number mean: (\S+) max: (\S+)`)
+	continuityRe = regexp.MustCompile(`^time step continuity errors : sum local = ([^,]+), global = ([^,]+), cumulative = (\S+)`)
+)
+
+// LogParser incrementally turns OpenFOAM solver output into Residuals.
+// Feed it lines in order; it keeps track of the current time step.
+type LogParser struct {
+	residuals *Residuals
+	time      Float
+	// solved tracks fields already recorded in the current time step, so
+	// repeated solves (PISO/PIMPLE correctors) don't add extra points.
+	solved map[string]bool
+}
+
+func NewLogParser() *LogParser {
+	return &LogParser{
+		residuals: NewResiduals(),
+		solved:    make(map[string]bool),
+	}
+}
+
+// Record is what a single log line contributed. At most one of its fields is
+// set; all are nil for lines that carry no convergence data.
+type Record struct {
+	Field      string
+	Residual   *FieldResidual
+	Courant    *CourantNumber
+	Continuity *ContinuityError
+}
+
+// ParseLine consumes one log line and reports what it recorded, so callers
+// can react to new data as it arrives.
+func (p *LogParser) ParseLine(line string) Record {
+	line = strings.TrimSpace(line)
+
+	if strings.HasPrefix(line, "Time = ") {
+		value := strings.TrimSuffix(strings.TrimPrefix(line, "Time = "), "s")
+		if t, err := parseFloat(value); err == nil {
+			p.time = Float(t)
+			p.solved = make(map[string]bool)
+		}
+		return Record{}
+	}
+
+	if m := courantRe.FindStringSubmatch(line); m != nil {
+		mean, err1 := parseFloat(m[1])
+		max, err2 := parseFloat(m[2])
+		if err1 == nil && err2 == nil {
+			c := CourantNumber{Time: p.time, Mean: Float(mean), Max: Float(max)}
+			p.residuals.Courant = append(p.residuals.Courant, c)
+			return Record{Courant: &c}
+		}
+		return Record{}
+	}
+
+	if m := continuityRe.FindStringSubmatch(line); m != nil {
+		sumLocal, err1 := parseFloat(m[1])
+		global, err2 := parseFloat(m[2])
+		cumulative, err3 := parseFloat(m[3])
+		if err1 == nil && err2 == nil && err3 == nil {
+			c := ContinuityError{
+				Time:       p.time,
+				SumLocal:   Float(sumLocal),
+				Global:     Float(global),
+				Cumulative: Float(cumulative),
+			}
+			p.residuals.Continuity = append(p.residuals.Continuity, c)
+			return Record{Continuity: &c}
+		}
+		return Record{}
+	}
+
+	if m := solvingRe.FindStringSubmatch(line); m != nil {
+		field := strings.TrimSpace(m[1])
+		if p.solved[field] {
+			return Record{}
+		}
+
+		initial, err1 := parseFloat(m[2])
+		final, err2 := parseFloat(m[3])
+		iterations, err3 := strconv.Atoi(m[4])
+		if err1 != nil || err2 != nil || err3 != nil {
+			return Record{}
+		}
+
+		p.solved[field] = true
+		point := FieldResidual{Time: p.time, Initial: Float(initial), Final: Float(final), Iterations: iterations}
+		p.residuals.Fields[field] = append(p.residuals.Fields[field], point)
+		return Record{Field: field, Residual: &point}
+	}
+
+	return Record{}
+}
+
+// Residuals returns a copy of everything parsed so far.
+func (p *LogParser) Residuals() *Residuals {
+	out := &Residuals{
+		Fields:     make(map[string][]FieldResidual, len(p.residuals.Fields)),
+		Courant:    append([]CourantNumber(nil), p.residuals.Courant...),
+		Continuity: append([]ContinuityError(nil), p.residuals.Continuity...),
+	}
+	for field, points := range p.residuals.Fields {
+		out.Fields[field] = append([]FieldResidual(nil), points...)
+	}
+	return out
+}
+
+// ParseResiduals reads a complete solver log.
+func ParseResiduals(r io.Reader) (*Residuals, error) {
+	parser := NewLogParser()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		parser.ParseLine(scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return parser.Residuals(), nil
+}
+
+// parseFloat also accepts the "nan"/"-nan"/"inf" spellings printed by glibc.
+func parseFloat(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	switch strings.ToLower(strings.TrimPrefix(s, "-")) {
+	case "nan":
+		return math.NaN(), nil
+	case "inf", "infinity":
+		if strings.HasPrefix(s, "-") {
+			return math.Inf(-1), nil
+		}
+		return math.Inf(1), nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
@@ -0,0 +1,71 @@
+package openfoam
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// WriteCSV writes one row per time step: the initial residual of every field
+// followed by Courant numbers and continuity errors. Missing values are left
+// empty.
+func (r *Residuals) WriteCSV(w io.Writer) error {
+	fields := make([]string, 0, len(r.Fields))
+	for field := range r.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	extra := []string{"courant_mean", "courant_max", "continuity_sum_local", "continuity_global", "continuity_cumulative"}
+	header := append(append([]string{"time"}, fields...), extra...)
+
+	rows := make(map[Float][]string)
+	var times []Float
+	row := func(t Float) []string {
+		if r, ok := rows[t]; ok {
+			return r
+		}
+		r := make([]string, len(header))
+		r[0] = formatFloat(t)
+		rows[t] = r
+		times = append(times, t)
+		return r
+	}
+
+	for i, field := range fields {
+		for _, point := range r.Fields[field] {
+			row(point.Time)[1+i] = formatFloat(point.Initial)
+		}
+	}
+	base := 1 + len(fields)
+	for _, c := range r.Courant {
+		values := row(c.Time)
+		values[base] = formatFloat(c.Mean)
+		values[base+1] = formatFloat(c.Max)
+	}
+	for _, c := range r.Continuity {
+		values := row(c.Time)
+		values[base+2] = formatFloat(c.SumLocal)
+		values[base+3] = formatFloat(c.Global)
+		values[base+4] = formatFloat(c.Cumulative)
+	}
+
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, t := range times {
+		if err := cw.Write(rows[t]); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatFloat(f Float) string {
+	return strconv.FormatFloat(float64(f), 'g', -1, 64)
+}
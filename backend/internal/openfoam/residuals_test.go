@@ -0,0 +1,209 @@
+package openfoam
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// pisoLog is two time steps of pisoFoam on the cavity tutorial, with two
+// pressure correctors per step.
+const pisoLog = `Starting time loop
+
+Time = 0.005
+
+Courant Number mean: 0 max: 0
+smoothSolver:  Solving for Ux, Initial residual = 1, Final residual = 2.96338e-06, No Iterations 19
+smoothSolver:  Solving for Uy, Initial residual = 0, Final residual = 0, No Iterations 0
+DICPCG:  Solving for p, Initial residual = 1, Final residual = 0.0492854, No Iterations 12
+time step continuity errors : sum local = 0.000466513, global = -1.79995e-19, cumulative = -1.79995e-19
+DICPCG:  Solving for p, Initial residual = 0.590864, Final residual = 2.65225e-07, No Iterations 35
+time step continuity errors : sum local = 2.74685e-09, global = -2.6445e-19, cumulative = -4.44445e-19
+ExecutionTime = 0.01 s  ClockTime = 0 s
+
+Time = 0.01
+
+Courant Number mean: 0.0976825 max: 0.585607
+smoothSolver:  Solving for Ux, Initial residual = 0.160686, Final residual = 6.83031e-06, No Iterations 19
+smoothSolver:  Solving for Uy, Initial residual = 0.260828, Final residual = 9.65939e-06, No Iterations 18
+DICPCG:  Solving for p, Initial residual = 0.428925, Final residual = 0.0103739, No Iterations 22
+time step continuity errors : sum local = 0.000110788, global = 2.74798e-19, cumulative = -1.69647e-19
+DICPCG:  Solving for p, Initial residual = 0.30209, Final residual = 5.26569e-07, No Iterations 33
+time step continuity errors : sum local = 6.76288e-09, global = -1.5315e-19, cumulative = -3.22797e-19
+ExecutionTime = 0.02 s  ClockTime = 0 s
+
+End
+`
+
+func TestLogParser(t *testing.T) {
+	tests := []struct {
+		name string
+		log  string
+		want *Residuals
+	}{
+		{
+			name: "PISO correctors",
+			log:  pisoLog,
+			want: &Residuals{
+				Fields: map[string][]FieldResidual{
+					"Ux": {
+						{Time: 0.005, Initial: 1, Final: 2.96338e-06, Iterations: 19},
+						{Time: 0.01, Initial: 0.160686, Final: 6.83031e-06, Iterations: 19},
+					},
+					"Uy": {
+						{Time: 0.005, Initial: 0, Final: 0, Iterations: 0},
+						{Time: 0.01, Initial: 0.260828, Final: 9.65939e-06, Iterations: 18},
+					},
+					"p": {
+						{Time: 0.005, Initial: 1, Final: 0.0492854, Iterations: 12},
+						{Time: 0.01, Initial: 0.428925, Final: 0.0103739, Iterations: 22},
+					},
+				},
+				Courant: []CourantNumber{
+					{Time: 0.005, Mean: 0, Max: 0},
+					{Time: 0.01, Mean: 0.0976825, Max: 0.585607},
+				},
+				Continuity: []ContinuityError{
+					{Time: 0.005, SumLocal: 0.000466513, Global: -1.79995e-19, Cumulative: -1.79995e-19},
+					{Time: 0.005, SumLocal: 2.74685e-09, Global: -2.6445e-19, Cumulative: -4.44445e-19},
+					{Time: 0.01, SumLocal: 0.000110788, Global: 2.74798e-19, Cumulative: -1.69647e-19},
+					{Time: 0.01, SumLocal: 6.76288e-09, Global: -1.5315e-19, Cumulative: -3.22797e-19},
+				},
+			},
+		},
+		{
+			name: "steady state with time in seconds",
+			log: `Time = 1s
+
+smoothSolver:  Solving for Ux, Initial residual = 1, Final residual = 0.0538101, No Iterations 1
+GAMG:  Solving for p, Initial residual = 1, Final residual = 0.068427, No Iterations 17
+time step continuity errors : sum local = 1.19733, global = 0.179883, cumulative = 0.179883
+smoothSolver:  Solving for epsilon, Initial residual = 0.199669, Final residual = 0.00853013, No Iterations 3
+bounding epsilon, min: -1.45735 max: 1080.25 average: 48.855
+ExecutionTime = 0.19 s  ClockTime = 0 s
+`,
+			want: &Residuals{
+				Fields: map[string][]FieldResidual{
+					"Ux":      {{Time: 1, Initial: 1, Final: 0.0538101, Iterations: 1}},
+					"p":       {{Time: 1, Initial: 1, Final: 0.068427, Iterations: 17}},
+					"epsilon": {{Time: 1, Initial: 0.199669, Final: 0.00853013, Iterations: 3}},
+				},
+				Continuity: []ContinuityError{
+					{Time: 1, SumLocal: 1.19733, Global: 0.179883, Cumulative: 0.179883},
+				},
+			},
+		},
+		{
+			name: "no solver output",
+			log:  "/*---------------------------------------------------------------------------*\\\nCreate time\n\nCreate mesh for time = 0\n",
+			want: &Residuals{Fields: map[string][]FieldResidual{}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseResiduals(strings.NewReader(tt.log))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("residuals = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogParserRecords(t *testing.T) {
+	tests := []struct {
+		line string
+		want string // the field of the record, "Courant", "continuity" or ""
+	}{
+		{"Time = 0.12", ""},
+		{"Courant Number mean: 1.17733 max: nan", "Courant"},
+		{"DILUPBiCGStab:  Solving for Ux, Initial residual = nan, Final residual = nan, No Iterations 1000", "Ux"},
+		{"DILUPBiCGStab:  Solving for Ux, Initial residual = 0.1, Final residual = 0.001, No Iterations 2", ""},
+		{"time step continuity errors : sum local = -nan, global = -nan, cumulative = -nan", "continuity"},
+		{"DICPCG:  Solving for p, Initial residual = 1, Final residual = inf, No Iterations 1000", "p"},
+		{"deltaT = 0.00119048", ""},
+	}
+
+	parser := NewLogParser()
+	for _, tt := range tests {
+		rec := parser.ParseLine(tt.line)
+		var got string
+		switch {
+		case rec.Residual != nil:
+			got = rec.Field
+			if rec.Residual.Time != 0.12 {
+				t.Errorf("%q: time = %g", tt.line, float64(rec.Residual.Time))
+			}
+		case rec.Courant != nil:
+			got = "Courant"
+		case rec.Continuity != nil:
+			got = "continuity"
+		}
+		if got != tt.want {
+			t.Errorf("%q recorded %q, want %q", tt.line, got, tt.want)
+		}
+	}
+
+	residuals := parser.Residuals()
+	if ux := residuals.Fields["Ux"]; len(ux) != 1 || !math.IsNaN(float64(ux[0].Initial)) {
+		t.Errorf("Ux = %+v, want one NaN residual", ux)
+	}
+	if p := residuals.Fields["p"]; len(p) != 1 || !math.IsInf(float64(p[0].Final), 1) {
+		t.Errorf("p = %+v, want an infinite final residual", p)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	tests := []struct {
+		name string
+		log  string
+		want string
+	}{
+		{
+			name: "PISO correctors",
+			log:  pisoLog,
+			want: `time,Ux,Uy,p,courant_mean,courant_max,continuity_sum_local,continuity_global,continuity_cumulative
+0.005,1,0,1,0,0,2.74685e-09,-2.6445e-19,-4.44445e-19
+0.01,0.160686,0.260828,0.428925,0.0976825,0.585607,6.76288e-09,-1.5315e-19,-3.22797e-19
+`,
+		},
+		{
+			name: "missing values",
+			log: `Time = 1
+smoothSolver:  Solving for Ux, Initial residual = 1, Final residual = 0.05, No Iterations 1
+Time = 2
+GAMG:  Solving for p, Initial residual = nan, Final residual = nan, No Iterations 1000
+`,
+			want: `time,Ux,p,courant_mean,courant_max,continuity_sum_local,continuity_global,continuity_cumulative
+1,1,,,,,,
+2,,NaN,,,,,
+`,
+		},
+		{
+			name: "no solver output",
+			log:  "Create time\n",
+			want: "time,courant_mean,courant_max,continuity_sum_local,continuity_global,continuity_cumulative\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			residuals, err := ParseResiduals(strings.NewReader(tt.log))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var b bytes.Buffer
+			if err := residuals.WriteCSV(&b); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tt.want {
+				t.Errorf("CSV:\n%s\nwant:\n%s", b.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,452 @@
+package openfoam
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/theweirdfulmurk/cfd-platform/internal/casefile"
+)
+
+// CaseErrors lists the problems found in a case.
+type CaseErrors = casefile.Errors
+
+// caseValidator collects errors across the files of one case.
+type caseValidator struct {
+	dir    string
+	errs   CaseErrors
+	fields []fieldFile
+}
+
+// fieldFile is a parsed field of the initial time directory.
+type fieldFile struct {
+	file       string
+	name       string
+	components int
+	dict       *Dict
+}
+
+func (v *caseValidator) add(file string, line int, format string, args ...any) {
+	v.errs = append(v.errs, &Error{File: file, Line: line, Msg: fmt.Sprintf(format, args...)})
+}
+
+// parse parses a case file and checks its FoamFile header. It returns nil
+// after recording the error when the file can't be used.
+func (v *caseValidator) parse(rel string) *Dict {
+	dict, err := ParseFile(v.dir, rel)
+	if err != nil {
+		var perr *Error
+		if errors.As(err, &perr) {
+			v.errs = append(v.errs, perr)
+		} else {
+			v.add(rel, 0, "%v", err)
+		}
+		return nil
+	}
+
+	header := dict.Get("FoamFile")
+	if header == nil || header.Dict == nil {
+		v.add(rel, 1, "missing FoamFile header")
+		return nil
+	}
+	if _, ok := header.Dict.Get("class").Word(); !ok {
+		v.add(rel, header.Line, "FoamFile header has no class")
+	}
+	return dict
+}
+
+// ValidateCase parses system/controlDict, system/fvSchemes,
+// system/fvSolution and the field files of the initial time directory and
+// checks the entries the solvers need, then checks the fields' boundary
+// conditions against the mesh patches. It returns CaseErrors with file and
+// line of every problem, or nil.
+func ValidateCase(caseDir string) error {
+	v := &caseValidator{dir: caseDir}
+	v.controlDict()
+	v.fvSchemes()
+	v.fvSolution()
+	v.initialFields()
+	v.mesh()
+	v.boundaries()
+	return v.errs.Err()
+}
+
+var (
+	startFromValues    = []string{"firstTime", "startTime", "latestTime"}
+	stopAtValues       = []string{"endTime", "writeNow", "noWriteNow", "nextWrite"}
+	writeControlValues = []string{"timeStep", "runTime", "adjustableRunTime", "clockTime", "cpuTime", "adjustable"}
+)
+
+func (v *caseValidator) controlDict() {
+	const file = "system/controlDict"
+	dict := v.parse(file)
+	if dict == nil {
+		return
+	}
+
+	if e := dict.Get("application"); e == nil {
+		v.add(file, 0, "missing application")
+	} else if _, ok := e.Word(); !ok {
+		v.add(file, e.Line, "application must be a solver name")
+	}
+
+	startFrom := v.keyword(dict, file, "startFrom", startFromValues, "")
+	if startFrom == "startTime" {
+		v.number(dict, file, "startTime", false)
+	}
+	if stopAt := v.keyword(dict, file, "stopAt", stopAtValues, "endTime"); stopAt == "endTime" {
+		v.number(dict, file, "endTime", false)
+	}
+	v.number(dict, file, "deltaT", true)
+	v.keyword(dict, file, "writeControl", writeControlValues, "timeStep")
+	v.number(dict, file, "writeInterval", true)
+}
+
+// keyword checks that key, if present, is one of allowed and returns its
+// value or def.
+func (v *caseValidator) keyword(dict *Dict, file, key string, allowed []string, def string) string {
+	e := dict.Get(key)
+	if e == nil {
+		return def
+	}
+	word, ok := e.Word()
+	if !ok || !contains(allowed, word) {
+		v.add(file, e.Line, "%s must be one of %s", key, strings.Join(allowed, ", "))
+		return ""
+	}
+	return word
+}
+
+// number checks that key is a number, and a positive one when positive is
+// set. Values computed by #calc or #codeStream are accepted unchecked.
+func (v *caseValidator) number(dict *Dict, file, key string, positive bool) {
+	e := dict.Get(key)
+	if e == nil {
+		v.add(file, 0, "missing %s", key)
+		return
+	}
+	if len(e.Values) > 0 && e.Values[0].Kind == ValueDirective {
+		return
+	}
+	n, ok := e.Number()
+	switch {
+	case !ok:
+		v.add(file, e.Line, "%s must be a number", key)
+	case positive && n <= 0:
+		v.add(file, e.Line, "%s must be greater than zero", key)
+	}
+}
+
+var fvSchemesSections = []string{
+	"ddtSchemes", "gradSchemes", "divSchemes", "laplacianSchemes", "interpolationSchemes", "snGradSchemes",
+}
+
+func (v *caseValidator) fvSchemes() {
+	const file = "system/fvSchemes"
+	dict := v.parse(file)
+	if dict == nil {
+		return
+	}
+	for _, section := range fvSchemesSections {
+		if e := dict.Get(section); e == nil {
+			v.add(file, 0, "missing %s", section)
+		} else if e.Dict == nil {
+			v.add(file, e.Line, "%s must be a dictionary", section)
+		}
+	}
+}
+
+func (v *caseValidator) fvSolution() {
+	const file = "system/fvSolution"
+	dict := v.parse(file)
+	if dict == nil {
+		return
+	}
+
+	solvers := dict.Get("solvers")
+	if solvers == nil {
+		return
+	}
+	if solvers.Dict == nil {
+		v.add(file, solvers.Line, "solvers must be a dictionary")
+		return
+	}
+	for _, e := range solvers.Dict.Entries {
+		settings := e.Dict
+		if settings == nil && len(e.Values) == 1 && e.Values[0].Kind == ValueDict {
+			settings = e.Values[0].Dict
+		}
+		if settings == nil {
+			v.add(file, e.Line, "solver settings for %s must be a dictionary", e.Key)
+			continue
+		}
+		if settings.Get("solver") == nil {
+			v.add(file, e.Line, "no solver selected for %s", e.Key)
+		}
+	}
+}
+
+// mesh checks that the case either ships a mesh or can be meshed with
+// blockMesh, and that snappyHexMesh finds its geometry.
+func (v *caseValidator) mesh() {
+	if !HasPolyMesh(v.dir) && !exists(v.dir, "system/blockMeshDict") &&
+		!exists(v.dir, PolyMeshDir+"/blockMeshDict") {
+		v.add(PolyMeshDir, 0, "case has neither a mesh nor a system/blockMeshDict")
+	}
+	if HasPolyMesh(v.dir) || !exists(v.dir, "system/snappyHexMeshDict") {
+		return
+	}
+
+	const file = "system/snappyHexMeshDict"
+	dict := v.parse(file)
+	if dict == nil {
+		return
+	}
+	geometry := dict.Get("geometry")
+	if geometry == nil || geometry.Dict == nil {
+		v.add(file, 0, "missing geometry dictionary")
+		return
+	}
+	for _, e := range geometry.Dict.Entries {
+		if e.Dict == nil {
+			continue
+		}
+		// Surfaces name their file, or are named after it.
+		surface := ""
+		if f := e.Dict.Get("file"); f != nil && len(f.Values) == 1 {
+			surface = f.Values[0].Text
+		} else if t, _ := e.Dict.Get("type").Word(); t == "triSurfaceMesh" {
+			surface = e.Key
+		}
+		if surface != "" && !surfaceExists(v.dir, surface) {
+			v.add(file, e.Line, "geometry %s not found in constant/triSurface", surface)
+		}
+	}
+}
+
+// surfaceExists looks for a snappyHexMesh surface where the OpenFOAM
+// versions keep them, optionally gzipped.
+func surfaceExists(caseDir, name string) bool {
+	if !filepath.IsLocal(name) {
+		return false
+	}
+	for _, dir := range []string{"constant/triSurface", "constant/geometry"} {
+		for _, suffix := range []string{"", ".gz"} {
+			if exists(caseDir, filepath.Join(dir, name+suffix)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// initialTimeDir is the time directory the run starts from. Tutorials keep
+// pristine fields in 0.orig and copy them to 0 in Allrun; without Allrun
+// the runner makes the copy.
+func (v *caseValidator) initialTimeDir() string {
+	for _, dir := range []string{"0", "0.orig"} {
+		if info, err := os.Stat(filepath.Join(v.dir, dir)); err == nil && info.IsDir() {
+			return dir
+		}
+	}
+	return ""
+}
+
+func (v *caseValidator) initialFields() {
+	dir := v.initialTimeDir()
+	if dir == "" {
+		return
+	}
+	entries, err := os.ReadDir(filepath.Join(v.dir, dir))
+	if err != nil {
+		v.add(dir, 0, "%v", err)
+		return
+	}
+
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || strings.HasPrefix(name, ".") ||
+			strings.HasSuffix(name, "~") || strings.HasSuffix(name, ".orig") {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		v.field(filepath.ToSlash(filepath.Join(dir, name)))
+	}
+}
+
+var binaryFormatRe = regexp.MustCompile(`\bformat\s+binary\s*;`)
+
+// field checks a volume or point field file.
+func (v *caseValidator) field(file string) {
+	if binary, err := isBinaryField(filepath.Join(v.dir, file)); err == nil && binary {
+		// Binary list data can't be tokenized; the solver checks it.
+		return
+	}
+
+	dict := v.parse(file)
+	if dict == nil {
+		return
+	}
+	class, _ := dict.Sub("FoamFile").Get("class").Word()
+	components, ok := fieldComponents(class)
+	if !ok {
+		// Not a field, e.g. a dictionary kept next to the fields.
+		return
+	}
+	v.fields = append(v.fields, fieldFile{file: file, name: filepath.Base(file), components: components, dict: dict})
+
+	if e := dict.Get("dimensions"); e == nil {
+		v.add(file, 0, "missing dimensions")
+	} else if len(e.Values) != 1 || e.Values[0].Kind != ValueDimensions || !validDimensions(e.Values[0]) {
+		v.add(file, e.Line, "dimensions must be [mass length time temperature moles current luminosity]")
+	}
+
+	if e := dict.Get("internalField"); e == nil {
+		v.add(file, 0, "missing internalField")
+	} else if msg := checkFieldValue(e.Values, components); msg != "" {
+		v.add(file, e.Line, "internalField %s", msg)
+	}
+
+	boundary := dict.Get("boundaryField")
+	if boundary == nil {
+		v.add(file, 0, "missing boundaryField")
+		return
+	}
+	if boundary.Dict == nil {
+		v.add(file, boundary.Line, "boundaryField must be a dictionary")
+		return
+	}
+	for _, patch := range boundary.Dict.Entries {
+		if patch.Dict == nil {
+			v.add(file, patch.Line, "boundary condition for %s must be a dictionary", patch.Key)
+			continue
+		}
+		if _, ok := patch.Dict.Get("type").Word(); !ok {
+			v.add(file, patch.Line, "boundary condition for %s has no type", patch.Key)
+		}
+		if value := patch.Dict.Get("value"); value != nil {
+			if msg := checkFieldValue(value.Values, components); msg != "" {
+				v.add(file, value.Line, "value of %s %s", patch.Key, msg)
+			}
+		}
+	}
+}
+
+func isBinaryField(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	// The FoamFile header sits at the top of the file.
+	head := make([]byte, 4096)
+	n, _ := f.Read(head)
+	return binaryFormatRe.Match(head[:n]), nil
+}
+
+// fieldComponents returns the number of components of a field class such
+// as volVectorField.
+func fieldComponents(class string) (int, bool) {
+	for _, kind := range []struct {
+		suffix     string
+		components int
+	}{
+		{"SphericalTensorField", 1},
+		{"SymmTensorField", 6},
+		{"TensorField", 9},
+		{"VectorField", 3},
+		{"ScalarField", 1},
+	} {
+		if strings.HasSuffix(class, kind.suffix) && class != kind.suffix {
+			return kind.components, true
+		}
+	}
+	return 0, false
+}
+
+func validDimensions(v Value) bool {
+	numeric := 0
+	for _, item := range v.Items {
+		if item.Kind == ValueNumber {
+			numeric++
+		}
+	}
+	if numeric != len(v.Items) {
+		// Named units such as [m/s] in recent OpenFOAM versions.
+		return len(v.Items) > 0
+	}
+	return numeric == 5 || numeric == 7
+}
+
+// checkFieldValue checks a "uniform v" or "nonuniform List<T> N(...)"
+// value and returns what's wrong with it.
+func checkFieldValue(values []Value, components int) string {
+	if len(values) == 0 {
+		return "is empty"
+	}
+	if values[0].Kind == ValueDirective {
+		return ""
+	}
+
+	switch values[0].Text {
+	case "uniform":
+		if len(values) != 2 || !validComponent(values[1], components) {
+			return fmt.Sprintf("must be uniform followed by %s", componentsDescription(components))
+		}
+	case "nonuniform":
+		if len(values) != 3 || values[2].Kind != ValueList {
+			return "must be nonuniform List<type> followed by a list"
+		}
+		for _, item := range values[2].Items {
+			if !validComponent(item, components) {
+				return fmt.Sprintf("has an item on line %d that is not %s", item.Line, componentsDescription(components))
+			}
+		}
+	default:
+		if values[0].Kind != ValueNumber || len(values) != 1 || components != 1 {
+			return "must start with uniform or nonuniform"
+		}
+	}
+	return ""
+}
+
+func validComponent(v Value, components int) bool {
+	if components == 1 {
+		return v.Kind == ValueNumber
+	}
+	if v.Kind != ValueList || len(v.Items) != components {
+		return false
+	}
+	for _, item := range v.Items {
+		if item.Kind != ValueNumber {
+			return false
+		}
+	}
+	return true
+}
+
+func componentsDescription(components int) string {
+	if components == 1 {
+		return "a number"
+	}
+	return fmt.Sprintf("a list of %d numbers", components)
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
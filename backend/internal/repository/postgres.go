@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// queryTimeout bounds every repository call so a stalled database cannot
+// hang HTTP handlers indefinitely.
+const queryTimeout = 5 * time.Second
+
+// migrationLockID is an arbitrary key for pg_advisory_lock, held while
+// migrating so replicas starting together don't race on the schema.
+const migrationLockID = 0x6366642d706c6174
+
+var postgresMigrations = []string{
+	`CREATE TABLE simulations (
+		id           TEXT PRIMARY KEY,
+		name         TEXT NOT NULL,
+		type         TEXT NOT NULL,
+		status       TEXT NOT NULL,
+		pod_name     TEXT NOT NULL DEFAULT '',
+		result_path  TEXT NOT NULL DEFAULT '',
+		config_path  TEXT NOT NULL DEFAULT '',
+		created_at   TIMESTAMPTZ NOT NULL,
+		started_at   TIMESTAMPTZ,
+		completed_at TIMESTAMPTZ
+	)`,
+	`CREATE TABLE visualizations (
+		id            TEXT PRIMARY KEY,
+		simulation_id TEXT NOT NULL,
+		status        TEXT NOT NULL,
+		pod_name      TEXT NOT NULL DEFAULT '',
+		websocket_url TEXT NOT NULL DEFAULT '',
+		result_path   TEXT NOT NULL DEFAULT '',
+		created_at    TIMESTAMPTZ NOT NULL,
+		updated_at    TIMESTAMPTZ NOT NULL
+	)`,
+	`CREATE INDEX idx_visualizations_simulation_id ON visualizations (simulation_id)`,
+	`ALTER TABLE simulations ADD COLUMN status_reason TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE simulations ADD COLUMN divergence_rules TEXT NOT NULL DEFAULT '{}'`,
+	`ALTER TABLE simulations ADD COLUMN parent_id TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE simulations ADD COLUMN resources TEXT NOT NULL DEFAULT '{}'`,
+	`ALTER TABLE simulations ADD COLUMN subdomains INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE simulations ADD COLUMN nodes INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE simulations ADD COLUMN solver TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE simulations ADD COLUMN run_plan TEXT NOT NULL DEFAULT '{}'`,
+	`CREATE TABLE simulation_events (
+		seq     BIGSERIAL PRIMARY KEY,
+		payload BYTEA NOT NULL
+	)`,
+}
+
+type PostgresConfig struct {
+	DSN             string
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+// OpenPostgres connects to PostgreSQL, configures the connection pool and
+// brings the schema up to date.
+func OpenPostgres(cfg PostgresConfig) (*sql.DB, error) {
+	db, err := sql.Open("pgx", cfg.DSN)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to postgres: %w", err)
+	}
+
+	if err := migratePostgres(ctx, db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func migratePostgres(ctx context.Context, db *sql.DB) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID)
+
+	return migrate(ctx, conn, postgresMigrations)
+}
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/theweirdfulmurk/cfd-platform/internal/domain"
+)
+
+const (
+	eventChannel = "simulation_events"
+
+	// eventLockID serialises appends, so entries commit in the order of
+	// their sequence numbers and followers never skip one that commits late.
+	eventLockID = 0x6366642d65767473
+
+	// eventPollInterval is how often followers look for entries even
+	// without a notification.
+	eventPollInterval = 30 * time.Second
+)
+
+// PostgresEventLog shares events between replicas through a table; LISTEN
+// and NOTIFY wake the followers up.
+type PostgresEventLog struct {
+	db   *sql.DB
+	keep int
+}
+
+// NewPostgresEventLog returns a log that keeps the latest keep entries.
+func NewPostgresEventLog(db *sql.DB, keep int) *PostgresEventLog {
+	return &PostgresEventLog{db: db, keep: keep}
+}
+
+func (l *PostgresEventLog) Append(payload []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	tx, err := l.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, eventLockID); err != nil {
+		return err
+	}
+	var seq int64
+	if err := tx.QueryRowContext(ctx,
+		`INSERT INTO simulation_events (payload) VALUES ($1) RETURNING seq`, payload,
+	).Scan(&seq); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM simulation_events WHERE seq <= $1`, seq-int64(l.keep)); err != nil {
+		return err
+	}
+	// Notifications are delivered on commit.
+	if _, err := tx.ExecContext(ctx, `SELECT pg_notify($1, '')`, eventChannel); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (l *PostgresEventLog) Read(after int64) ([]domain.EventLogEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	return readEvents(ctx, l.db, after)
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func readEvents(ctx context.Context, db queryer, after int64) ([]domain.EventLogEntry, error) {
+	rows, err := db.QueryContext(ctx, `SELECT seq, payload FROM simulation_events WHERE seq > $1 ORDER BY seq`, after)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []domain.EventLogEntry
+	for rows.Next() {
+		var entry domain.EventLogEntry
+		if err := rows.Scan(&entry.Seq, &entry.Payload); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// Follow listens on a connection of its own, which it holds until it
+// returns.
+func (l *PostgresEventLog) Follow(ctx context.Context, after int64, deliver func(domain.EventLogEntry)) error {
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Listen before reading, so nothing appended in between is missed.
+	if _, err := conn.ExecContext(ctx, `LISTEN `+eventChannel); err != nil {
+		return fmt.Errorf("failed to listen for events: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), `UNLISTEN `+eventChannel)
+
+	for {
+		readCtx, cancel := context.WithTimeout(ctx, queryTimeout)
+		entries, err := readEvents(readCtx, conn, after)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to read events: %w", err)
+		}
+		for _, entry := range entries {
+			deliver(entry)
+			after = entry.Seq
+		}
+
+		err = conn.Raw(func(driverConn any) error {
+			waitCtx, cancel := context.WithTimeout(ctx, eventPollInterval)
+			defer cancel()
+			_, err := driverConn.(*stdlib.Conn).Conn().WaitForNotification(waitCtx)
+			if errors.Is(waitCtx.Err(), context.DeadlineExceeded) {
+				return nil
+			}
+			return err
+		})
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return fmt.Errorf("failed to wait for events: %w", err)
+		}
+	}
+}
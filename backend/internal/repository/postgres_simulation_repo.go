@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/theweirdfulmurk/cfd-platform/internal/domain"
+)
+
+type PostgresSimulationRepo struct {
+	db *sql.DB
+}
+
+func NewPostgresSimulationRepo(db *sql.DB) *PostgresSimulationRepo {
+	return &PostgresSimulationRepo{db: db}
+}
+
+func (r *PostgresSimulationRepo) Create(sim *domain.Simulation) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO simulations (`+simulationColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)`,
+		sim.ID, sim.Name, string(sim.Type), string(sim.Status), sim.PodName, sim.ResultPath, sim.ConfigPath,
+		sim.CreatedAt, nullTime(sim.StartedAt), nullTime(sim.CompletedAt), sim.StatusReason, jsonText(sim.Divergence), sim.ParentID, jsonText(sim.Resources), sim.Subdomains, sim.Nodes, sim.Solver, jsonText(sim.Run),
+	)
+	return err
+}
+
+func (r *PostgresSimulationRepo) GetByID(id string) (*domain.Simulation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	row := r.db.QueryRowContext(ctx, `SELECT `+simulationColumns+` FROM simulations WHERE id = $1`, id)
+
+	sim, err := scanSimulation(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return sim, err
+}
+
+func (r *PostgresSimulationRepo) List() ([]*domain.Simulation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT `+simulationColumns+` FROM simulations ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]*domain.Simulation, 0)
+	for rows.Next() {
+		sim, err := scanSimulation(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, sim)
+	}
+	return result, rows.Err()
+}
+
+func (r *PostgresSimulationRepo) Update(sim *domain.Simulation) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE simulations SET name = $1, type = $2, status = $3, pod_name = $4, result_path = $5, config_path = $6,
+			created_at = $7, started_at = $8, completed_at = $9, status_reason = $10, divergence_rules = $11, parent_id = $12, resources = $13, subdomains = $14, nodes = $15, solver = $16, run_plan = $17
+		WHERE id = $18`,
+		sim.Name, string(sim.Type), string(sim.Status), sim.PodName, sim.ResultPath, sim.ConfigPath,
+		sim.CreatedAt, nullTime(sim.StartedAt), nullTime(sim.CompletedAt), sim.StatusReason, jsonText(sim.Divergence), sim.ParentID, jsonText(sim.Resources), sim.Subdomains, sim.Nodes, sim.Solver, jsonText(sim.Run),
+		sim.ID,
+	)
+	if err != nil {
+		return err
+	}
+	return requireAffected(res)
+}
+
+func (r *PostgresSimulationRepo) Delete(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `DELETE FROM simulations WHERE id = $1`, id)
+	return err
+}
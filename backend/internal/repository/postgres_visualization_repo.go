@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/theweirdfulmurk/cfd-platform/internal/domain"
+)
+
+type PostgresVisualizationRepo struct {
+	db *sql.DB
+}
+
+func NewPostgresVisualizationRepo(db *sql.DB) *PostgresVisualizationRepo {
+	return &PostgresVisualizationRepo{db: db}
+}
+
+func (r *PostgresVisualizationRepo) Create(viz *domain.Visualization) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO visualizations (`+visualizationColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		viz.ID, viz.SimulationID, string(viz.Status), viz.PodName, viz.WebSocketURL, viz.ResultPath,
+		viz.CreatedAt, viz.UpdatedAt,
+	)
+	return err
+}
+
+func (r *PostgresVisualizationRepo) GetByID(id string) (*domain.Visualization, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	row := r.db.QueryRowContext(ctx, `SELECT `+visualizationColumns+` FROM visualizations WHERE id = $1`, id)
+
+	viz, err := scanVisualization(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return viz, err
+}
+
+func (r *PostgresVisualizationRepo) GetBySimulationID(simulationID string) ([]*domain.Visualization, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+visualizationColumns+` FROM visualizations WHERE simulation_id = $1 ORDER BY created_at`,
+		simulationID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*domain.Visualization
+	for rows.Next() {
+		viz, err := scanVisualization(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, viz)
+	}
+	return result, rows.Err()
+}
+
+func (r *PostgresVisualizationRepo) Update(viz *domain.Visualization) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE visualizations SET simulation_id = $1, status = $2, pod_name = $3, websocket_url = $4, result_path = $5,
+			created_at = $6, updated_at = $7
+		WHERE id = $8`,
+		viz.SimulationID, string(viz.Status), viz.PodName, viz.WebSocketURL, viz.ResultPath,
+		viz.CreatedAt, viz.UpdatedAt,
+		viz.ID,
+	)
+	if err != nil {
+		return err
+	}
+	return requireAffected(res)
+}
+
+func (r *PostgresVisualizationRepo) Delete(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `DELETE FROM visualizations WHERE id = $1`, id)
+	return err
+}
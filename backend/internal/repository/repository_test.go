@@ -0,0 +1,305 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/theweirdfulmurk/cfd-platform/internal/domain"
+)
+
+// backend is one storage driver under test.
+type backend struct {
+	name string
+	open func(t *testing.T) (domain.SimulationRepository, domain.VisualizationRepository)
+}
+
+// backends returns every driver that can run here. Postgres runs against
+// the database in POSTGRES_TEST_DSN, e.g. the one from make postgres-up;
+// each test gets a schema of its own.
+func backends() []backend {
+	list := []backend{
+		{"memory", func(t *testing.T) (domain.SimulationRepository, domain.VisualizationRepository) {
+			return NewInMemorySimulationRepo(), NewInMemoryVisualizationRepo()
+		}},
+		{"sqlite", func(t *testing.T) (domain.SimulationRepository, domain.VisualizationRepository) {
+			db, err := OpenSQLite(filepath.Join(t.TempDir(), "cfd.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { db.Close() })
+			return NewSQLiteSimulationRepo(db), NewSQLiteVisualizationRepo(db)
+		}},
+	}
+	if dsn := os.Getenv("POSTGRES_TEST_DSN"); dsn != "" {
+		list = append(list, backend{"postgres", func(t *testing.T) (domain.SimulationRepository, domain.VisualizationRepository) {
+			db := openPostgresSchema(t, dsn)
+			return NewPostgresSimulationRepo(db), NewPostgresVisualizationRepo(db)
+		}})
+	}
+	return list
+}
+
+func openPostgresSchema(t *testing.T, dsn string) *sql.DB {
+	t.Helper()
+	admin, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if admin, err := sql.Open("pgx", dsn); err == nil {
+			admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`)
+			admin.Close()
+		}
+	})
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+
+	db, err := OpenPostgres(PostgresConfig{DSN: u.String(), MaxOpenConns: 4, MaxIdleConns: 4, ConnMaxLifetime: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// at returns a time that survives every driver's precision and time zone.
+func at(sec int64) time.Time {
+	return time.Unix(sec, 123000).UTC()
+}
+
+func sampleSimulation(id string) *domain.Simulation {
+	started := at(1_700_000_100)
+	return &domain.Simulation{
+		ID:         id,
+		Name:       "cavity",
+		Type:       domain.SimTypeCFD,
+		Status:     domain.SimStatusRunning,
+		PodName:    "sim-" + id + "-abcde",
+		ResultPath: "/results/" + id,
+		ConfigPath: "/pvc/" + id,
+		CreatedAt:  at(1_700_000_000),
+		StartedAt:  &started,
+		Divergence: domain.DivergenceRules{MaxResidual: 10, StopOnNaN: true},
+		Resources:  domain.ResourceRequirements{CPU: "2", Memory: "4Gi"},
+		Subdomains: 4,
+		Solver:     "openfoam:v2312",
+		Run: domain.RunPlan{
+			Application: "simpleFoam",
+			PostProcess: []string{"yPlus"},
+			Stages:      []domain.RunStage{{Name: "solve", Args: []string{"simpleFoam"}}},
+		},
+	}
+}
+
+// normalize puts times in UTC so records read back from any driver compare
+// equal to what was stored.
+func normalize(sim *domain.Simulation) *domain.Simulation {
+	c := *sim
+	c.CreatedAt = c.CreatedAt.UTC()
+	if c.StartedAt != nil {
+		t := c.StartedAt.UTC()
+		c.StartedAt = &t
+	}
+	if c.CompletedAt != nil {
+		t := c.CompletedAt.UTC()
+		c.CompletedAt = &t
+	}
+	return &c
+}
+
+func TestSimulationRepository(t *testing.T) {
+	for _, b := range backends() {
+		t.Run(b.name, func(t *testing.T) {
+			repo, _ := b.open(t)
+
+			sim := sampleSimulation("s1")
+			if err := repo.Create(sim); err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			got, err := repo.GetByID("s1")
+			if err != nil {
+				t.Fatalf("GetByID: %v", err)
+			}
+			if !reflect.DeepEqual(normalize(got), normalize(sim)) {
+				t.Errorf("GetByID = %+v, want %+v", got, sim)
+			}
+
+			// Callers own what they get back.
+			got.Name = "changed"
+			if again, _ := repo.GetByID("s1"); again.Name != "cavity" {
+				t.Errorf("stored record changed through a returned copy")
+			}
+
+			completed := at(1_700_000_200)
+			sim.Status, sim.StatusReason, sim.CompletedAt = domain.SimStatusFailed, "solver exited with code 1", &completed
+			if err := repo.Update(sim); err != nil {
+				t.Fatalf("Update: %v", err)
+			}
+			got, _ = repo.GetByID("s1")
+			if !reflect.DeepEqual(normalize(got), normalize(sim)) {
+				t.Errorf("after Update = %+v, want %+v", got, sim)
+			}
+
+			if err := repo.Update(sampleSimulation("missing")); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Update of a missing record = %v, want ErrNotFound", err)
+			}
+			if _, err := repo.GetByID("missing"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("GetByID of a missing record = %v, want ErrNotFound", err)
+			}
+
+			if err := repo.Create(sampleSimulation("s2")); err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			list, err := repo.List()
+			if err != nil || len(list) != 2 {
+				t.Fatalf("List = %d records, %v; want 2", len(list), err)
+			}
+
+			if err := repo.Delete("s1"); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			if _, err := repo.GetByID("s1"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("GetByID after Delete = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestVisualizationRepository(t *testing.T) {
+	for _, b := range backends() {
+		t.Run(b.name, func(t *testing.T) {
+			_, repo := b.open(t)
+
+			viz := &domain.Visualization{
+				ID:           "v1",
+				SimulationID: "s1",
+				Status:       domain.VizStatusPending,
+				ResultPath:   "/results/s1",
+				CreatedAt:    at(1_700_000_000),
+				UpdatedAt:    at(1_700_000_000),
+			}
+			if err := repo.Create(viz); err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			other := *viz
+			other.ID, other.SimulationID = "v2", "s2"
+			if err := repo.Create(&other); err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+
+			viz.Status, viz.PodName, viz.WebSocketURL = domain.VizStatusReady, "viz-v1", "ws://10.0.0.1:9000/ws"
+			viz.UpdatedAt = at(1_700_000_050)
+			if err := repo.Update(viz); err != nil {
+				t.Fatalf("Update: %v", err)
+			}
+			got, err := repo.GetByID("v1")
+			if err != nil {
+				t.Fatalf("GetByID: %v", err)
+			}
+			got.CreatedAt, got.UpdatedAt = got.CreatedAt.UTC(), got.UpdatedAt.UTC()
+			if !reflect.DeepEqual(got, viz) {
+				t.Errorf("GetByID = %+v, want %+v", got, viz)
+			}
+
+			forSim, err := repo.GetBySimulationID("s1")
+			if err != nil || len(forSim) != 1 || forSim[0].ID != "v1" {
+				t.Errorf("GetBySimulationID(s1) = %v, %v; want [v1]", forSim, err)
+			}
+
+			missing := *viz
+			missing.ID = "missing"
+			if err := repo.Update(&missing); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Update of a missing record = %v, want ErrNotFound", err)
+			}
+			if err := repo.Delete("v1"); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			if _, err := repo.GetByID("v1"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("GetByID after Delete = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+// Reopening a database must not re-run migrations that were applied.
+func TestSQLiteMigrationsAreIdempotent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cfd.db")
+	for i := 0; i < 2; i++ {
+		db, err := OpenSQLite(path)
+		if err != nil {
+			t.Fatalf("open %d: %v", i+1, err)
+		}
+		var version int
+		err = db.QueryRowContext(context.Background(), `SELECT MAX(version) FROM schema_migrations`).Scan(&version)
+		db.Close()
+		if err != nil || version != len(sqliteMigrations) {
+			t.Fatalf("open %d: schema version %d, %v; want %d", i+1, version, err, len(sqliteMigrations))
+		}
+	}
+}
+
+func TestPostgresEventLog(t *testing.T) {
+	dsn := os.Getenv("POSTGRES_TEST_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_TEST_DSN is not set")
+	}
+	eventLog := NewPostgresEventLog(openPostgresSchema(t, dsn), 2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	delivered := make(chan domain.EventLogEntry, 10)
+	followed := make(chan error, 1)
+	go func() {
+		followed <- eventLog.Follow(ctx, 0, func(entry domain.EventLogEntry) { delivered <- entry })
+	}()
+
+	for _, payload := range []string{"a", "b", "c"} {
+		if err := eventLog.Append([]byte(payload)); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	for i, want := range []string{"a", "b", "c"} {
+		select {
+		case entry := <-delivered:
+			if entry.Seq != int64(i+1) || string(entry.Payload) != want {
+				t.Errorf("entry %d = %d %q, want %d %q", i, entry.Seq, entry.Payload, i+1, want)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("entry %d was not delivered", i)
+		}
+	}
+
+	// Only the latest two are kept.
+	entries, err := eventLog.Read(0)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if len(entries) != 2 || string(entries[0].Payload) != "b" || string(entries[1].Payload) != "c" {
+		t.Errorf("Read = %+v", entries)
+	}
+
+	cancel()
+	if err := <-followed; !errors.Is(err, context.Canceled) {
+		t.Errorf("Follow returned %v after its context was cancelled", err)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"github.com/theweirdfulmurk/cfd-platform/internal/domain"
 )
 
+// InMemorySimulationRepo copies records in and out so callers can't mutate
+// stored state outside the lock.
 type InMemorySimulationRepo struct {
 	mu   sync.RWMutex
 	data map[string]*domain.Simulation
@@ -20,7 +22,8 @@ func NewInMemorySimulationRepo() *InMemorySimulationRepo {
 func (r *InMemorySimulationRepo) Create(sim *domain.Simulation) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	r.data[sim.ID] = sim
+	stored := *sim
+	r.data[sim.ID] = &stored
 	return nil
 }
 
@@ -32,7 +35,8 @@ func (r *InMemorySimulationRepo) GetByID(id string) (*domain.Simulation, error)
 	if !exists {
 		return nil, ErrNotFound
 	}
-	return sim, nil
+	copied := *sim
+	return &copied, nil
 }
 
 func (r *InMemorySimulationRepo) List() ([]*domain.Simulation, error) {
@@ -41,7 +45,8 @@ func (r *InMemorySimulationRepo) List() ([]*domain.Simulation, error) {
 
 	result := make([]*domain.Simulation, 0, len(r.data))
 	for _, sim := range r.data {
-		result = append(result, sim)
+		copied := *sim
+		result = append(result, &copied)
 	}
 	return result, nil
 }
@@ -53,7 +58,8 @@ func (r *InMemorySimulationRepo) Update(sim *domain.Simulation) error {
 	if _, exists := r.data[sim.ID]; !exists {
 		return ErrNotFound
 	}
-	r.data[sim.ID] = sim
+	stored := *sim
+	r.data[sim.ID] = &stored
 	return nil
 }
 
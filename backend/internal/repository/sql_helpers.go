@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/theweirdfulmurk/cfd-platform/internal/domain"
+)
+
+const (
+	simulationColumns    = `id, name, type, status, pod_name, result_path, config_path, created_at, started_at, completed_at, status_reason, divergence_rules, parent_id, resources, subdomains, nodes, solver, run_plan`
+	visualizationColumns = `id, simulation_id, status, pod_name, websocket_url, result_path, created_at, updated_at`
+)
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *t, Valid: true}
+}
+
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// jsonText encodes v for a TEXT column holding structured data.
+func jsonText(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+func requireAffected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanSimulation(row rowScanner) (*domain.Simulation, error) {
+	var (
+		sim                    domain.Simulation
+		simType, status        string
+		startedAt, completedAt sql.NullTime
+		divergence, resources  string
+		runPlan                string
+	)
+	if err := row.Scan(
+		&sim.ID, &sim.Name, &simType, &status, &sim.PodName, &sim.ResultPath, &sim.ConfigPath,
+		&sim.CreatedAt, &startedAt, &completedAt, &sim.StatusReason, &divergence, &sim.ParentID, &resources, &sim.Subdomains, &sim.Nodes, &sim.Solver, &runPlan,
+	); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(divergence), &sim.Divergence); err != nil {
+		return nil, fmt.Errorf("invalid divergence rules for %s: %w", sim.ID, err)
+	}
+	if err := json.Unmarshal([]byte(resources), &sim.Resources); err != nil {
+		return nil, fmt.Errorf("invalid resources for %s: %w", sim.ID, err)
+	}
+	if err := json.Unmarshal([]byte(runPlan), &sim.Run); err != nil {
+		return nil, fmt.Errorf("invalid run plan for %s: %w", sim.ID, err)
+	}
+
+	sim.Type = domain.SimulationType(simType)
+	sim.Status = domain.SimulationStatus(status)
+	sim.StartedAt = timePtr(startedAt)
+	sim.CompletedAt = timePtr(completedAt)
+	return &sim, nil
+}
+
+func scanVisualization(row rowScanner) (*domain.Visualization, error) {
+	var (
+		viz    domain.Visualization
+		status string
+	)
+	if err := row.Scan(
+		&viz.ID, &viz.SimulationID, &status, &viz.PodName, &viz.WebSocketURL, &viz.ResultPath,
+		&viz.CreatedAt, &viz.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	viz.Status = domain.VisualizationStatus(status)
+	return &viz, nil
+}
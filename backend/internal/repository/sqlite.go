@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	_ "modernc.org/sqlite"
+)
+
+var sqliteMigrations = []string{
+	`CREATE TABLE simulations (
+		id           TEXT PRIMARY KEY,
+		name         TEXT NOT NULL,
+		type         TEXT NOT NULL,
+		status       TEXT NOT NULL,
+		pod_name     TEXT NOT NULL DEFAULT '',
+		result_path  TEXT NOT NULL DEFAULT '',
+		config_path  TEXT NOT NULL DEFAULT '',
+		created_at   DATETIME NOT NULL,
+		started_at   DATETIME,
+		completed_at DATETIME
+	)`,
+	`CREATE TABLE visualizations (
+		id            TEXT PRIMARY KEY,
+		simulation_id TEXT NOT NULL,
+		status        TEXT NOT NULL,
+		pod_name      TEXT NOT NULL DEFAULT '',
+		websocket_url TEXT NOT NULL DEFAULT '',
+		result_path   TEXT NOT NULL DEFAULT '',
+		created_at    DATETIME NOT NULL,
+		updated_at    DATETIME NOT NULL
+	)`,
+	`CREATE INDEX idx_visualizations_simulation_id ON visualizations (simulation_id)`,
+	`ALTER TABLE simulations ADD COLUMN status_reason TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE simulations ADD COLUMN divergence_rules TEXT NOT NULL DEFAULT '{}'`,
+	`ALTER TABLE simulations ADD COLUMN parent_id TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE simulations ADD COLUMN resources TEXT NOT NULL DEFAULT '{}'`,
+	`ALTER TABLE simulations ADD COLUMN subdomains INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE simulations ADD COLUMN nodes INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE simulations ADD COLUMN solver TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE simulations ADD COLUMN run_plan TEXT NOT NULL DEFAULT '{}'`,
+}
+
+// OpenSQLite opens (or creates) the database file at path and brings its
+// schema up to date.
+func OpenSQLite(path string) (*sql.DB, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create database directory: %w", err)
+	}
+
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer; serialize access instead of fighting SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+
+	if err := migrate(context.Background(), db, sqliteMigrations); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/theweirdfulmurk/cfd-platform/internal/domain"
+)
+
+type SQLiteSimulationRepo struct {
+	db *sql.DB
+}
+
+func NewSQLiteSimulationRepo(db *sql.DB) *SQLiteSimulationRepo {
+	return &SQLiteSimulationRepo{db: db}
+}
+
+func (r *SQLiteSimulationRepo) Create(sim *domain.Simulation) error {
+	_, err := r.db.Exec(
+		`INSERT INTO simulations (`+simulationColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		sim.ID, sim.Name, string(sim.Type), string(sim.Status), sim.PodName, sim.ResultPath, sim.ConfigPath,
+		sim.CreatedAt, nullTime(sim.StartedAt), nullTime(sim.CompletedAt), sim.StatusReason, jsonText(sim.Divergence), sim.ParentID, jsonText(sim.Resources), sim.Subdomains, sim.Nodes, sim.Solver, jsonText(sim.Run),
+	)
+	return err
+}
+
+func (r *SQLiteSimulationRepo) GetByID(id string) (*domain.Simulation, error) {
+	row := r.db.QueryRow(`SELECT `+simulationColumns+` FROM simulations WHERE id = ?`, id)
+
+	sim, err := scanSimulation(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return sim, err
+}
+
+func (r *SQLiteSimulationRepo) List() ([]*domain.Simulation, error) {
+	rows, err := r.db.Query(`SELECT ` + simulationColumns + ` FROM simulations ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]*domain.Simulation, 0)
+	for rows.Next() {
+		sim, err := scanSimulation(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, sim)
+	}
+	return result, rows.Err()
+}
+
+func (r *SQLiteSimulationRepo) Update(sim *domain.Simulation) error {
+	res, err := r.db.Exec(
+		`UPDATE simulations SET name = ?, type = ?, status = ?, pod_name = ?, result_path = ?, config_path = ?,
+			created_at = ?, started_at = ?, completed_at = ?, status_reason = ?, divergence_rules = ?, parent_id = ?, resources = ?, subdomains = ?, nodes = ?, solver = ?, run_plan = ?
+		WHERE id = ?`,
+		sim.Name, string(sim.Type), string(sim.Status), sim.PodName, sim.ResultPath, sim.ConfigPath,
+		sim.CreatedAt, nullTime(sim.StartedAt), nullTime(sim.CompletedAt), sim.StatusReason, jsonText(sim.Divergence), sim.ParentID, jsonText(sim.Resources), sim.Subdomains, sim.Nodes, sim.Solver, jsonText(sim.Run),
+		sim.ID,
+	)
+	if err != nil {
+		return err
+	}
+	return requireAffected(res)
+}
+
+func (r *SQLiteSimulationRepo) Delete(id string) error {
+	_, err := r.db.Exec(`DELETE FROM simulations WHERE id = ?`, id)
+	return err
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/theweirdfulmurk/cfd-platform/internal/domain"
+)
+
+type SQLiteVisualizationRepo struct {
+	db *sql.DB
+}
+
+func NewSQLiteVisualizationRepo(db *sql.DB) *SQLiteVisualizationRepo {
+	return &SQLiteVisualizationRepo{db: db}
+}
+
+func (r *SQLiteVisualizationRepo) Create(viz *domain.Visualization) error {
+	_, err := r.db.Exec(
+		`INSERT INTO visualizations (`+visualizationColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		viz.ID, viz.SimulationID, string(viz.Status), viz.PodName, viz.WebSocketURL, viz.ResultPath,
+		viz.CreatedAt, viz.UpdatedAt,
+	)
+	return err
+}
+
+func (r *SQLiteVisualizationRepo) GetByID(id string) (*domain.Visualization, error) {
+	row := r.db.QueryRow(`SELECT `+visualizationColumns+` FROM visualizations WHERE id = ?`, id)
+
+	viz, err := scanVisualization(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return viz, err
+}
+
+func (r *SQLiteVisualizationRepo) GetBySimulationID(simulationID string) ([]*domain.Visualization, error) {
+	rows, err := r.db.Query(
+		`SELECT `+visualizationColumns+` FROM visualizations WHERE simulation_id = ? ORDER BY created_at`,
+		simulationID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*domain.Visualization
+	for rows.Next() {
+		viz, err := scanVisualization(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, viz)
+	}
+	return result, rows.Err()
+}
+
+func (r *SQLiteVisualizationRepo) Update(viz *domain.Visualization) error {
+	res, err := r.db.Exec(
+		`UPDATE visualizations SET simulation_id = ?, status = ?, pod_name = ?, websocket_url = ?, result_path = ?,
+			created_at = ?, updated_at = ?
+		WHERE id = ?`,
+		viz.SimulationID, string(viz.Status), viz.PodName, viz.WebSocketURL, viz.ResultPath,
+		viz.CreatedAt, viz.UpdatedAt,
+		viz.ID,
+	)
+	if err != nil {
+		return err
+	}
+	return requireAffected(res)
+}
+
+func (r *SQLiteVisualizationRepo) Delete(id string) error {
+	_, err := r.db.Exec(`DELETE FROM visualizations WHERE id = ?`, id)
+	return err
+}
@@ -9,6 +9,8 @@ import (
 
 var ErrNotFound = errors.New("not found")
 
+// InMemoryVisualizationRepo copies records in and out so callers can't mutate
+// stored state outside the lock.
 type InMemoryVisualizationRepo struct {
 	mu   sync.RWMutex
 	data map[string]*domain.Visualization
@@ -23,7 +25,8 @@ func NewInMemoryVisualizationRepo() *InMemoryVisualizationRepo {
 func (r *InMemoryVisualizationRepo) Create(viz *domain.Visualization) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	r.data[viz.ID] = viz
+	stored := *viz
+	r.data[viz.ID] = &stored
 	return nil
 }
 
@@ -35,7 +38,8 @@ func (r *InMemoryVisualizationRepo) GetByID(id string) (*domain.Visualization, e
 	if !exists {
 		return nil, ErrNotFound
 	}
-	return viz, nil
+	copied := *viz
+	return &copied, nil
 }
 
 func (r *InMemoryVisualizationRepo) GetBySimulationID(simulationID string) ([]*domain.Visualization, error) {
@@ -45,7 +49,8 @@ func (r *InMemoryVisualizationRepo) GetBySimulationID(simulationID string) ([]*d
 	var result []*domain.Visualization
 	for _, viz := range r.data {
 		if viz.SimulationID == simulationID {
-			result = append(result, viz)
+			copied := *viz
+			result = append(result, &copied)
 		}
 	}
 	return result, nil
@@ -58,7 +63,8 @@ func (r *InMemoryVisualizationRepo) Update(viz *domain.Visualization) error {
 	if _, exists := r.data[viz.ID]; !exists {
 		return ErrNotFound
 	}
-	r.data[viz.ID] = viz
+	stored := *viz
+	r.data[viz.ID] = &stored
 	return nil
 }
 
@@ -0,0 +1,213 @@
+package runner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ManifestFile is written into Spec.Results once the outputs are staged.
+const ManifestFile = "manifest.json"
+
+// Kinds of result files.
+const (
+	KindTime   = "time"   // a time directory, only in listings
+	KindField  = "field"  // a field in a time directory
+	KindMesh   = "mesh"   // polyMesh files
+	KindLog    = "log"    // solver and utility output
+	KindData   = "data"   // function object output under postProcessing/
+	KindFRD    = "frd"    // CalculiX results
+	KindDAT    = "dat"    // CalculiX printed output
+	KindImage  = "image"  // pictures written by function objects
+	KindReport = "report" // platform reports such as mesh-report.json
+	KindOther  = "other"
+)
+
+// Manifest describes the files in a results directory.
+type Manifest struct {
+	Created time.Time       `json:"created"`
+	Files   []ManifestEntry `json:"files"`
+}
+
+// ManifestEntry is one result file.
+type ManifestEntry struct {
+	Path     string    `json:"path"` // slash-separated, relative to the results directory
+	Kind     string    `json:"kind"`
+	Time     string    `json:"time,omitempty"` // time directory the file belongs to
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+	SHA256   string    `json:"sha256,omitempty"`
+}
+
+var imageExtensions = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true, ".webp": true,
+}
+
+// IsTimeName reports whether name is a numeric time directory, like "0" or
+// "0.005".
+func IsTimeName(name string) bool {
+	_, err := strconv.ParseFloat(name, 64)
+	return err == nil
+}
+
+// FileKind classifies a result file by its slash-separated path relative
+// to the results directory.
+func FileKind(rel string) string {
+	base := path.Base(rel)
+	ext := strings.ToLower(path.Ext(base))
+	first, rest, nested := strings.Cut(rel, "/")
+
+	switch {
+	case imageExtensions[ext]:
+		return KindImage
+	case strings.HasPrefix(base, "log.") || ext == ".log":
+		return KindLog
+	case first == "postProcessing":
+		return KindData
+	case ext == ".frd":
+		return KindFRD
+	case ext == ".dat":
+		return KindDAT
+	case strings.Contains(rel, "polyMesh/"):
+		return KindMesh
+	case nested && IsTimeName(first) && !strings.HasPrefix(rest, "uniform/"):
+		return KindField
+	case !nested && ext == ".json":
+		return KindReport
+	}
+	return KindOther
+}
+
+// timeOf returns the time directory a file is in, if any.
+func timeOf(rel string) string {
+	if first, _, nested := strings.Cut(rel, "/"); nested && IsTimeName(first) {
+		return first
+	}
+	return ""
+}
+
+// BuildManifest lists the regular files in dir with their checksums. The
+// status file, the manifest itself and hidden temporary files are left
+// out, since they change after the manifest is written.
+func BuildManifest(dir string) (*Manifest, error) {
+	return scanResults(dir, nil, true)
+}
+
+// ListResults lists dir like BuildManifest without reading any file: the
+// checksums come from previous for files whose size and modification time
+// are unchanged and are left out for the rest.
+func ListResults(dir string, previous *Manifest) (*Manifest, error) {
+	return scanResults(dir, previous, false)
+}
+
+func scanResults(dir string, previous *Manifest, hash bool) (*Manifest, error) {
+	known := make(map[string]ManifestEntry)
+	if previous != nil {
+		for _, entry := range previous.Files {
+			known[entry.Path] = entry
+		}
+	}
+
+	manifest := &Manifest{Created: time.Now().UTC(), Files: []ManifestEntry{}}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == dir {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == StatusFile || rel == ManifestFile {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		entry := ManifestEntry{
+			Path:     rel,
+			Kind:     FileKind(rel),
+			Time:     timeOf(rel),
+			Size:     info.Size(),
+			Modified: info.ModTime().UTC(),
+		}
+		if old, ok := known[rel]; ok && old.Size == entry.Size && old.Modified.Equal(entry.Modified) {
+			entry.SHA256 = old.SHA256
+		}
+		if entry.SHA256 == "" && hash {
+			if entry.SHA256, err = fileSHA256(p); err != nil {
+				return err
+			}
+		}
+		manifest.Files = append(manifest.Files, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+func fileSHA256(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// WriteManifest stores manifest in dir, replacing any previous one in a
+// single rename.
+func WriteManifest(dir string, manifest *Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	p := filepath.Join(dir, ManifestFile)
+	tmp := filepath.Join(dir, "."+ManifestFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p)
+}
+
+// ReadManifest loads the manifest from dir.
+func ReadManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	manifest := &Manifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
@@ -0,0 +1,346 @@
+package runner
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Multi-node runs start their MPI daemons without SSH. mpirun's remote
+// shell agent is the runner on the launcher, which queues each command in
+// Spec.MPIDir for the runner of the target pod and relays its output and
+// exit status back. The case directory is shared by all pods of the Job,
+// so the pods need no open ports, keys or Kubernetes credentials. The
+// queue is no more trusted than the case itself: whatever can write the
+// case can already change what the run executes.
+//
+// Spec.MPIDir holds, for every worker pod, a directory named after its
+// hostname with
+//
+//	ready       kept by the worker while it waits for commands
+//	<id>.cmd    a command line for /bin/sh, written by the agent
+//	<id>.out    the command's output; created when the command starts
+//	<id>.exit   its exit status, once it has finished
+//	<id>.cancel written by the agent when mpirun stops it
+//
+// and the launcher writes "done" when it has finished.
+const (
+	// RshCommand makes the runner act as mpirun's remote shell agent:
+	// "cfd-runner rsh <host> <command...>".
+	RshCommand = "rsh"
+
+	doneFile  = "done"
+	readyFile = "ready"
+
+	pollInterval = time.Second
+	// startTimeout bounds the wait for a worker to pick up a command.
+	startTimeout = 2 * time.Minute
+)
+
+func queueDir(mpiDir, host string) string {
+	// mpirun passes on the names of the hostfile, which are qualified
+	// with the Job's Service.
+	name, _, _ := strings.Cut(host, ".")
+	return filepath.Join(mpiDir, name)
+}
+
+// releaseWorkers writes the done marker. The launcher's start time tells
+// this attempt's marker from any other.
+func releaseWorkers(mpiDir string, exitCode int, start time.Time) {
+	marker := fmt.Sprintf("%d %d\n", exitCode, start.UnixNano())
+	err := os.MkdirAll(mpiDir, 0755)
+	if err == nil {
+		err = writeAtomic(filepath.Join(mpiDir, doneFile), []byte(marker))
+	}
+	if err != nil {
+		log.Printf("failed to release workers: %v", err)
+	}
+}
+
+// workerReady reports whether the worker with the given hostname waits for
+// commands.
+func workerReady(mpiDir, host string) bool {
+	_, err := os.Stat(filepath.Join(queueDir(mpiDir, host), readyFile))
+	return err == nil
+}
+
+// writeAtomic writes a file so that readers see all of it or nothing.
+func writeAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// serveWorker runs the commands queued for this pod until the launcher is
+// done. Workers always succeed, so the Job's outcome is the launcher's. A
+// done marker already there when the worker starts belongs to an earlier
+// attempt.
+func serveWorker(mpiDir string) int {
+	host, err := os.Hostname()
+	if err != nil {
+		log.Printf("failed to get the hostname: %v", err)
+		return 1
+	}
+	queue := queueDir(mpiDir, host)
+	stale, _ := os.ReadFile(filepath.Join(mpiDir, doneFile))
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(signals)
+
+	w := &worker{
+		queue:   queue,
+		started: make(map[string]*exec.Cmd),
+		seen:    make(map[string]bool),
+		exited:  make(chan string),
+	}
+	defer w.killAll()
+
+	log.Printf("worker waiting for the launcher to finish")
+	for {
+		if marker, err := os.ReadFile(filepath.Join(mpiDir, doneFile)); err == nil && len(marker) > 0 && !bytes.Equal(marker, stale) {
+			return 0
+		}
+		// The launcher clears the directory when it starts, so the
+		// worker keeps announcing itself.
+		if err := w.announce(); err != nil {
+			log.Printf("failed to announce the worker: %v", err)
+		}
+		w.poll()
+
+		select {
+		case sig := <-signals:
+			log.Printf("received %s, leaving the launcher", sig)
+			return 128 + int(sig.(syscall.Signal))
+		case id := <-w.exited:
+			delete(w.started, id)
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+type worker struct {
+	queue   string
+	started map[string]*exec.Cmd // running commands by id
+	seen    map[string]bool
+	exited  chan string
+}
+
+func (w *worker) announce() error {
+	if err := os.MkdirAll(w.queue, 0755); err != nil {
+		return err
+	}
+	ready := filepath.Join(w.queue, readyFile)
+	if _, err := os.Stat(ready); err == nil {
+		return nil
+	}
+	return os.WriteFile(ready, nil, 0644)
+}
+
+// poll starts new commands and stops cancelled ones.
+func (w *worker) poll() {
+	commands, _ := filepath.Glob(filepath.Join(w.queue, "*.cmd"))
+	for _, path := range commands {
+		id := strings.TrimSuffix(filepath.Base(path), ".cmd")
+		if w.seen[id] {
+			continue
+		}
+		w.seen[id] = true
+		if err := w.start(id); err != nil {
+			log.Printf("failed to run command %s: %v", id, err)
+			writeAtomic(w.path(id, ".exit"), []byte("127\n"))
+		}
+	}
+	for id, cmd := range w.started {
+		if _, err := os.Stat(w.path(id, ".cancel")); err == nil {
+			cmd.Process.Signal(syscall.SIGTERM)
+		}
+	}
+}
+
+func (w *worker) path(id, suffix string) string {
+	return filepath.Join(w.queue, id+suffix)
+}
+
+// start runs a queued command under /bin/sh, as sshd would.
+func (w *worker) start(id string) error {
+	command, err := os.ReadFile(w.path(id, ".cmd"))
+	if err != nil {
+		return err
+	}
+	out, err := os.Create(w.path(id, ".out"))
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command("/bin/sh", "-c", string(command))
+	cmd.Stdout = out
+	cmd.Stderr = out
+	if err := cmd.Start(); err != nil {
+		out.Close()
+		return err
+	}
+	log.Printf("running %s", command)
+	w.started[id] = cmd
+
+	go func() {
+		status := waitStatus(cmd.Wait())
+		out.Close()
+		if err := writeAtomic(w.path(id, ".exit"), []byte(strconv.Itoa(status)+"\n")); err != nil {
+			log.Printf("failed to report the exit status of %s: %v", id, err)
+		}
+		w.exited <- id
+	}()
+	return nil
+}
+
+func (w *worker) killAll() {
+	for _, cmd := range w.started {
+		cmd.Process.Kill()
+	}
+}
+
+// waitStatus turns the result of exec.Cmd.Wait into an exit status the way
+// a shell does.
+func waitStatus(err error) int {
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			return 128 + int(ws.Signal())
+		}
+		return exitErr.ExitCode()
+	case err != nil:
+		return 127
+	}
+	return 0
+}
+
+// Rsh runs a command on another pod of the Job for mpirun and returns its
+// exit status, or 255 if it couldn't be started, as ssh does. The command's
+// output is relayed to stderr while it runs.
+func Rsh(args []string) int {
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		args = args[1:]
+	}
+	if len(args) < 2 {
+		log.Printf("usage: %s <host> <command...>", RshCommand)
+		return 255
+	}
+	host, command := args[0], strings.Join(args[1:], " ")
+
+	// mpirun passes on the runner's environment, and with it the spec.
+	spec, err := DecodeSpec(os.Getenv(SpecEnv))
+	if err != nil {
+		log.Print(err)
+		return 255
+	}
+	if spec.MPIDir == "" {
+		log.Printf("the run spec has no MPI directory")
+		return 255
+	}
+
+	return remoteRun(spec.MPIDir, host, command, os.Stderr)
+}
+
+// remoteRun queues command for host and waits for its exit status, copying
+// its output to output.
+func remoteRun(mpiDir, host, command string, output io.Writer) int {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(signals)
+
+	queue := queueDir(mpiDir, host)
+	if err := os.MkdirAll(queue, 0755); err != nil {
+		log.Printf("failed to queue a command for %s: %v", host, err)
+		return 255
+	}
+	tmp, err := os.CreateTemp(queue, "cmd-*")
+	if err != nil {
+		log.Printf("failed to queue a command for %s: %v", host, err)
+		return 255
+	}
+	id := filepath.Base(tmp.Name())
+	_, err = io.WriteString(tmp, command)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(queue, id+".cmd"))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		log.Printf("failed to queue a command for %s: %v", host, err)
+		return 255
+	}
+
+	var out *os.File
+	defer func() {
+		if out != nil {
+			out.Close()
+		}
+	}()
+	deadline := time.Now().Add(startTimeout)
+	for {
+		if out == nil {
+			if out, err = os.Open(filepath.Join(queue, id+".out")); err != nil {
+				out = nil
+			}
+		}
+		if out != nil {
+			io.Copy(output, out)
+		}
+
+		exit, err := os.ReadFile(filepath.Join(queue, id+".exit"))
+		if err == nil {
+			if out != nil {
+				io.Copy(output, out)
+			}
+			status, err := strconv.Atoi(strings.TrimSpace(string(exit)))
+			if err != nil {
+				log.Printf("invalid exit status from %s: %q", host, exit)
+				return 255
+			}
+			return status
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("failed to read the exit status from %s: %v", host, err)
+			return 255
+		}
+		if out == nil && time.Now().After(deadline) {
+			os.Remove(filepath.Join(queue, id+".cmd"))
+			log.Printf("%s did not pick up the command within %s", host, startTimeout)
+			return 255
+		}
+
+		select {
+		case sig := <-signals:
+			os.WriteFile(filepath.Join(queue, id+".cancel"), nil, 0644)
+			return 128 + int(sig.(syscall.Signal))
+		case <-time.After(pollInterval):
+		}
+	}
+}
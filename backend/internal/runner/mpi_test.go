@@ -0,0 +1,50 @@
+package runner
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRemoteRun(t *testing.T) {
+	mpiDir := t.TempDir()
+	host, err := os.Hostname()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A marker of an earlier attempt must not release the worker.
+	if err := os.WriteFile(filepath.Join(mpiDir, doneFile), []byte("143 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	exited := make(chan int, 1)
+	go func() { exited <- serveWorker(mpiDir) }()
+
+	var output bytes.Buffer
+	if status := remoteRun(mpiDir, host+".sim-1", "echo started; echo $0 >&2; exit 3", &output); status != 3 {
+		t.Errorf("status = %d, want 3", status)
+	}
+	if got := output.String(); got != "started\n/bin/sh\n" {
+		t.Errorf("output = %q", got)
+	}
+	if !workerReady(mpiDir, host+".sim-1") {
+		t.Error("the worker doesn't announce itself")
+	}
+	select {
+	case status := <-exited:
+		t.Fatalf("worker left with %d before the launcher was done", status)
+	default:
+	}
+
+	releaseWorkers(mpiDir, 0, time.Now())
+	select {
+	case status := <-exited:
+		if status != 0 {
+			t.Errorf("worker exited with %d, want 0", status)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("worker kept waiting after the launcher was done")
+	}
+}
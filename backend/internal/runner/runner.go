@@ -0,0 +1,553 @@
+package runner
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/theweirdfulmurk/cfd-platform/internal/casefile"
+	"github.com/theweirdfulmurk/cfd-platform/internal/openfoam"
+)
+
+const (
+	terminationLog = "/dev/termination-log"
+
+	// hostTimeout bounds the wait for the pods of a multi-node run.
+	hostTimeout = 10 * time.Minute
+)
+
+// Run executes spec and returns the exit code for the container. Stage
+// output goes to stdout and stderr, where the platform picks up the logs.
+func Run(spec *Spec) int {
+	if spec.MPIDir != "" && isWorker() {
+		return serveWorker(spec.MPIDir)
+	}
+
+	if spec.MPIDir != "" {
+		// Commands and the done marker of an earlier attempt, e.g. one
+		// stopped when the Job was suspended, must not reach this
+		// attempt's workers.
+		if err := os.RemoveAll(spec.MPIDir); err != nil {
+			log.Printf("failed to clear the MPI directory of an earlier attempt: %v", err)
+		}
+	}
+
+	r := &run{spec: spec, dir: spec.Root}
+	start := time.Now()
+	exitCode := r.execute()
+
+	// A stopped launcher leaves its workers to be stopped with it.
+	if spec.MPIDir != "" && !r.stopped {
+		releaseWorkers(spec.MPIDir, exitCode, start)
+	}
+	return exitCode
+}
+
+type run struct {
+	spec    *Spec
+	dir     string
+	status  Status
+	stopped bool // a termination signal arrived
+}
+
+// stop records that sig stopped the run and returns the matching exit code.
+func (r *run) stop(sig os.Signal) int {
+	r.stopped = true
+	return 128 + int(sig.(syscall.Signal))
+}
+
+func (r *run) execute() int {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(signals)
+
+	if err := r.checkDir(r.spec.Root); err != nil {
+		return r.finish(r.fail("setup", err))
+	}
+	if err := os.MkdirAll(r.spec.Results, 0755); err != nil {
+		return r.finish(r.fail("setup", err))
+	}
+
+	skips, err := r.conditions()
+	if err != nil {
+		return r.finish(r.fail("setup", err))
+	}
+	r.completedEarlier(skips)
+
+	// After a failure or a signal only the stages marked Always run; the
+	// run keeps the exit code of what stopped it.
+	exitCode := 0
+	for i, stage := range r.spec.Stages {
+		if exitCode == 0 {
+			select {
+			case sig := <-signals:
+				log.Printf("received %s, not starting stage %s", sig, stage.Name)
+				exitCode = r.stop(sig)
+			default:
+			}
+		}
+		if exitCode != 0 && !stage.Always {
+			continue
+		}
+
+		start := time.Now()
+		result := StageResult{Name: stage.Name}
+
+		switch {
+		case skips[i] != "":
+			result.Skipped = true
+			log.Printf("stage %s skipped: %s", stage.Name, skips[i])
+		default:
+			log.Printf("stage %s started", stage.Name)
+			result.ExitCode, err = r.runStage(stage, signals)
+			if err != nil {
+				result.Error = err.Error()
+			}
+		}
+		result.DurationSeconds = time.Since(start).Seconds()
+
+		r.status.Stages = append(r.status.Stages, result)
+		r.writeStatus()
+		log.Printf("stage %s finished with exit code %d", stage.Name, result.ExitCode)
+
+		if exitCode == 0 {
+			exitCode = result.ExitCode
+		}
+	}
+	return r.finish(exitCode)
+}
+
+func (r *run) fail(stage string, err error) int {
+	r.status.Stages = append(r.status.Stages, StageResult{Name: stage, ExitCode: 1, Error: err.Error()})
+	return 1
+}
+
+// finish records the final status. The summary also goes to the container's
+// termination message so it shows up in the pod status.
+func (r *run) finish(exitCode int) int {
+	r.status.Finished = true
+	r.status.ExitCode = exitCode
+	r.writeStatus()
+
+	if data, err := json.Marshal(r.status); err == nil {
+		os.WriteFile(terminationLog, data, 0644)
+	}
+	return exitCode
+}
+
+func (r *run) writeStatus() {
+	data, err := json.MarshalIndent(r.status, "", "  ")
+	if err != nil {
+		return
+	}
+	path := filepath.Join(r.spec.Results, StatusFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		log.Printf("failed to write %s: %v", StatusFile, err)
+	}
+}
+
+// conditions decides up front which stages to skip and why.
+func (r *run) conditions() ([]string, error) {
+	skips := make([]string, len(r.spec.Stages))
+	for i, stage := range r.spec.Stages {
+		if stage.If != "" {
+			found, err := r.exists(stage.If)
+			if err != nil {
+				return nil, err
+			}
+			if !found {
+				skips[i] = "no " + stage.If
+				continue
+			}
+		}
+		if stage.Unless != "" {
+			found, err := r.exists(stage.Unless)
+			if err != nil {
+				return nil, err
+			}
+			if found {
+				skips[i] = stage.Unless + " exists"
+			}
+		}
+	}
+	return skips, nil
+}
+
+// completedEarlier skips the stages that an earlier attempt of the run
+// completed before it was stopped, e.g. when the Job was suspended, so a
+// resumed run picks up at the stage that was interrupted. The hostfile is
+// written again for the new pods, and stages marked Always run anyway.
+func (r *run) completedEarlier(skips []string) {
+	data, err := os.ReadFile(filepath.Join(r.spec.Results, StatusFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	var earlier Status
+	if err == nil {
+		err = json.Unmarshal(data, &earlier)
+	}
+	if err != nil {
+		log.Printf("running every stage, the earlier attempt's status is unusable: %v", err)
+		return
+	}
+
+	for i, result := range earlier.Stages {
+		if i >= len(r.spec.Stages) || result.Name != r.spec.Stages[i].Name || result.ExitCode != 0 {
+			return
+		}
+		if stage := r.spec.Stages[i]; !stage.Always && stage.Builtin != BuiltinHostfile {
+			skips[i] = "completed in an earlier attempt"
+		}
+	}
+}
+
+// exists reports whether pattern matches a path inside the simulation
+// directory.
+func (r *run) exists(pattern string) (bool, error) {
+	matches, err := filepath.Glob(filepath.Join(r.dir, pattern))
+	if err != nil || len(matches) == 0 {
+		return false, err
+	}
+	return true, r.checkDir(matches[0])
+}
+
+func (r *run) runStage(stage Stage, signals <-chan os.Signal) (int, error) {
+	switch stage.Builtin {
+	case "":
+		return r.exec(stage, signals)
+	case BuiltinDecomposeDict:
+		return exitCode(r.decomposeDict(stage.Args))
+	case BuiltinHostfile:
+		return r.hostfile(stage.Args, signals)
+	case BuiltinCopyDir:
+		return exitCode(r.copyDir(stage.Args))
+	case BuiltinMeshReport:
+		r.meshReport()
+		return 0, nil
+	case BuiltinStageResults:
+		return exitCode(r.stageResults(stage.Args))
+	default:
+		return 1, fmt.Errorf("unknown builtin %q", stage.Builtin)
+	}
+}
+
+func exitCode(err error) (int, error) {
+	if err != nil {
+		return 1, err
+	}
+	return 0, nil
+}
+
+// exec runs the stage's program and forwards termination signals to it, so
+// suspending or cancelling the Job stops the solver promptly.
+func (r *run) exec(stage Stage, signals <-chan os.Signal) (int, error) {
+	args, err := r.expand(stage.Args)
+	if err != nil {
+		return 1, err
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = r.dir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return 127, err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	for {
+		select {
+		case sig := <-signals:
+			r.stop(sig)
+			cmd.Process.Signal(sig)
+		case err := <-done:
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+					return 128 + int(ws.Signal()), nil
+				}
+				return exitErr.ExitCode(), nil
+			}
+			if err != nil {
+				return 1, err
+			}
+			return 0, nil
+		}
+	}
+}
+
+func (r *run) expand(args []string) ([]string, error) {
+	expanded := make([]string, len(args))
+	for i, arg := range args {
+		switch arg {
+		case Application:
+			controlDict, err := os.ReadFile(filepath.Join(r.dir, "system", "controlDict"))
+			if err != nil {
+				return nil, err
+			}
+			app, ok := openfoam.TopLevelEntry(controlDict, "application")
+			if !ok || app == "" || strings.ContainsAny(app, `/\`) {
+				return nil, fmt.Errorf("controlDict names no usable application")
+			}
+			expanded[i] = app
+		case WorkDir:
+			expanded[i] = r.dir
+		default:
+			expanded[i] = arg
+		}
+	}
+	return expanded, nil
+}
+
+func (r *run) decomposeDict(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("decompose-dict needs the number of subdomains")
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 2 {
+		return fmt.Errorf("invalid number of subdomains %q", args[0])
+	}
+
+	path := filepath.Join(r.dir, "system", "decomposeParDict")
+	if err := r.checkDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		content = []byte("FoamFile\n{\n    version 2.0;\n    format ascii;\n    class dictionary;\n    object decomposeParDict;\n}\n")
+	} else if err != nil {
+		return err
+	} else if info, err := os.Lstat(path); err != nil || !info.Mode().IsRegular() {
+		return fmt.Errorf("system/decomposeParDict is not a regular file")
+	}
+
+	// scotch needs no per-direction coefficients, whatever the case used.
+	content = openfoam.SetTopLevelEntry(content, "numberOfSubdomains", strconv.Itoa(n))
+	content = openfoam.SetTopLevelEntry(content, "method", "scotch")
+	return os.WriteFile(path, content, 0644)
+}
+
+// hostfile lists the pods of an Indexed Job by their stable hostnames and
+// waits until each resolves, which happens once the pod has an address,
+// and until the runners of the other pods wait for commands. Pods that
+// aren't ready within hostTimeout fail the stage, as does a termination
+// signal.
+func (r *run) hostfile(args []string, signals <-chan os.Signal) (int, error) {
+	if len(args) != 3 {
+		return 1, fmt.Errorf("hostfile needs the job name, pod count and slots per pod")
+	}
+	if r.spec.MPIDir == "" {
+		return 1, fmt.Errorf("hostfile needs a run spec with an MPI directory")
+	}
+	job := args[0]
+	nodes, err1 := strconv.Atoi(args[1])
+	slots, err2 := strconv.Atoi(args[2])
+	if err1 != nil || err2 != nil || nodes < 1 || slots < 1 {
+		return 1, fmt.Errorf("invalid hostfile arguments %q", args)
+	}
+
+	var b strings.Builder
+	deadline := time.After(hostTimeout)
+	for i := 0; i < nodes; i++ {
+		host := fmt.Sprintf("%s-%d.%s", job, i, job)
+		for {
+			_, err := net.LookupHost(host)
+			if err == nil && (i == 0 || workerReady(r.spec.MPIDir, host)) {
+				break
+			}
+			select {
+			case sig := <-signals:
+				return r.stop(sig), fmt.Errorf("received %s while waiting for %s", sig, host)
+			case <-deadline:
+				return 1, fmt.Errorf("%s was not ready within %s", host, hostTimeout)
+			case <-time.After(2 * time.Second):
+			}
+		}
+		fmt.Fprintf(&b, "%s slots=%d\n", host, slots)
+	}
+	return exitCode(os.WriteFile(filepath.Join(r.dir, "hostfile"), []byte(b.String()), 0644))
+}
+
+// copyDir copies a directory of the case, such as 0.orig to 0.
+func (r *run) copyDir(args []string) error {
+	if len(args) != 2 || !IsLocal(args[0]) || !IsLocal(args[1]) {
+		return fmt.Errorf("copy-dir needs a source and a destination inside the case")
+	}
+	src, dst := filepath.Join(r.dir, args[0]), filepath.Join(r.dir, args[1])
+	if err := r.checkDir(src); err != nil {
+		return err
+	}
+	if info, err := os.Lstat(src); err != nil || !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", args[0])
+	}
+	if _, err := os.Lstat(dst); err == nil {
+		return fmt.Errorf("%s already exists", args[1])
+	}
+
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(filepath.Join(dst, rel), 0755)
+		case d.Type().IsRegular():
+			return copyFile(path, filepath.Join(dst, rel))
+		}
+		return nil
+	})
+}
+
+// meshReport analyses the mesh the previous stages built, the same way the
+// backend does for meshes that come with the upload. The report is only
+// informational: when it can't be made the problem is logged and the run
+// goes on, leaving it to the solver to reject the mesh.
+func (r *run) meshReport() {
+	report, err := openfoam.AnalyzeCaseMesh(r.dir)
+	if err != nil {
+		log.Printf("no mesh report: %v", err)
+		return
+	}
+	if !report.OK() {
+		log.Printf("mesh failed checks: %s", strings.Join(report.FailedChecks, ", "))
+	}
+	if err := openfoam.WriteMeshReport(r.spec.Results, report); err != nil {
+		log.Printf("failed to write the mesh report: %v", err)
+	}
+}
+
+// stageResults copies the run's outputs into the results directory under
+// their paths in the case and writes the manifest. Symlinks are not
+// followed; time directories of processors stay behind.
+func (r *run) stageResults(patterns []string) error {
+	var matches []string
+	for _, pattern := range patterns {
+		if pattern == TimeDirs {
+			times, err := r.timeDirs()
+			if err != nil {
+				return err
+			}
+			matches = append(matches, times...)
+			continue
+		}
+		if !IsLocal(pattern) {
+			return fmt.Errorf("pattern %q leaves the working directory", pattern)
+		}
+		found, err := filepath.Glob(filepath.Join(r.dir, pattern))
+		if err != nil {
+			return err
+		}
+		matches = append(matches, found...)
+	}
+
+	for _, match := range matches {
+		if err := r.checkDir(match); err != nil {
+			return err
+		}
+		err := filepath.WalkDir(match, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			rel, err := filepath.Rel(r.dir, path)
+			if err != nil {
+				return err
+			}
+			dst := filepath.Join(r.spec.Results, rel)
+			if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+				return err
+			}
+			if err := copyFile(path, dst); err != nil {
+				return err
+			}
+			// Keep the solver's write times for the file listing.
+			if info, err := d.Info(); err == nil {
+				os.Chtimes(dst, info.ModTime(), info.ModTime())
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	manifest, err := BuildManifest(r.spec.Results)
+	if err != nil {
+		return err
+	}
+	log.Printf("staged %d result files", len(manifest.Files))
+	return WriteManifest(r.spec.Results, manifest)
+}
+
+// timeDirs lists the numeric time directories of the case.
+func (r *run) timeDirs() ([]string, error) {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, entry := range entries {
+		if entry.IsDir() && IsTimeName(entry.Name()) {
+			dirs = append(dirs, filepath.Join(r.dir, entry.Name()))
+		}
+	}
+	return dirs, nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// checkDir makes sure path, with symlinks resolved, is still inside the
+// simulation directory. Uploaded archives may contain links pointing
+// anywhere.
+func (r *run) checkDir(path string) error {
+	_, err := casefile.Resolve(r.spec.Root, path)
+	if errors.Is(err, casefile.ErrOutside) {
+		return fmt.Errorf("%s leaves the simulation directory", path)
+	}
+	return err
+}
+
+func isWorker() bool {
+	index := os.Getenv("JOB_COMPLETION_INDEX")
+	return index != "" && index != "0"
+}
@@ -0,0 +1,123 @@
+package runner
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeStatusFile(t *testing.T, results string, status Status) {
+	t.Helper()
+	data, err := json.Marshal(status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(results, StatusFile), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompletedEarlier(t *testing.T) {
+	stages := []Stage{
+		{Name: "decomposeParDict", Builtin: BuiltinDecomposeDict, Args: []string{"4"}},
+		{Name: "decompose", Args: []string{"decomposePar", "-force"}},
+		{Name: "hostfile", Builtin: BuiltinHostfile, Args: []string{"sim-1", "2", "2"}},
+		{Name: "solve", Args: []string{"mpirun", "icoFoam", "-parallel"}},
+		{Name: "stageResults", Builtin: BuiltinStageResults, Always: true},
+	}
+	const done = "completed in an earlier attempt"
+
+	tests := []struct {
+		name    string
+		earlier *Status
+		want    []string
+	}{
+		{
+			name: "first attempt",
+			want: []string{"", "", "", "", ""},
+		},
+		{
+			name: "stopped in the solver",
+			earlier: &Status{Stages: []StageResult{
+				{Name: "decomposeParDict"}, {Name: "decompose"}, {Name: "hostfile"},
+				{Name: "solve", ExitCode: 143}, {Name: "stageResults"},
+			}},
+			want: []string{done, done, "", "", ""},
+		},
+		{
+			name: "stopped again after a resume",
+			earlier: &Status{Stages: []StageResult{
+				{Name: "decomposeParDict", Skipped: true}, {Name: "decompose", Skipped: true}, {Name: "hostfile"},
+				{Name: "solve", ExitCode: 143}, {Name: "stageResults"},
+			}},
+			want: []string{done, done, "", "", ""},
+		},
+		{
+			name: "stopped before the first stage",
+			earlier: &Status{Stages: []StageResult{
+				{Name: "stageResults"},
+			}},
+			want: []string{"", "", "", "", ""},
+		},
+		{
+			name: "setup failed",
+			earlier: &Status{Stages: []StageResult{
+				{Name: "setup", ExitCode: 1},
+			}},
+			want: []string{"", "", "", "", ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := t.TempDir()
+			if tt.earlier != nil {
+				writeStatusFile(t, results, *tt.earlier)
+			}
+			r := &run{spec: &Spec{Results: results, Stages: stages}}
+			skips := make([]string, len(stages))
+			r.completedEarlier(skips)
+			if !reflect.DeepEqual(skips, tt.want) {
+				t.Errorf("skips = %q, want %q", skips, tt.want)
+			}
+		})
+	}
+}
+
+// A resumed run reruns the stage that was interrupted and everything after
+// it, but not what the stopped attempt completed.
+func TestResumedRun(t *testing.T) {
+	root, results := t.TempDir(), t.TempDir()
+	spec := &Spec{
+		Root:    root,
+		Results: results,
+		Stages: []Stage{
+			{Name: "decompose", Args: []string{"sh", "-c", "echo x >> decomposed"}},
+			{Name: "solve", Args: []string{"sh", "-c", "echo x >> solved"}},
+			{Name: "stageResults", Builtin: BuiltinStageResults, Args: []string{"decomposed", "solved"}, Always: true},
+		},
+	}
+	writeStatusFile(t, results, Status{
+		Stages:   []StageResult{{Name: "decompose"}, {Name: "solve", ExitCode: 143}, {Name: "stageResults"}},
+		Finished: true,
+		ExitCode: 143,
+	})
+	if err := os.WriteFile(filepath.Join(root, "decomposed"), []byte("x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &run{spec: spec, dir: root}
+	if code := r.execute(); code != 0 {
+		t.Fatalf("exit code %d, stages %+v", code, r.status.Stages)
+	}
+	for _, name := range []string{"decomposed", "solved"} {
+		if got, _ := os.ReadFile(filepath.Join(results, name)); string(got) != "x\n" {
+			t.Errorf("%s = %q, want one run", name, got)
+		}
+	}
+	if !r.status.Stages[0].Skipped || r.status.Stages[1].Skipped {
+		t.Errorf("stages = %+v", r.status.Stages)
+	}
+}
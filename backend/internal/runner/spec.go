@@ -0,0 +1,197 @@
+// Package runner is the entrypoint of solver containers. The backend
+// describes a run as a Spec of stages; the runner executes them without a
+// shell, keeps every path inside the simulation directory and records each
+// stage's exit code in stages.json.
+package runner
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// SpecEnv carries the JSON encoded Spec into the container.
+	SpecEnv = "CFD_RUN_SPEC"
+	// StatusFile is written into Spec.Results after every stage. A run
+	// that finds one from an earlier attempt skips the stages that attempt
+	// completed.
+	StatusFile = "stages.json"
+
+	// SimulationsDir and ResultsDir are where the solver pods mount the
+	// volumes; Spec.Root and Spec.Results must stay inside them.
+	SimulationsDir = "/pvc/simulations"
+	ResultsDir     = "/results"
+)
+
+// Placeholders substituted in stage arguments when the stage starts.
+const (
+	// Application is the solver named in the case's system/controlDict.
+	Application = "{application}"
+	// WorkDir is the absolute working directory of the stage.
+	WorkDir = "{workdir}"
+	// TimeDirs stands for the numeric time directories of an OpenFOAM case
+	// in the patterns of BuiltinStageResults.
+	TimeDirs = "{times}"
+)
+
+// Built-in stages, selected with Stage.Builtin.
+const (
+	// BuiltinDecomposeDict sets numberOfSubdomains to Args[0] and the scotch
+	// method in system/decomposeParDict, creating the file if needed.
+	BuiltinDecomposeDict = "decompose-dict"
+	// BuiltinHostfile writes "hostfile" for an Indexed Job named Args[0]
+	// with Args[1] pods and Args[2] slots each, and waits until every
+	// hostname resolves and every other pod waits for commands.
+	BuiltinHostfile = "hostfile"
+	// BuiltinCopyDir copies the directory Args[0] to Args[1], which must
+	// not exist yet; both are relative to the simulation directory.
+	// Symlinks are not copied.
+	BuiltinCopyDir = "copy-dir"
+	// BuiltinMeshReport analyses constant/polyMesh and writes the mesh
+	// report into Spec.Results. It never fails the run.
+	BuiltinMeshReport = "mesh-report"
+	// BuiltinStageResults copies the files and directories matching the
+	// glob patterns in Args into Spec.Results, keeping their paths relative
+	// to the simulation directory, and writes ManifestFile there.
+	BuiltinStageResults = "stage-results"
+)
+
+// Spec is a complete solver run.
+type Spec struct {
+	Root    string  `json:"root"`    // simulation directory, the initial working directory
+	Results string  `json:"results"` // receives stages.json and collected files
+	Stages  []Stage `json:"stages"`
+	// MPIDir is set for multi-node runs: a directory of the case through
+	// which the launcher (completion index 0) hands commands to the other
+	// pods. They run the launcher's MPI daemons until it is done and then
+	// exit successfully.
+	MPIDir string `json:"mpiDir,omitempty"`
+}
+
+// Stage is one step of a run. Args are passed to the program as they are,
+// without a shell.
+type Stage struct {
+	Name    string   `json:"name"`
+	Builtin string   `json:"builtin,omitempty"`
+	Args    []string `json:"args,omitempty"`
+	// If and Unless are glob patterns relative to the simulation directory.
+	// The stage runs only when If matches something and Unless matches
+	// nothing. Both are checked against the case as uploaded, before the
+	// first stage runs, so earlier stages don't change the outcome.
+	If     string `json:"if,omitempty"`
+	Unless string `json:"unless,omitempty"`
+	// Always runs the stage even after an earlier stage failed or the run
+	// was stopped, e.g. to stage whatever results there are.
+	Always bool `json:"always,omitempty"`
+}
+
+// StageResult is the machine-readable outcome of a stage.
+type StageResult struct {
+	Name            string  `json:"name"`
+	ExitCode        int     `json:"exitCode"`
+	Skipped         bool    `json:"skipped,omitempty"`
+	Error           string  `json:"error,omitempty"`
+	DurationSeconds float64 `json:"durationSeconds"`
+}
+
+// Status is the content of stages.json.
+type Status struct {
+	Stages   []StageResult `json:"stages"`
+	Finished bool          `json:"finished"`
+	ExitCode int           `json:"exitCode"`
+}
+
+// Failed returns the first stage that didn't succeed.
+func (s *Status) Failed() *StageResult {
+	for i := range s.Stages {
+		if s.Stages[i].ExitCode != 0 {
+			return &s.Stages[i]
+		}
+	}
+	return nil
+}
+
+// Encode returns the value of SpecEnv for spec.
+func (s *Spec) Encode() (string, error) {
+	data, err := json.Marshal(s)
+	return string(data), err
+}
+
+// DecodeSpec parses and validates a Spec.
+func DecodeSpec(data string) (*Spec, error) {
+	spec := &Spec{}
+	if err := json.Unmarshal([]byte(data), spec); err != nil {
+		return nil, fmt.Errorf("invalid run spec: %w", err)
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// Validate checks the spec's paths lexically. The runner checks them again
+// against the real filesystem, following symlinks.
+func (s *Spec) Validate() error {
+	if !Within(SimulationsDir, s.Root) || s.Root == SimulationsDir {
+		return fmt.Errorf("root %q is not a simulation directory", s.Root)
+	}
+	if !Within(ResultsDir, s.Results) {
+		return fmt.Errorf("results %q is outside %s", s.Results, ResultsDir)
+	}
+	if s.MPIDir != "" && (!Within(s.Root, s.MPIDir) || s.MPIDir == s.Root) {
+		return fmt.Errorf("MPI directory %q is not inside the simulation directory", s.MPIDir)
+	}
+	if len(s.Stages) == 0 {
+		return errors.New("run spec has no stages")
+	}
+	for _, stage := range s.Stages {
+		switch {
+		case stage.Name == "":
+			return errors.New("stage without a name")
+		case stage.Builtin == "" && len(stage.Args) == 0:
+			return fmt.Errorf("stage %s has nothing to run", stage.Name)
+		case stage.If != "" && !IsLocal(stage.If):
+			return fmt.Errorf("stage %s: %q is not a path inside the case", stage.Name, stage.If)
+		case stage.Unless != "" && !IsLocal(stage.Unless):
+			return fmt.Errorf("stage %s: %q is not a path inside the case", stage.Name, stage.Unless)
+		}
+		for _, pattern := range []string{stage.If, stage.Unless} {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return fmt.Errorf("stage %s: invalid pattern %q", stage.Name, pattern)
+			}
+		}
+	}
+	return nil
+}
+
+// Within reports whether path is dir or lies below it. Both must be
+// absolute; the check is lexical.
+func Within(dir, path string) bool {
+	if !filepath.IsAbs(dir) || !filepath.IsAbs(path) {
+		return false
+	}
+	rel, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(path))
+	return err == nil && IsLocal(rel)
+}
+
+// IsLocal reports whether a relative path stays below its base directory.
+func IsLocal(path string) bool {
+	return path != "" && filepath.IsLocal(path) && !strings.ContainsRune(path, 0)
+}
+
+// ReadStatus loads stages.json from dir.
+func ReadStatus(dir string) (*Status, error) {
+	data, err := os.ReadFile(filepath.Join(dir, StatusFile))
+	if err != nil {
+		return nil, err
+	}
+	status := &Status{}
+	if err := json.Unmarshal(data, status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
@@ -0,0 +1,51 @@
+package usecase
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/theweirdfulmurk/cfd-platform/internal/domain"
+	"github.com/theweirdfulmurk/cfd-platform/internal/openfoam"
+)
+
+// checkDivergence reports why rec violates rules, or "" if it doesn't.
+func checkDivergence(rules domain.DivergenceRules, rec openfoam.Record) string {
+	switch {
+	case rec.Residual != nil:
+		initial := float64(rec.Residual.Initial)
+		if rules.StopOnNaN && isNotFinite(initial, float64(rec.Residual.Final)) {
+			return fmt.Sprintf("non-finite residual for %s at time %g", rec.Field, float64(rec.Residual.Time))
+		}
+		if rules.MaxResidual > 0 && initial > rules.MaxResidual {
+			return fmt.Sprintf("initial residual of %s is %g at time %g (limit %g)",
+				rec.Field, initial, float64(rec.Residual.Time), rules.MaxResidual)
+		}
+
+	case rec.Courant != nil:
+		max := float64(rec.Courant.Max)
+		if rules.StopOnNaN && isNotFinite(float64(rec.Courant.Mean), max) {
+			return fmt.Sprintf("non-finite Courant number at time %g", float64(rec.Courant.Time))
+		}
+		if rules.MaxCourant > 0 && max > rules.MaxCourant {
+			return fmt.Sprintf("max Courant number is %g at time %g (limit %g)",
+				max, float64(rec.Courant.Time), rules.MaxCourant)
+		}
+
+	case rec.Continuity != nil:
+		c := rec.Continuity
+		if rules.StopOnNaN && isNotFinite(float64(c.SumLocal), float64(c.Global), float64(c.Cumulative)) {
+			return fmt.Sprintf("non-finite continuity error at time %g", float64(c.Time))
+		}
+	}
+
+	return ""
+}
+
+func isNotFinite(values ...float64) bool {
+	for _, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return true
+		}
+	}
+	return false
+}
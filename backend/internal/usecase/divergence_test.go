@@ -0,0 +1,108 @@
+package usecase
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/theweirdfulmurk/cfd-platform/internal/domain"
+	"github.com/theweirdfulmurk/cfd-platform/internal/openfoam"
+)
+
+// A stopped run reports the first log line that breaks its rules.
+func TestCheckDivergence(t *testing.T) {
+	strict := domain.DivergenceRules{MaxResidual: 10, MaxCourant: 5, StopOnNaN: true}
+
+	tests := []struct {
+		name  string
+		rules domain.DivergenceRules
+		log   string
+		want  string
+	}{
+		{
+			name:  "converging run",
+			rules: strict,
+			log: `Time = 0.005
+Courant Number mean: 0.0976825 max: 0.585607
+smoothSolver:  Solving for Ux, Initial residual = 1, Final residual = 2.96338e-06, No Iterations 19
+DICPCG:  Solving for p, Initial residual = 1, Final residual = 0.0492854, No Iterations 12
+time step continuity errors : sum local = 0.000466513, global = -1.79995e-19, cumulative = -1.79995e-19`,
+		},
+		{
+			name:  "NaN residual",
+			rules: domain.DefaultDivergenceRules(),
+			log: `Time = 0.0625
+DILUPBiCGStab:  Solving for Ux, Initial residual = 0.00151, Final residual = 8.1e-06, No Iterations 1
+GAMG:  Solving for p_rgh, Initial residual = nan, Final residual = nan, No Iterations 1000`,
+			want: "non-finite residual for p_rgh at time 0.0625",
+		},
+		{
+			name:  "NaN final residual only",
+			rules: domain.DefaultDivergenceRules(),
+			log: `Time = 3
+DICPCG:  Solving for p, Initial residual = 0.9, Final residual = -nan, No Iterations 1000`,
+			want: "non-finite residual for p at time 3",
+		},
+		{
+			name:  "NaN residual allowed",
+			rules: domain.DivergenceRules{MaxResidual: 10},
+			log: `Time = 3
+DICPCG:  Solving for p, Initial residual = nan, Final residual = nan, No Iterations 1000`,
+		},
+		{
+			name:  "residual above the limit",
+			rules: strict,
+			log: `Time = 0.12
+smoothSolver:  Solving for Ux, Initial residual = 1, Final residual = 0.05, No Iterations 3
+smoothSolver:  Solving for Uy, Initial residual = 12.5, Final residual = 0.3, No Iterations 3`,
+			want: "initial residual of Uy is 12.5 at time 0.12 (limit 10)",
+		},
+		{
+			name:  "residual limit off",
+			rules: domain.DefaultDivergenceRules(),
+			log: `Time = 0.12
+smoothSolver:  Solving for Uy, Initial residual = 12.5, Final residual = 0.3, No Iterations 3`,
+		},
+		{
+			name:  "Courant number above the limit",
+			rules: strict,
+			log: `Time = 0.2
+Courant Number mean: 1.17733 max: 7.10543`,
+			want: "max Courant number is 7.10543 at time 0.2 (limit 5)",
+		},
+		{
+			name:  "infinite Courant number",
+			rules: domain.DefaultDivergenceRules(),
+			log: `Time = 0.2
+Courant Number mean: inf max: inf`,
+			want: "non-finite Courant number at time 0.2",
+		},
+		{
+			name:  "NaN continuity error",
+			rules: domain.DefaultDivergenceRules(),
+			log: `Time = 0.4
+time step continuity errors : sum local = -nan, global = -nan, cumulative = -nan`,
+			want: "non-finite continuity error at time 0.4",
+		},
+		{
+			name:  "large continuity error",
+			rules: strict,
+			log: `Time = 1
+time step continuity errors : sum local = 1.19733e+10, global = 0.179883, cumulative = 0.179883`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := openfoam.NewLogParser()
+			var got string
+			for _, line := range strings.Split(tt.log, "\n") {
+				if got = checkDivergence(tt.rules, parser.ParseLine(line)); got != "" {
+					break
+				}
+			}
+			if got != tt.want {
+				t.Errorf("divergence = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/theweirdfulmurk/cfd-platform/internal/domain"
+)
+
+// SimulationEvent is a status transition of a single simulation.
+type SimulationEvent struct {
+	ID           string
+	SimulationID string
+	Status       domain.SimulationStatus
+	StatusReason string
+	StartedAt    *time.Time
+	CompletedAt  *time.Time
+	Time         time.Time
+}
+
+// subscriberBuffer is how far a subscriber may fall behind before it is
+// dropped. Dropped clients reconnect with Last-Event-ID and catch up from
+// the history buffer.
+const subscriberBuffer = 64
+
+// EventBroker fans simulation events out to subscribers and keeps a bounded
+// history so reconnecting clients can resume where they left off.
+//
+// Event IDs have the form "<epoch>-<seq>". The epoch changes on every backend
+// start, so an ID from another epoch makes the whole history replay rather
+// than silently skipping events. Replicas that share an EventLog publish
+// through it and number events alike, so clients may reconnect to any of
+// them.
+type EventBroker struct {
+	mu          sync.Mutex
+	epoch       string
+	seq         uint64
+	history     []SimulationEvent
+	historySize int
+	subscribers map[chan SimulationEvent]string
+	shared      domain.EventLog
+}
+
+// sharedEpoch is the epoch of events from an EventLog, whose sequence
+// numbers survive restarts.
+const sharedEpoch = "log"
+
+// followRetryInterval is how long the broker waits before following the
+// shared log again after it failed.
+const followRetryInterval = 5 * time.Second
+
+func NewEventBroker(historySize int) *EventBroker {
+	return &EventBroker{
+		epoch:       strconv.FormatInt(time.Now().UnixNano(), 36),
+		historySize: historySize,
+		subscribers: make(map[chan SimulationEvent]string),
+	}
+}
+
+// Share makes the broker publish through eventLog and deliver the events
+// of every replica that shares it, until ctx is done. The kept entries
+// become the history. Call it before the first Publish or Subscribe.
+func (b *EventBroker) Share(ctx context.Context, eventLog domain.EventLog) error {
+	entries, err := eventLog.Read(0)
+	if err != nil {
+		return fmt.Errorf("failed to read the event log: %w", err)
+	}
+
+	b.mu.Lock()
+	b.epoch = sharedEpoch
+	b.shared = eventLog
+	b.mu.Unlock()
+	for _, entry := range entries {
+		b.deliverEntry(entry)
+	}
+
+	go b.follow(ctx)
+	return nil
+}
+
+func (b *EventBroker) follow(ctx context.Context) {
+	for {
+		b.mu.Lock()
+		after := int64(b.seq)
+		b.mu.Unlock()
+
+		err := b.shared.Follow(ctx, after, b.deliverEntry)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("event log: %v", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(followRetryInterval):
+		}
+	}
+}
+
+// deliverEntry delivers an event from the shared log.
+func (b *EventBroker) deliverEntry(entry domain.EventLogEntry) {
+	var event SimulationEvent
+	if err := json.Unmarshal(entry.Payload, &event); err != nil {
+		log.Printf("event log: skipping entry %d: %v", entry.Seq, err)
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if uint64(entry.Seq) <= b.seq {
+		return
+	}
+	b.seq = uint64(entry.Seq)
+	b.deliver(event)
+}
+
+// Publish records the current status of sim as a new event.
+func (b *EventBroker) Publish(sim *domain.Simulation) {
+	event := SimulationEvent{
+		SimulationID: sim.ID,
+		Status:       sim.Status,
+		StatusReason: sim.StatusReason,
+		StartedAt:    sim.StartedAt,
+		CompletedAt:  sim.CompletedAt,
+		Time:         time.Now(),
+	}
+
+	if b.shared != nil {
+		// Delivered once the log hands it back.
+		payload, err := json.Marshal(event)
+		if err == nil {
+			err = b.shared.Append(payload)
+		}
+		if err != nil {
+			log.Printf("event log: failed to publish %s of %s: %v", event.Status, event.SimulationID, err)
+		}
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq++
+	b.deliver(event)
+}
+
+// deliver numbers event with the current seq, keeps it in history and
+// hands it to the subscribers. b.mu must be held.
+func (b *EventBroker) deliver(event SimulationEvent) {
+	event.ID = fmt.Sprintf("%s-%d", b.epoch, b.seq)
+
+	b.history = append(b.history, event)
+	if len(b.history) > b.historySize {
+		b.history = b.history[len(b.history)-b.historySize:]
+	}
+
+	for ch, simID := range b.subscribers {
+		if simID != "" && simID != event.SimulationID {
+			continue
+		}
+		select {
+		case ch <- event:
+		default:
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscribe returns the events after lastEventID that are still in history,
+// plus a channel for new ones. An empty simID subscribes to every
+// simulation. The channel is closed when the subscriber falls too far
+// behind; call unsubscribe when done.
+func (b *EventBroker) Subscribe(simID, lastEventID string) (replay []SimulationEvent, events <-chan SimulationEvent, unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if lastEventID != "" {
+		epoch, seq := parseEventID(lastEventID)
+		for _, event := range b.history {
+			if simID != "" && event.SimulationID != simID {
+				continue
+			}
+			if epoch == b.epoch {
+				if _, eventSeq := parseEventID(event.ID); eventSeq <= seq {
+					continue
+				}
+			}
+			replay = append(replay, event)
+		}
+	}
+
+	ch := make(chan SimulationEvent, subscriberBuffer)
+	b.subscribers[ch] = simID
+
+	unsubscribe = func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+
+	return replay, ch, unsubscribe
+}
+
+func parseEventID(id string) (epoch string, seq uint64) {
+	epoch, seqStr, ok := strings.Cut(id, "-")
+	if !ok {
+		return "", 0
+	}
+	seq, _ = strconv.ParseUint(seqStr, 10, 64)
+	return epoch, seq
+}
@@ -0,0 +1,128 @@
+package usecase
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/theweirdfulmurk/cfd-platform/internal/domain"
+)
+
+// memEventLog stands in for the PostgreSQL event log.
+type memEventLog struct {
+	mu       sync.Mutex
+	entries  []domain.EventLogEntry
+	appended chan struct{}
+}
+
+func newMemEventLog() *memEventLog {
+	return &memEventLog{appended: make(chan struct{})}
+}
+
+func (l *memEventLog) Append(payload []byte) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, domain.EventLogEntry{Seq: int64(len(l.entries) + 1), Payload: payload})
+	close(l.appended)
+	l.appended = make(chan struct{})
+	return nil
+}
+
+func (l *memEventLog) Read(after int64) ([]domain.EventLogEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]domain.EventLogEntry(nil), l.entries[after:]...), nil
+}
+
+func (l *memEventLog) Follow(ctx context.Context, after int64, deliver func(domain.EventLogEntry)) error {
+	for {
+		l.mu.Lock()
+		entries := append([]domain.EventLogEntry(nil), l.entries[after:]...)
+		appended := l.appended
+		l.mu.Unlock()
+
+		for _, entry := range entries {
+			deliver(entry)
+			after = entry.Seq
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-appended:
+		}
+	}
+}
+
+func receive(t *testing.T, events <-chan SimulationEvent) SimulationEvent {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event arrived")
+		return SimulationEvent{}
+	}
+}
+
+func TestEventBrokerShare(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	eventLog := newMemEventLog()
+	eventLog.Append([]byte(`{"SimulationID":"s0","Status":"completed"}`))
+
+	a, b := NewEventBroker(10), NewEventBroker(10)
+	for _, broker := range []*EventBroker{a, b} {
+		if err := broker.Share(ctx, eventLog); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	replay, events, unsubscribe := b.Subscribe("s1", "")
+	defer unsubscribe()
+	if len(replay) != 0 {
+		t.Errorf("replay without Last-Event-ID = %v", replay)
+	}
+	_, ownEvents, unsubscribeOwn := a.Subscribe("", "")
+	defer unsubscribeOwn()
+
+	a.Publish(&domain.Simulation{ID: "s1", Status: domain.SimStatusRunning})
+	a.Publish(&domain.Simulation{ID: "s1", Status: domain.SimStatusFailed, StatusReason: "diverged"})
+
+	running := receive(t, events)
+	if running.ID != "log-2" || running.Status != domain.SimStatusRunning {
+		t.Errorf("first event = %+v", running)
+	}
+	failed := receive(t, events)
+	if failed.ID != "log-3" || failed.Status != domain.SimStatusFailed || failed.StatusReason != "diverged" {
+		t.Errorf("second event = %+v", failed)
+	}
+
+	// The publishing replica gets its events back from the log as well.
+	if own := receive(t, ownEvents); own.ID != running.ID {
+		t.Errorf("own event = %+v", own)
+	}
+	if own := receive(t, ownEvents); own.ID != failed.ID {
+		t.Errorf("own event = %+v", own)
+	}
+
+	// A client reconnecting to the other replica resumes where it was.
+	replay, _, unsubscribeA := a.Subscribe("", running.ID)
+	defer unsubscribeA()
+	if len(replay) != 1 || replay[0].ID != failed.ID {
+		t.Errorf("replay after %s = %+v", running.ID, replay)
+	}
+
+	// Events kept in the log become the history of a replica that starts
+	// later.
+	c := NewEventBroker(10)
+	if err := c.Share(ctx, eventLog); err != nil {
+		t.Fatal(err)
+	}
+	replay, _, unsubscribeC := c.Subscribe("", "log-1")
+	defer unsubscribeC()
+	if len(replay) != 2 || replay[0].ID != "log-2" || replay[1].ID != "log-3" {
+		t.Errorf("replay on a new replica = %+v", replay)
+	}
+}
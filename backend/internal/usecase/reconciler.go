@@ -0,0 +1,233 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/theweirdfulmurk/cfd-platform/internal/domain"
+)
+
+// reconcileGracePeriod keeps the reconciler away from objects that are still
+// being created, where the Job exists but the record does not yet (or the
+// reverse).
+const reconcileGracePeriod = time.Minute
+
+// Reconciler brings the repositories back in line with the platform Jobs and
+// viz Pods that exist in the cluster. It is meant to run once at startup and
+// then periodically, since records may be lost on restart with an in-memory
+// repo and Jobs may vanish while the backend is down.
+type Reconciler struct {
+	simRepo   domain.SimulationRepository
+	vizRepo   domain.VisualizationRepository
+	simK8s    domain.SimulationK8sManager
+	vizK8s    domain.VisualizationK8sManager
+	events    *EventBroker
+	gcOrphans bool
+}
+
+func NewReconciler(
+	simRepo domain.SimulationRepository,
+	vizRepo domain.VisualizationRepository,
+	simK8s domain.SimulationK8sManager,
+	vizK8s domain.VisualizationK8sManager,
+	events *EventBroker,
+	gcOrphans bool,
+) *Reconciler {
+	return &Reconciler{
+		simRepo:   simRepo,
+		vizRepo:   vizRepo,
+		simK8s:    simK8s,
+		vizK8s:    vizK8s,
+		events:    events,
+		gcOrphans: gcOrphans,
+	}
+}
+
+// Run reconciles immediately and then every interval until ctx is done.
+func (r *Reconciler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.Reconcile(); err != nil {
+			log.Printf("reconcile failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Reconciler) Reconcile() error {
+	if err := r.reconcileSimulations(); err != nil {
+		return fmt.Errorf("simulations: %w", err)
+	}
+	if err := r.reconcileVisualizations(); err != nil {
+		return fmt.Errorf("visualizations: %w", err)
+	}
+	return nil
+}
+
+func (r *Reconciler) reconcileSimulations() error {
+	jobs, err := r.simK8s.ListJobs()
+	if err != nil {
+		return fmt.Errorf("failed to list jobs: %w", err)
+	}
+
+	sims, err := r.simRepo.List()
+	if err != nil {
+		return fmt.Errorf("failed to list records: %w", err)
+	}
+
+	known := make(map[string]*domain.Simulation, len(sims))
+	for _, sim := range sims {
+		known[sim.ID] = sim
+	}
+
+	seen := make(map[string]bool, len(jobs))
+	for _, job := range jobs {
+		seen[job.ID] = true
+		if _, exists := known[job.ID]; exists || isRecent(job.CreatedAt) {
+			continue
+		}
+
+		// Without annotations we can't rebuild a meaningful record.
+		if job.Name == "" {
+			r.handleOrphanJob(job.ID)
+			continue
+		}
+
+		// Deleting a simulation removes the Job before the record, so a
+		// record missing from the list may have been deleted after the
+		// Jobs were listed: only a Job that is still there brings it back.
+		if _, err := r.simK8s.GetJobStatus(job.ID); err != nil {
+			log.Printf("reconcile: not restoring simulation %s: %v", job.ID, err)
+			continue
+		}
+
+		if err := r.simRepo.Create(job); err != nil {
+			log.Printf("reconcile: failed to restore simulation %s: %v", job.ID, err)
+			continue
+		}
+		r.events.Publish(job)
+		log.Printf("reconcile: restored simulation %s from job %s", job.ID, job.PodName)
+	}
+
+	for _, sim := range sims {
+		if seen[sim.ID] || !isActiveSimulation(sim.Status) || isRecent(sim.CreatedAt) {
+			continue
+		}
+
+		sim.Status = domain.SimStatusLost
+		sim.StatusReason = "job no longer exists"
+		if err := r.simRepo.Update(sim); err != nil {
+			log.Printf("reconcile: failed to mark simulation %s lost: %v", sim.ID, err)
+			continue
+		}
+		r.events.Publish(sim)
+		log.Printf("reconcile: simulation %s has no job, marked lost", sim.ID)
+	}
+
+	return nil
+}
+
+func (r *Reconciler) reconcileVisualizations() error {
+	pods, err := r.vizK8s.ListPods()
+	if err != nil {
+		return fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	seen := make(map[string]bool, len(pods))
+	for _, pod := range pods {
+		seen[pod.ID] = true
+
+		if _, err := r.vizRepo.GetByID(pod.ID); err == nil || isRecent(pod.CreatedAt) {
+			continue
+		}
+
+		// A viz pod is only useful for a simulation we still know about.
+		if pod.SimulationID == "" {
+			r.handleOrphanPod(pod.ID)
+			continue
+		}
+		if _, err := r.simRepo.GetByID(pod.SimulationID); err != nil {
+			r.handleOrphanPod(pod.ID)
+			continue
+		}
+
+		if err := r.vizRepo.Create(pod); err != nil {
+			log.Printf("reconcile: failed to restore visualization %s: %v", pod.ID, err)
+			continue
+		}
+		log.Printf("reconcile: restored visualization %s from pod %s", pod.ID, pod.PodName)
+	}
+
+	sims, err := r.simRepo.List()
+	if err != nil {
+		return fmt.Errorf("failed to list simulation records: %w", err)
+	}
+
+	for _, sim := range sims {
+		vizList, err := r.vizRepo.GetBySimulationID(sim.ID)
+		if err != nil {
+			return fmt.Errorf("failed to list records: %w", err)
+		}
+
+		for _, viz := range vizList {
+			if seen[viz.ID] || !isActiveVisualization(viz.Status) || isRecent(viz.CreatedAt) {
+				continue
+			}
+
+			viz.Status = domain.VizStatusLost
+			viz.UpdatedAt = time.Now()
+			if err := r.vizRepo.Update(viz); err != nil {
+				log.Printf("reconcile: failed to mark visualization %s lost: %v", viz.ID, err)
+				continue
+			}
+			log.Printf("reconcile: visualization %s has no pod, marked lost", viz.ID)
+		}
+	}
+
+	return nil
+}
+
+func (r *Reconciler) handleOrphanJob(simID string) {
+	if !r.gcOrphans {
+		log.Printf("reconcile: job for simulation %s has no metadata, leaving it", simID)
+		return
+	}
+	if err := r.simK8s.DeleteJob(simID); err != nil {
+		log.Printf("reconcile: failed to delete orphaned job for %s: %v", simID, err)
+		return
+	}
+	log.Printf("reconcile: deleted orphaned job for simulation %s", simID)
+}
+
+func (r *Reconciler) handleOrphanPod(vizID string) {
+	if !r.gcOrphans {
+		log.Printf("reconcile: viz pod %s has no known simulation, leaving it", vizID)
+		return
+	}
+	if err := r.vizK8s.DeletePod(vizID); err != nil {
+		log.Printf("reconcile: failed to delete orphaned viz pod %s: %v", vizID, err)
+		return
+	}
+	log.Printf("reconcile: deleted orphaned viz pod %s", vizID)
+}
+
+func isActiveSimulation(status domain.SimulationStatus) bool {
+	return status == domain.SimStatusPending || status == domain.SimStatusRunning || status == domain.SimStatusSuspended
+}
+
+func isActiveVisualization(status domain.VisualizationStatus) bool {
+	return status == domain.VizStatusPending || status == domain.VizStatusRunning || status == domain.VizStatusReady
+}
+
+func isRecent(t time.Time) bool {
+	return time.Since(t) < reconcileGracePeriod
+}
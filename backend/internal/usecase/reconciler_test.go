@@ -0,0 +1,48 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+
+	"github.com/theweirdfulmurk/cfd-platform/internal/domain"
+	"github.com/theweirdfulmurk/cfd-platform/internal/repository"
+)
+
+func TestReconcileRestoresSimulations(t *testing.T) {
+	rules := domain.DivergenceRules{MaxResidual: 5, MaxCourant: 20}
+	job := &domain.Simulation{
+		ID:         "s1",
+		Name:       "cube",
+		Type:       domain.SimTypeCFD,
+		Status:     domain.SimStatusRunning,
+		CreatedAt:  time.Now().Add(-time.Hour),
+		Divergence: rules,
+	}
+
+	for _, tt := range []struct {
+		name    string
+		gone    bool
+		restore bool
+	}{
+		{"job still there", false, true},
+		{"deleted meanwhile", true, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			k8s := &fakeK8s{jobs: []*domain.Simulation{job}, gone: map[string]bool{"s1": tt.gone}}
+			repo := repository.NewInMemorySimulationRepo()
+			r := NewReconciler(repo, repository.NewInMemoryVisualizationRepo(), k8s, nil, NewEventBroker(10), false)
+
+			if err := r.reconcileSimulations(); err != nil {
+				t.Fatal(err)
+			}
+
+			sim, err := repo.GetByID("s1")
+			if restored := err == nil; restored != tt.restore {
+				t.Fatalf("restored = %t, want %t", restored, tt.restore)
+			}
+			if tt.restore && sim.Divergence != rules {
+				t.Errorf("divergence rules = %+v, want %+v", sim.Divergence, rules)
+			}
+		})
+	}
+}
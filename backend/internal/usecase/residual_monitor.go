@@ -0,0 +1,143 @@
+package usecase
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/theweirdfulmurk/cfd-platform/internal/domain"
+	"github.com/theweirdfulmurk/cfd-platform/internal/openfoam"
+)
+
+const residualsFileName = "residuals.json"
+
+// ResidualMonitor follows the solver log of running CFD simulations, keeps
+// the parsed residuals in memory and persists them to the results directory
+// once the log ends. Every parsed record is checked against the simulation's
+// divergence rules; the first violation is reported through onDiverged.
+type ResidualMonitor struct {
+	k8sManager  domain.SimulationK8sManager
+	resultsPath string
+	onDiverged  func(simID, reason string)
+
+	mu     sync.Mutex
+	active map[string]*monitoredRun
+}
+
+type monitoredRun struct {
+	mu     sync.Mutex
+	parser *openfoam.LogParser
+	rules  domain.DivergenceRules
+}
+
+func NewResidualMonitor(
+	k8s domain.SimulationK8sManager,
+	resultsPath string,
+	onDiverged func(simID, reason string),
+) *ResidualMonitor {
+	return &ResidualMonitor{
+		k8sManager:  k8s,
+		resultsPath: resultsPath,
+		onDiverged:  onDiverged,
+		active:      make(map[string]*monitoredRun),
+	}
+}
+
+// Watch starts following the log of sim unless it is already followed.
+// It is safe to call on every status update of a running simulation.
+func (m *ResidualMonitor) Watch(sim *domain.Simulation) {
+	m.mu.Lock()
+	if _, ok := m.active[sim.ID]; ok {
+		m.mu.Unlock()
+		return
+	}
+	run := &monitoredRun{parser: openfoam.NewLogParser(), rules: sim.Divergence}
+	m.active[sim.ID] = run
+	m.mu.Unlock()
+
+	go m.follow(sim.ID, run)
+}
+
+// Residuals returns the live residuals of a simulation that is being watched.
+func (m *ResidualMonitor) Residuals(simID string) (*openfoam.Residuals, bool) {
+	m.mu.Lock()
+	run, ok := m.active[simID]
+	m.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	return run.parser.Residuals(), true
+}
+
+func (m *ResidualMonitor) follow(simID string, run *monitoredRun) {
+	defer func() {
+		m.mu.Lock()
+		delete(m.active, simID)
+		m.mu.Unlock()
+	}()
+
+	logs, err := m.k8sManager.StreamLogs(context.Background(), simID, true, 0)
+	if err != nil {
+		log.Printf("residual monitor: failed to follow logs of %s: %v", simID, err)
+		return
+	}
+	defer logs.Close()
+
+	scanner := bufio.NewScanner(logs)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	diverged := false
+	for scanner.Scan() {
+		run.mu.Lock()
+		rec := run.parser.ParseLine(scanner.Text())
+		run.mu.Unlock()
+
+		if diverged {
+			continue
+		}
+		if reason := checkDivergence(run.rules, rec); reason != "" {
+			diverged = true
+			go m.onDiverged(simID, reason)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Printf("residual monitor: log stream of %s ended: %v", simID, err)
+	}
+
+	run.mu.Lock()
+	residuals := run.parser.Residuals()
+	run.mu.Unlock()
+
+	if err := m.persist(simID, residuals); err != nil {
+		log.Printf("residual monitor: failed to persist residuals of %s: %v", simID, err)
+	}
+}
+
+func (m *ResidualMonitor) persist(simID string, residuals *openfoam.Residuals) error {
+	data, err := json.Marshal(residuals)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(m.resultsPath, simID, residualsFileName), bytes.NewReader(data))
+}
+
+// load reads residuals persisted by a previous run of the monitor.
+func (m *ResidualMonitor) load(simID string) (*openfoam.Residuals, error) {
+	data, err := os.ReadFile(filepath.Join(m.resultsPath, simID, residualsFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	residuals := openfoam.NewResiduals()
+	if err := json.Unmarshal(data, residuals); err != nil {
+		return nil, err
+	}
+	return residuals, nil
+}
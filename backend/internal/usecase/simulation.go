@@ -3,60 +3,129 @@ package usecase
 import (
 	"fmt"
 	"io"
+	"log"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/theweirdfulmurk/cfd-platform/internal/archive"
 	"github.com/theweirdfulmurk/cfd-platform/internal/domain"
 )
 
 type SimulationUseCase struct {
 	repo        domain.SimulationRepository
 	k8sManager  domain.SimulationK8sManager
+	resources   domain.ResourcePolicy
+	solvers     domain.SolverCatalog
+	limits      archive.Limits
+	events      *EventBroker
+	residuals   *ResidualMonitor
 	storagePath string
+	resultsPath string
+
+	archiveCacheBytes int64
+	restartMu         sync.Mutex // serialises this replica's restarts; see lockRestarts
 }
 
 func NewSimulationUseCase(
 	repo domain.SimulationRepository,
 	k8s domain.SimulationK8sManager,
+	resources domain.ResourcePolicy,
+	solvers domain.SolverCatalog,
+	limits archive.Limits,
+	events *EventBroker,
 ) *SimulationUseCase {
-	return &SimulationUseCase{
+	uc := &SimulationUseCase{
 		repo:        repo,
 		k8sManager:  k8s,
+		resources:   resources,
+		solvers:     solvers,
+		limits:      limits,
+		events:      events,
 		storagePath: "/pvc/simulations", // монтируется из PVC
+		resultsPath: "/results",
 	}
+	uc.residuals = NewResidualMonitor(k8s, uc.resultsPath, uc.markDiverged)
+
+	return uc
+}
+
+// SimulationParams are the user-supplied settings of a new simulation.
+type SimulationParams struct {
+	Name       string
+	Type       domain.SimulationType
+	Divergence domain.DivergenceRules
+	Resources  domain.ResourceRequirements
+	Subdomains int
+	Nodes      int
+	Solver     string // catalog reference; empty picks the type's default
+	// Allrun runs the case's Allrun script instead of the generated
+	// sequence; PostProcess lists function objects to run after the solver.
+	Allrun      bool
+	PostProcess []string
 }
 
 func (uc *SimulationUseCase) CreateWithFile(
-	name string,
-	simType domain.SimulationType,
+	params SimulationParams,
 	file io.Reader,
 	filename string,
 ) (*domain.Simulation, error) {
-	simID := uuid.New().String()[:8]
+	name, simType := params.Name, params.Type
 
-	// Создаём директорию для симуляции
-	simDir := filepath.Join(uc.storagePath, simID)
-	if err := os.MkdirAll(simDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create simulation directory: %w", err)
+	solver, err := uc.solvers.Resolve(params.Solver, simType)
+	if err != nil {
+		return nil, err
+	}
+	if !acceptsInput(solver, filename) {
+		return nil, fmt.Errorf("%w: %s reads %s, got %s", domain.ErrUnsupportedInput,
+			solver.Ref(), strings.Join(solver.InputFormats, ", "), filename)
 	}
 
-	var destPath string
-	if simType == domain.SimTypeFEA {
-		destPath = filepath.Join(simDir, "input.inp")
-	} else {
-		destPath = filepath.Join(simDir, filename)
+	if err := sizeParallelRun(&params); err != nil {
+		return nil, err
+	}
+	if params.Type == domain.SimTypeFEA && params.Nodes > 1 && !solver.MPI {
+		return nil, fmt.Errorf("%w: %s is not an MPI build and runs on a single node", domain.ErrInvalidResources, solver.Ref())
+	}
+	if err := checkRunOptions(params); err != nil {
+		return nil, err
 	}
 
-	destFile, err := os.Create(destPath)
+	resources, err := uc.resources.Resolve(simType, withSolverDefaults(params.Resources, solver))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create file: %w", err)
+		return nil, err
 	}
-	defer destFile.Close()
 
-	if _, err := io.Copy(destFile, file); err != nil {
-		return nil, fmt.Errorf("failed to save file: %w", err)
+	simID := uuid.New().String()[:8]
+
+	// Создаём директорию для симуляции
+	simDir := filepath.Join(uc.storagePath, simID)
+	// Until the Job exists, nothing may use the case or the mesh report.
+	submitted := false
+	defer func() {
+		if !submitted {
+			os.RemoveAll(simDir)
+			os.RemoveAll(filepath.Join(uc.resultsPath, simID))
+		}
+	}()
+
+	var run domain.RunPlan
+	if simType == domain.SimTypeCFD {
+		if err := uc.unpackCase(file, simDir); err != nil {
+			return nil, err
+		}
+		if run, err = planRun(params, simDir); err != nil {
+			return nil, err
+		}
+		if err := uc.reportMesh(simDir, simID); err != nil {
+			return nil, err
+		}
+	} else if err := saveInput(file, simDir); err != nil {
+		return nil, err
 	}
 
 	now := time.Now()
@@ -69,21 +138,37 @@ func (uc *SimulationUseCase) CreateWithFile(
 		ResultPath: fmt.Sprintf("results/%s", simID),
 		ConfigPath: simID, // путь в PVC
 		CreatedAt:  now,
+		Divergence: params.Divergence,
+		Resources:  resources,
+		Subdomains: params.Subdomains,
+		Nodes:      params.Nodes,
+		Solver:     solver.Ref(),
+		Run:        run,
 	}
 
 	// Создаём K8s Job
-	if err := uc.k8sManager.CreateJob(simID, simType, simID); err != nil {
-		return nil, fmt.Errorf("failed to create job: %w", err)
-	}
-
-	if err := uc.repo.Create(sim); err != nil {
-		return nil, fmt.Errorf("failed to save simulation: %w", err)
+	if err := uc.submit(sim); err != nil {
+		return nil, err
 	}
+	submitted = true
 
 	return sim, nil
 }
 
 func (uc *SimulationUseCase) Create(name string, simType domain.SimulationType, configPath string) (*domain.Simulation, error) {
+	if !filepath.IsLocal(configPath) {
+		return nil, fmt.Errorf("config path %q must be relative to the simulations directory", configPath)
+	}
+
+	solver, err := uc.solvers.Resolve("", simType)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := uc.resources.Resolve(simType, withSolverDefaults(domain.ResourceRequirements{}, solver))
+	if err != nil {
+		return nil, err
+	}
+
 	simID := uuid.New().String()[:8]
 
 	now := time.Now()
@@ -96,57 +181,110 @@ func (uc *SimulationUseCase) Create(name string, simType domain.SimulationType,
 		ResultPath: fmt.Sprintf("results/%s", simID),
 		ConfigPath: configPath,
 		CreatedAt:  now,
+		Divergence: domain.DefaultDivergenceRules(),
+		Resources:  resources,
+		Solver:     solver.Ref(),
 	}
 
-	if err := uc.k8sManager.CreateJob(simID, simType, configPath); err != nil {
-		return nil, fmt.Errorf("failed to create job: %w", err)
-	}
-
-	if err := uc.repo.Create(sim); err != nil {
-		return nil, fmt.Errorf("failed to save simulation: %w", err)
+	if err := uc.submit(sim); err != nil {
+		return nil, err
 	}
 
 	return sim, nil
 }
 
-func (uc *SimulationUseCase) GetByID(simID string) (*domain.Simulation, error) {
-	sim, err := uc.repo.GetByID(simID)
-	if err != nil {
-		return nil, err
+// submit saves a new simulation and then creates its Job. The record comes
+// first so the status watcher has something to apply the Job's first events
+// to; it is removed again if the Job can't be created. An error means there
+// is no Job, so callers may undo what they prepared for it.
+func (uc *SimulationUseCase) submit(sim *domain.Simulation) error {
+	if err := uc.repo.Create(sim); err != nil {
+		return fmt.Errorf("failed to save simulation: %w", err)
 	}
 
-	// Update status from k8s
-	status, err := uc.k8sManager.GetJobStatus(simID)
-	if err == nil && status != sim.Status {
-		sim.Status = status
-		if status == domain.SimStatusCompleted {
-			now := time.Now()
-			sim.CompletedAt = &now
+	if err := uc.k8sManager.CreateJob(sim); err != nil {
+		if delErr := uc.repo.Delete(sim.ID); delErr != nil {
+			log.Printf("failed to remove simulation %s without a job: %v", sim.ID, delErr)
 		}
-		uc.repo.Update(sim)
+		return fmt.Errorf("failed to create job: %w", err)
 	}
 
-	return sim, nil
+	// CreateJob recorded the stages it runs. The watcher may have moved the
+	// record on meanwhile, so only the plan is written back. The Job runs
+	// either way, so a record without the plan is no reason to fail.
+	stored, err := uc.repo.GetByID(sim.ID)
+	if err == nil {
+		stored.Run = sim.Run
+		err = uc.repo.Update(stored)
+	}
+	if err != nil {
+		log.Printf("failed to record the stages of simulation %s: %v", sim.ID, err)
+	} else {
+		*sim = *stored
+	}
+	uc.events.Publish(sim)
+	return nil
+}
+
+func (uc *SimulationUseCase) GetByID(simID string) (*domain.Simulation, error) {
+	return uc.repo.GetByID(simID)
 }
 
 func (uc *SimulationUseCase) List() ([]*domain.Simulation, error) {
-	sims, err := uc.repo.List()
+	return uc.repo.List()
+}
+
+// ApplyJobState records the state of a simulation's Job as observed by the
+// status watcher. Jobs without a record are left to the reconciler.
+func (uc *SimulationUseCase) ApplyJobState(observed *domain.Simulation) {
+	sim, err := uc.repo.GetByID(observed.ID)
 	if err != nil {
-		return nil, err
+		return
 	}
 
-	for _, sim := range sims {
-		if status, err := uc.k8sManager.GetJobStatus(sim.ID); err == nil {
-			sim.Status = status
-			if status == domain.SimStatusCompleted {
-				now := time.Now()
-				sim.CompletedAt = &now
-			}
-			uc.repo.Update(sim)
-		}
+	// The platform stopped this run on purpose; late Job updates must not
+	// overwrite that decision.
+	if isStoppedByPlatform(sim.Status) {
+		return
+	}
+
+	if observed.Status == domain.SimStatusFailed {
+		observed.StatusReason = uc.stageFailure(observed.ID, observed.StatusReason)
+	}
+
+	// Also covers runs that were already running when the backend started.
+	if observed.Status == domain.SimStatusRunning && sim.Type == domain.SimTypeCFD {
+		uc.residuals.Watch(sim)
 	}
 
-	return sims, nil
+	if sim.Status == observed.Status &&
+		sim.StatusReason == observed.StatusReason &&
+		sameTime(sim.StartedAt, observed.StartedAt) &&
+		sameTime(sim.CompletedAt, observed.CompletedAt) {
+		return
+	}
+
+	finished := sim.Status != observed.Status && isFinishedSimulation(observed.Status)
+
+	sim.Status = observed.Status
+	sim.StatusReason = observed.StatusReason
+	sim.StartedAt = observed.StartedAt
+	sim.CompletedAt = observed.CompletedAt
+	if err := uc.repo.Update(sim); err != nil {
+		log.Printf("failed to update simulation %s status: %v", sim.ID, err)
+		return
+	}
+	uc.events.Publish(sim)
+
+	if finished {
+		go uc.archiveLogs(sim.ID)
+	}
+}
+
+// Subscribe streams status events, optionally for a single simulation. See
+// EventBroker.Subscribe.
+func (uc *SimulationUseCase) Subscribe(simID, lastEventID string) ([]SimulationEvent, <-chan SimulationEvent, func()) {
+	return uc.events.Subscribe(simID, lastEventID)
 }
 
 func (uc *SimulationUseCase) Delete(simID string) error {
@@ -160,3 +298,94 @@ func (uc *SimulationUseCase) Delete(simID string) error {
 
 	return nil
 }
+
+// markDiverged stops a simulation whose solver output violated one of its
+// divergence rules. The record is updated before the Job is deleted so the
+// status watcher can't race it back to running.
+func (uc *SimulationUseCase) markDiverged(simID, reason string) {
+	sim, err := uc.repo.GetByID(simID)
+	if err != nil || !isActiveSimulation(sim.Status) {
+		return
+	}
+
+	now := time.Now()
+	sim.Status = domain.SimStatusDiverged
+	sim.StatusReason = reason
+	sim.CompletedAt = &now
+	if err := uc.repo.Update(sim); err != nil {
+		log.Printf("failed to mark simulation %s diverged: %v", simID, err)
+		return
+	}
+	uc.events.Publish(sim)
+	log.Printf("simulation %s diverged: %s", simID, reason)
+
+	// Keep the log before the pod goes away with the Job.
+	uc.archiveLogs(simID)
+	if err := uc.k8sManager.DeleteJob(simID); err != nil {
+		log.Printf("failed to delete job of diverged simulation %s: %v", simID, err)
+	}
+}
+
+func isStoppedByPlatform(status domain.SimulationStatus) bool {
+	return status == domain.SimStatusDiverged || status == domain.SimStatusCancelled
+}
+
+func isFinishedSimulation(status domain.SimulationStatus) bool {
+	return status == domain.SimStatusCompleted || status == domain.SimStatusFailed
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// sizeParallelRun validates the MPI layout and, unless the user sized the
+// run explicitly, requests one core per rank on each pod.
+func sizeParallelRun(params *SimulationParams) error {
+	if params.Subdomains < 0 || params.Nodes < 0 {
+		return fmt.Errorf("%w: subdomains and nodes must not be negative", domain.ErrInvalidResources)
+	}
+	if params.Subdomains > 1 && params.Type != domain.SimTypeCFD {
+		return fmt.Errorf("%w: subdomains only apply to CFD runs", domain.ErrInvalidResources)
+	}
+	if params.Nodes > 1 && params.Type == domain.SimTypeCFD && params.Subdomains < params.Nodes {
+		return fmt.Errorf("%w: a run on %d nodes needs at least as many subdomains", domain.ErrInvalidResources, params.Nodes)
+	}
+
+	if params.Subdomains > 1 && params.Resources.CPU == "" {
+		nodes := max(params.Nodes, 1)
+		params.Resources.CPU = strconv.Itoa((params.Subdomains + nodes - 1) / nodes)
+	}
+	return nil
+}
+
+// Solvers lists the solver catalog.
+func (uc *SimulationUseCase) Solvers() []domain.Solver {
+	return uc.solvers.List()
+}
+
+func acceptsInput(solver *domain.Solver, filename string) bool {
+	for _, format := range solver.InputFormats {
+		if strings.HasSuffix(strings.ToLower(filename), strings.ToLower(format)) {
+			return true
+		}
+	}
+	return false
+}
+
+// withSolverDefaults fills resources the user left empty with the solver's
+// own defaults; the policy's per-type defaults apply after that.
+func withSolverDefaults(requested domain.ResourceRequirements, solver *domain.Solver) domain.ResourceRequirements {
+	if requested.CPU == "" {
+		requested.CPU = solver.Resources.CPU
+	}
+	if requested.Memory == "" {
+		requested.Memory = solver.Resources.Memory
+	}
+	if requested.EphemeralStorage == "" {
+		requested.EphemeralStorage = solver.Resources.EphemeralStorage
+	}
+	return requested
+}
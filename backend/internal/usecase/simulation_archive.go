@@ -0,0 +1,384 @@
+package usecase
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/theweirdfulmurk/cfd-platform/internal/archive"
+	"github.com/theweirdfulmurk/cfd-platform/internal/runner"
+)
+
+// ErrInvalidSelection is returned for result filters that can't be applied.
+var ErrInvalidSelection = errors.New("invalid result selection")
+
+// checksumsName is the last entry of every results archive.
+const checksumsName = "SHA256SUMS"
+
+// archiveCacheDir keeps pre-built archives in a simulation's results
+// directory. It is hidden, so listings and archives leave it out.
+const archiveCacheDir = ".archives"
+
+// ResultsSelection narrows a results archive. Patterns are globs over
+// slash-separated paths in the results directory; a pattern matching a
+// directory covers everything below it.
+type ResultsSelection struct {
+	Include []string // empty includes everything
+	Exclude []string
+	Latest  bool     // only the latest time directory
+	From    *float64 // time directories from this time on
+	To      *float64 // time directories up to this time
+}
+
+func (s ResultsSelection) validate() error {
+	for _, pattern := range append(append([]string(nil), s.Include...), s.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("%w: bad pattern %q", ErrInvalidSelection, pattern)
+		}
+	}
+	if s.Latest && (s.From != nil || s.To != nil) {
+		return fmt.Errorf("%w: latest can't be combined with a time range", ErrInvalidSelection)
+	}
+	if s.From != nil && s.To != nil && *s.From > *s.To {
+		return fmt.Errorf("%w: from %g is after to %g", ErrInvalidSelection, *s.From, *s.To)
+	}
+	return nil
+}
+
+// matchesAny reports whether rel or one of its parent directories matches
+// one of the patterns.
+func matchesAny(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		for p := rel; p != "."; p = path.Dir(p) {
+			if ok, _ := path.Match(pattern, p); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// archiveFile is a result file selected for an archive.
+type archiveFile struct {
+	path string // on disk
+	rel  string // slash-separated name in the archive
+	size int64
+	mod  time.Time
+}
+
+// ResultsArchive is a results download, ready to be written.
+type ResultsArchive struct {
+	Name   string // suggested file name
+	Format archive.Format
+
+	files    []archiveFile
+	cacheDir string // set when the finished archive may be cached
+	cacheMax int64  // bytes the cache directory may hold
+	key      string
+}
+
+// PrepareResultsArchive selects the result files of a simulation for an
+// archive in format.
+func (uc *SimulationUseCase) PrepareResultsArchive(simID string, format archive.Format, sel ResultsSelection) (*ResultsArchive, error) {
+	sim, err := uc.repo.GetByID(simID)
+	if err != nil {
+		return nil, err
+	}
+	if err := sel.validate(); err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(uc.resultsPath, simID)
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+
+	files, err := selectResults(dir, sel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select results of %s: %w", simID, err)
+	}
+
+	a := &ResultsArchive{
+		Name:   fmt.Sprintf("results-%s.%s", simID, format),
+		Format: format,
+		files:  files,
+	}
+	// Results only stop changing once the run is over.
+	if uc.archiveCacheBytes > 0 && !isActiveSimulation(sim.Status) {
+		a.cacheDir = filepath.Join(dir, archiveCacheDir)
+		a.cacheMax = uc.archiveCacheBytes
+		a.key = a.cacheKey()
+	}
+	return a, nil
+}
+
+// CacheArchives keeps archives of finished simulations on the results
+// volume, so repeated downloads of the same selection are served as files.
+// Each simulation's cache holds at most maxBytes; the least recently used
+// archives make room for new ones. Zero disables the cache.
+func (uc *SimulationUseCase) CacheArchives(maxBytes int64) {
+	uc.archiveCacheBytes = maxBytes
+}
+
+func selectResults(dir string, sel ResultsSelection) ([]archiveFile, error) {
+	var files []archiveFile
+	latest, latestName := -1.0, ""
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == dir {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if len(sel.Include) > 0 && !matchesAny(sel.Include, rel) || matchesAny(sel.Exclude, rel) {
+			return nil
+		}
+		if name := timeDir(rel); name != "" {
+			t, _ := strconv.ParseFloat(name, 64)
+			if sel.From != nil && t < *sel.From || sel.To != nil && t > *sel.To {
+				return nil
+			}
+			if t > latest {
+				latest, latestName = t, name
+			}
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, archiveFile{path: p, rel: rel, size: info.Size(), mod: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if sel.Latest {
+		kept := files[:0]
+		for _, f := range files {
+			if name := timeDir(f.rel); name == "" || name == latestName {
+				kept = append(kept, f)
+			}
+		}
+		files = kept
+	}
+	return files, nil
+}
+
+// timeDir returns the time directory a result file is in, if any.
+func timeDir(rel string) string {
+	if first, _, nested := strings.Cut(rel, "/"); nested && runner.IsTimeName(first) {
+		return first
+	}
+	return ""
+}
+
+// cacheKey identifies the archive by its format and the exact files that
+// go into it.
+func (a *ResultsArchive) cacheKey() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n", a.Format)
+	for _, f := range a.files {
+		fmt.Fprintf(h, "%s\x00%d\x00%d\n", f.rel, f.size, f.mod.UnixNano())
+	}
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+func (a *ResultsArchive) cachePath() string {
+	return filepath.Join(a.cacheDir, a.key+"."+string(a.Format))
+}
+
+// OpenCached returns a cached copy of the archive and its checksum, if one
+// was built before.
+func (a *ResultsArchive) OpenCached() (*os.File, string, bool) {
+	if a.cacheDir == "" {
+		return nil, "", false
+	}
+	sum, err := os.ReadFile(a.cachePath() + ".sha256")
+	if err != nil {
+		return nil, "", false
+	}
+	file, err := os.Open(a.cachePath())
+	if err != nil {
+		return nil, "", false
+	}
+	// The modification time records the last use, for eviction.
+	now := time.Now()
+	os.Chtimes(a.cachePath(), now, now)
+	return file, strings.TrimSpace(string(sum)), true
+}
+
+// Stream writes the archive to w and returns the SHA-256 of the bytes
+// written. The last entry, SHA256SUMS, lists the checksum of every file in
+// the format of sha256sum. A failure leaves the archive unterminated, so a
+// client can't mistake a partial download for a complete one.
+func (a *ResultsArchive) Stream(w io.Writer) (string, error) {
+	var cache *cacheWriter
+	if a.cacheDir != "" {
+		if err := os.MkdirAll(a.cacheDir, 0755); err == nil {
+			if file, err := os.CreateTemp(a.cacheDir, "."+a.key+"-*"); err == nil {
+				cache = &cacheWriter{file: file, max: a.cacheMax}
+			}
+		}
+	}
+	if cache != nil {
+		defer os.Remove(cache.file.Name())
+		defer cache.file.Close()
+		w = io.MultiWriter(w, cache)
+	}
+
+	digest := sha256.New()
+	aw, err := archive.NewWriter(io.MultiWriter(w, digest), a.Format)
+	if err != nil {
+		return "", err
+	}
+
+	sort.Slice(a.files, func(i, j int) bool { return a.files[i].rel < a.files[j].rel })
+	var sums strings.Builder
+	for _, f := range a.files {
+		sum, err := addFile(aw, f)
+		if err != nil {
+			return "", fmt.Errorf("failed to archive %s: %w", f.rel, err)
+		}
+		fmt.Fprintf(&sums, "%s  %s\n", sum, f.rel)
+	}
+	err = aw.Add(checksumsName, int64(sums.Len()), time.Now(), strings.NewReader(sums.String()))
+	if err != nil {
+		return "", err
+	}
+	if err := aw.Close(); err != nil {
+		return "", err
+	}
+	sum := hex.EncodeToString(digest.Sum(nil))
+
+	if cache != nil {
+		a.keep(cache, sum)
+	}
+	return sum, nil
+}
+
+// cacheWriter copies a download into the cache. Problems with the cache,
+// such as a full volume or an archive larger than the whole cache, only
+// drop the copy; the download goes on.
+type cacheWriter struct {
+	file    *os.File
+	max     int64
+	written int64
+	failed  bool
+}
+
+func (c *cacheWriter) Write(p []byte) (int, error) {
+	if c.failed {
+		return len(p), nil
+	}
+	if c.written+int64(len(p)) > c.max {
+		c.failed = true
+		return len(p), nil
+	}
+	n, err := c.file.Write(p)
+	c.written += int64(n)
+	if err != nil {
+		c.failed = true
+	}
+	return len(p), nil
+}
+
+// keep moves a completely written archive into the cache and evicts older
+// ones beyond the size limit. The checksum goes first, so a cached archive
+// always has one.
+func (a *ResultsArchive) keep(cache *cacheWriter, sum string) {
+	if err := cache.file.Close(); err != nil || cache.failed {
+		return
+	}
+	if err := os.WriteFile(a.cachePath()+".sha256", []byte(sum+"\n"), 0644); err != nil {
+		return
+	}
+	if err := os.Rename(cache.file.Name(), a.cachePath()); err != nil {
+		return
+	}
+	a.evict()
+}
+
+// evict removes the least recently used archives until the cache holds at
+// most cacheMax bytes. Archives still being written are hidden and left
+// alone.
+func (a *ResultsArchive) evict() {
+	entries, err := os.ReadDir(a.cacheDir)
+	if err != nil {
+		return
+	}
+	type cached struct {
+		path string
+		size int64
+		used time.Time
+	}
+	var archives []cached
+	var total int64
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".sha256") || !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		archives = append(archives, cached{path: filepath.Join(a.cacheDir, name), size: info.Size(), used: info.ModTime()})
+		total += info.Size()
+	}
+
+	sort.Slice(archives, func(i, j int) bool { return archives[i].used.Before(archives[j].used) })
+	for _, c := range archives {
+		if total <= a.cacheMax {
+			return
+		}
+		if c.path == a.cachePath() {
+			continue
+		}
+		// The archive goes first, so no archive is left without a checksum.
+		if err := os.Remove(c.path); err != nil {
+			continue
+		}
+		os.Remove(c.path + ".sha256")
+		total -= c.size
+	}
+}
+
+func addFile(aw archive.Writer, f archiveFile) (string, error) {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if err := aw.Add(f.rel, f.size, f.mod, io.TeeReader(file, h)); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,194 @@
+package usecase
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/theweirdfulmurk/cfd-platform/internal/archive"
+	"github.com/theweirdfulmurk/cfd-platform/internal/domain"
+	"github.com/theweirdfulmurk/cfd-platform/internal/repository"
+)
+
+// archiveUseCase returns a use case with the finished simulation "s1",
+// whose results are the given files.
+func archiveUseCase(t *testing.T, files map[string]string, cacheBytes int64) (*SimulationUseCase, string) {
+	t.Helper()
+	repo := repository.NewInMemorySimulationRepo()
+	if err := repo.Create(&domain.Simulation{ID: "s1", Type: domain.SimTypeCFD, Status: domain.SimStatusCompleted}); err != nil {
+		t.Fatal(err)
+	}
+	uc := &SimulationUseCase{repo: repo, resultsPath: t.TempDir(), archiveCacheBytes: cacheBytes}
+	dir := filepath.Join(uc.resultsPath, "s1")
+	for name, text := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return uc, dir
+}
+
+var caseResults = map[string]string{
+	"0/U":            "internalField uniform (0 0 0);",
+	"0.5/U":          "internalField uniform (1 0 0);",
+	"1/U":            "internalField uniform (2 0 0);",
+	"log.simpleFoam": "Time = 1\n",
+}
+
+func zipNames(t *testing.T, data []byte) []string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+func TestResultsArchiveSelection(t *testing.T) {
+	from, to := 0.5, 0.5
+	tests := []struct {
+		name string
+		sel  ResultsSelection
+		want string
+	}{
+		{"everything", ResultsSelection{}, "0.5/U 0/U 1/U log.simpleFoam SHA256SUMS"},
+		{"latest", ResultsSelection{Latest: true}, "1/U log.simpleFoam SHA256SUMS"},
+		{"time range", ResultsSelection{From: &from, To: &to}, "0.5/U log.simpleFoam SHA256SUMS"},
+		{"include", ResultsSelection{Include: []string{"log.*"}}, "log.simpleFoam SHA256SUMS"},
+		{"exclude directory", ResultsSelection{Exclude: []string{"0*"}}, "1/U log.simpleFoam SHA256SUMS"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc, _ := archiveUseCase(t, caseResults, 0)
+			a, err := uc.PrepareResultsArchive("s1", archive.FormatZip, tt.sel)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			sum, err := a.Stream(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Join(zipNames(t, buf.Bytes()), " "); got != tt.want {
+				t.Errorf("archive has %s, want %s", got, tt.want)
+			}
+			if digest := sha256.Sum256(buf.Bytes()); sum != hex.EncodeToString(digest[:]) {
+				t.Errorf("Stream returned a checksum that doesn't match the bytes written")
+			}
+		})
+	}
+
+	uc, _ := archiveUseCase(t, caseResults, 0)
+	bad := []ResultsSelection{
+		{Include: []string{"["}},
+		{Latest: true, From: &from},
+		{From: &to, To: &[]float64{0.1}[0]},
+	}
+	for _, sel := range bad {
+		if _, err := uc.PrepareResultsArchive("s1", archive.FormatZip, sel); !errors.Is(err, ErrInvalidSelection) {
+			t.Errorf("selection %+v: err = %v, want ErrInvalidSelection", sel, err)
+		}
+	}
+}
+
+func TestResultsArchiveCache(t *testing.T) {
+	uc, dir := archiveUseCase(t, caseResults, 1<<20)
+	stream := func(sel ResultsSelection) (*ResultsArchive, []byte, string) {
+		t.Helper()
+		a, err := uc.PrepareResultsArchive("s1", archive.FormatZip, sel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		sum, err := a.Stream(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a, buf.Bytes(), sum
+	}
+
+	a, data, sum := stream(ResultsSelection{})
+	file, cachedSum, ok := a.OpenCached()
+	if !ok {
+		t.Fatal("finished archive was not cached")
+	}
+	cached, _ := io.ReadAll(file)
+	file.Close()
+	if !bytes.Equal(cached, data) || cachedSum != sum {
+		t.Error("cached archive differs from the download")
+	}
+
+	// A cache too small for both archives evicts the one used longest ago.
+	uc.archiveCacheBytes = int64(len(data))
+	hourAgo := time.Now().Add(-time.Hour)
+	os.Chtimes(a.cachePath(), hourAgo, hourAgo)
+	latest, _, _ := stream(ResultsSelection{Latest: true})
+	if _, _, ok := a.OpenCached(); ok {
+		t.Error("least recently used archive was not evicted")
+	}
+	if _, _, ok := latest.OpenCached(); !ok {
+		t.Error("most recently used archive was evicted")
+	}
+	if _, err := os.Stat(a.cachePath() + ".sha256"); !os.IsNotExist(err) {
+		t.Error("checksum of an evicted archive was kept")
+	}
+
+	// An archive larger than the whole cache is downloaded but not kept.
+	uc.archiveCacheBytes = 100
+	big, bigData, _ := stream(ResultsSelection{Include: []string{"0*"}})
+	if len(bigData) == 0 {
+		t.Fatal("download was cut short")
+	}
+	if _, _, ok := big.OpenCached(); ok {
+		t.Error("archive larger than the cache was kept")
+	}
+	if entries, _ := os.ReadDir(filepath.Join(dir, archiveCacheDir)); hasHidden(entries) {
+		t.Error("temporary cache file left behind")
+	}
+}
+
+func hasHidden(entries []os.DirEntry) bool {
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// A failing cache write must not fail the download.
+func TestCacheWriterDropsCopyOnError(t *testing.T) {
+	file, err := os.CreateTemp(t.TempDir(), "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close() // writes fail from now on
+
+	cache := &cacheWriter{file: file, max: 1 << 20}
+	var download bytes.Buffer
+	w := io.MultiWriter(&download, cache)
+	for i := 0; i < 3; i++ {
+		if _, err := io.WriteString(w, "chunk\n"); err != nil {
+			t.Fatalf("write %d: %v", i, err)
+		}
+	}
+	if download.String() != "chunk\nchunk\nchunk\n" || !cache.failed {
+		t.Errorf("download = %q, cache failed = %v", download.String(), cache.failed)
+	}
+}
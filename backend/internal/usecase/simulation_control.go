@@ -0,0 +1,134 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/theweirdfulmurk/cfd-platform/internal/domain"
+	"github.com/theweirdfulmurk/cfd-platform/internal/openfoam"
+)
+
+// ErrInvalidState is returned when an operation doesn't apply to the
+// simulation's current status, e.g. resuming a run that isn't suspended.
+var ErrInvalidState = errors.New("invalid simulation state")
+
+// Cancel stops a simulation for good. The Job is deleted but the record and
+// whatever results were written are kept.
+func (uc *SimulationUseCase) Cancel(simID string) (*domain.Simulation, error) {
+	sim, err := uc.repo.GetByID(simID)
+	if err != nil {
+		return nil, err
+	}
+	if !isActiveSimulation(sim.Status) {
+		return nil, fmt.Errorf("%w: cannot cancel a %s simulation", ErrInvalidState, sim.Status)
+	}
+
+	now := time.Now()
+	sim.Status = domain.SimStatusCancelled
+	sim.StatusReason = "cancelled by user"
+	sim.CompletedAt = &now
+	if err := uc.repo.Update(sim); err != nil {
+		return nil, fmt.Errorf("failed to update simulation: %w", err)
+	}
+	uc.events.Publish(sim)
+
+	// Keep the log before the pod goes away with the Job; fetching it can
+	// take a while, so the request doesn't wait.
+	go func() {
+		uc.archiveLogs(simID)
+		if err := uc.k8sManager.DeleteJob(simID); err != nil {
+			log.Printf("failed to delete job of cancelled simulation %s: %v", simID, err)
+		}
+	}()
+
+	return sim, nil
+}
+
+// Suspend pauses a simulation by suspending its Job, which terminates the
+// solver pod. Only runs that can pick up where they stopped are suspended:
+// OpenFOAM solvers continue from their latest time, while CalculiX and
+// Allrun scripts would start over.
+func (uc *SimulationUseCase) Suspend(simID string) (*domain.Simulation, error) {
+	sim, err := uc.repo.GetByID(simID)
+	if err != nil {
+		return nil, err
+	}
+	if sim.Status != domain.SimStatusPending && sim.Status != domain.SimStatusRunning {
+		return nil, fmt.Errorf("%w: cannot suspend a %s simulation", ErrInvalidState, sim.Status)
+	}
+	if sim.Type != domain.SimTypeCFD || sim.Run.Allrun {
+		return nil, fmt.Errorf("%w: this run would start over when resumed, cancel it instead", ErrInvalidState)
+	}
+
+	if err := uc.k8sManager.SuspendJob(simID); err != nil {
+		return nil, fmt.Errorf("failed to suspend job: %w", err)
+	}
+
+	sim.Status = domain.SimStatusSuspended
+	sim.StatusReason = "suspended by user"
+	if err := uc.repo.Update(sim); err != nil {
+		return nil, fmt.Errorf("failed to update simulation: %w", err)
+	}
+	uc.events.Publish(sim)
+
+	return sim, nil
+}
+
+// Resume lets a suspended Job start a new pod, which continues the run: the
+// stages completed before the suspend are skipped and the solver starts
+// from the latest time it wrote.
+func (uc *SimulationUseCase) Resume(simID string) (*domain.Simulation, error) {
+	sim, err := uc.repo.GetByID(simID)
+	if err != nil {
+		return nil, err
+	}
+	if sim.Status != domain.SimStatusSuspended {
+		return nil, fmt.Errorf("%w: cannot resume a %s simulation", ErrInvalidState, sim.Status)
+	}
+
+	caseDir, err := openfoam.FindCaseDir(filepath.Join(uc.storagePath, sim.ConfigPath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate case of %s: %w", simID, err)
+	}
+	restore, err := patchControlDict(caseDir, func(controlDict []byte) []byte {
+		return openfoam.SetTopLevelEntry(controlDict, "startFrom", "latestTime")
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := uc.k8sManager.ResumeJob(simID); err != nil {
+		restore()
+		return nil, fmt.Errorf("failed to resume job: %w", err)
+	}
+
+	sim.Status = domain.SimStatusPending
+	sim.StatusReason = ""
+	if err := uc.repo.Update(sim); err != nil {
+		return nil, fmt.Errorf("failed to update simulation: %w", err)
+	}
+	uc.events.Publish(sim)
+
+	return sim, nil
+}
+
+// patchControlDict rewrites the controlDict of caseDir with patch and
+// returns a function that puts the original back.
+func patchControlDict(caseDir string, patch func([]byte) []byte) (restore func(), err error) {
+	path := filepath.Join(caseDir, "system", "controlDict")
+	original, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read controlDict: %w", err)
+	}
+	if err := os.WriteFile(path, patch(original), 0644); err != nil {
+		return nil, fmt.Errorf("failed to update controlDict: %w", err)
+	}
+	return func() {
+		if err := os.WriteFile(path, original, 0644); err != nil {
+			log.Printf("failed to restore %s: %v", path, err)
+		}
+	}, nil
+}
@@ -0,0 +1,88 @@
+package usecase
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/theweirdfulmurk/cfd-platform/internal/domain"
+	"github.com/theweirdfulmurk/cfd-platform/internal/openfoam"
+)
+
+func TestSuspend(t *testing.T) {
+	tests := []struct {
+		name    string
+		sim     domain.Simulation
+		wantErr error
+	}{
+		{"OpenFOAM run", domain.Simulation{Type: domain.SimTypeCFD, Status: domain.SimStatusRunning}, nil},
+		{"pending run", domain.Simulation{Type: domain.SimTypeCFD, Status: domain.SimStatusPending}, nil},
+		{"Allrun script", domain.Simulation{Type: domain.SimTypeCFD, Status: domain.SimStatusRunning, Run: domain.RunPlan{Allrun: true}}, ErrInvalidState},
+		{"CalculiX run", domain.Simulation{Type: domain.SimTypeFEA, Status: domain.SimStatusRunning}, ErrInvalidState},
+		{"finished run", domain.Simulation{Type: domain.SimTypeCFD, Status: domain.SimStatusCompleted}, ErrInvalidState},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc := newTestUseCase(t, &fakeK8s{})
+			sim := tt.sim
+			sim.ID = "s1"
+			if err := uc.repo.Create(&sim); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := uc.Suspend("s1")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Suspend error = %v, want %v", err, tt.wantErr)
+			}
+			stored, _ := uc.repo.GetByID("s1")
+			if suspended := stored.Status == domain.SimStatusSuspended; suspended != (tt.wantErr == nil) {
+				t.Errorf("status = %s", stored.Status)
+			}
+		})
+	}
+}
+
+// A resumed run continues from the latest time the solver wrote.
+func TestResume(t *testing.T) {
+	resumeErr := errors.New("job not found")
+	for _, tt := range []struct {
+		name      string
+		resumeErr error
+		want      string
+	}{
+		{"resumed", nil, "latestTime"},
+		{"job not resumed", resumeErr, "startTime"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			k8s := &fakeK8s{resumeErr: tt.resumeErr}
+			uc := newTestUseCase(t, k8s)
+			caseDir := writeCase(t, uc, "s1", cubeCase)
+			if err := uc.repo.Create(&domain.Simulation{ID: "s1", Type: domain.SimTypeCFD, Status: domain.SimStatusSuspended, ConfigPath: "s1"}); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := uc.Resume("s1")
+			if !errors.Is(err, tt.resumeErr) {
+				t.Fatalf("Resume error = %v, want %v", err, tt.resumeErr)
+			}
+
+			controlDict, err := os.ReadFile(filepath.Join(caseDir, "system", "controlDict"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if startFrom, _ := openfoam.TopLevelEntry(controlDict, "startFrom"); startFrom != tt.want {
+				t.Errorf("startFrom = %q, want %q", startFrom, tt.want)
+			}
+			stored, _ := uc.repo.GetByID("s1")
+			wantStatus := domain.SimStatusPending
+			if tt.resumeErr != nil {
+				wantStatus = domain.SimStatusSuspended
+			}
+			if stored.Status != wantStatus {
+				t.Errorf("status = %s, want %s", stored.Status, wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package usecase
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	solverLogName     = "solver.log"
+	logArchiveTimeout = 2 * time.Minute
+)
+
+// StreamLogs returns the solver output of a simulation. While the Job's pod
+// exists the log comes from Kubernetes; afterwards the copy archived into the
+// results directory is served instead (follow has no effect then).
+func (uc *SimulationUseCase) StreamLogs(ctx context.Context, simID string, follow bool, tailLines int64) (io.ReadCloser, error) {
+	logs, err := uc.k8sManager.StreamLogs(ctx, simID, follow, tailLines)
+	if err == nil {
+		return logs, nil
+	}
+
+	archived, archiveErr := openArchivedLog(filepath.Join(uc.resultsPath, simID, solverLogName), tailLines)
+	if archiveErr != nil {
+		return nil, fmt.Errorf("logs not available: %w", err)
+	}
+	return archived, nil
+}
+
+// archiveLogs copies the complete solver log into the results directory so
+// it outlives the pod.
+func (uc *SimulationUseCase) archiveLogs(simID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), logArchiveTimeout)
+	defer cancel()
+
+	logs, err := uc.k8sManager.StreamLogs(ctx, simID, false, 0)
+	if err != nil {
+		log.Printf("failed to fetch logs of simulation %s for archiving: %v", simID, err)
+		return
+	}
+	defer logs.Close()
+
+	if err := writeFileAtomic(filepath.Join(uc.resultsPath, simID, solverLogName), logs); err != nil {
+		log.Printf("failed to archive logs of simulation %s: %v", simID, err)
+	}
+}
+
+// writeFileAtomic writes to a temp file next to path and renames it into
+// place, so readers never see a partially written file.
+func writeFileAtomic(path string, r io.Reader) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func openArchivedLog(path string, tailLines int64) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if tailLines <= 0 {
+		return file, nil
+	}
+	defer file.Close()
+
+	// A ring of the last tailLines lines; it only grows as far as the log
+	// is long.
+	var (
+		lines []string
+		next  int
+	)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if int64(len(lines)) < tailLines {
+			lines = append(lines, scanner.Text())
+			continue
+		}
+		lines[next] = scanner.Text()
+		next = (next + 1) % len(lines)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	lines = append(lines[next:], lines[:next]...)
+	return io.NopCloser(strings.NewReader(strings.Join(lines, "\n") + "\n")), nil
+}
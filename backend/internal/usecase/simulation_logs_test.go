@@ -0,0 +1,41 @@
+package usecase
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenArchivedLogTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), solverLogName)
+	if err := os.WriteFile(path, []byte("1\n2\n3\n4\n5\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		tail int64
+		want string
+	}{
+		{0, "1\n2\n3\n4\n5\n"},
+		{1, "5\n"},
+		{2, "4\n5\n"},
+		{3, "3\n4\n5\n"},
+		{5, "1\n2\n3\n4\n5\n"},
+		{100000, "1\n2\n3\n4\n5\n"},
+	}
+	for _, tt := range tests {
+		r, err := openArchivedLog(path, tt.tail)
+		if err != nil {
+			t.Fatalf("tail %d: %v", tt.tail, err)
+		}
+		got, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatalf("tail %d: %v", tt.tail, err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("tail %d = %q, want %q", tt.tail, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package usecase
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/theweirdfulmurk/cfd-platform/internal/domain"
+	"github.com/theweirdfulmurk/cfd-platform/internal/openfoam"
+)
+
+// reportMesh analyses the mesh an uploaded case ships with and keeps the
+// report next to the simulation's results. A mesh that can't be read
+// rejects the upload; a mesh that fails quality checks is only reported.
+func (uc *SimulationUseCase) reportMesh(simDir, simID string) error {
+	if !openfoam.HasPolyMesh(simDir) {
+		return nil
+	}
+
+	report, err := openfoam.AnalyzeCaseMesh(simDir)
+	if err != nil {
+		return fmt.Errorf("%w: %v", domain.ErrInvalidCase, err)
+	}
+	if err := openfoam.WriteMeshReport(filepath.Join(uc.resultsPath, simID), report); err != nil {
+		return fmt.Errorf("failed to store mesh report: %w", err)
+	}
+	return nil
+}
+
+// GetMeshReport returns the quality report of a simulation's mesh.
+func (uc *SimulationUseCase) GetMeshReport(simID string) (*openfoam.MeshReport, error) {
+	if _, err := uc.repo.GetByID(simID); err != nil {
+		return nil, err
+	}
+	return openfoam.ReadMeshReport(filepath.Join(uc.resultsPath, simID))
+}
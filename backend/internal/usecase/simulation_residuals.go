@@ -0,0 +1,47 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/theweirdfulmurk/cfd-platform/internal/domain"
+	"github.com/theweirdfulmurk/cfd-platform/internal/openfoam"
+)
+
+// GetResiduals returns the convergence history of a CFD simulation: live
+// while it runs, then from the complete solver log archived when the run
+// ended. The copy persisted while following the run may stop short where
+// the stream was interrupted, so it only stands in until the log is
+// archived; runs without either fall back to the pod's log.
+func (uc *SimulationUseCase) GetResiduals(simID string) (*openfoam.Residuals, error) {
+	sim, err := uc.repo.GetByID(simID)
+	if err != nil {
+		return nil, err
+	}
+	if sim.Type != domain.SimTypeCFD {
+		return nil, fmt.Errorf("residuals are only available for CFD simulations")
+	}
+
+	if residuals, ok := uc.residuals.Residuals(simID); ok {
+		return residuals, nil
+	}
+
+	if file, err := os.Open(filepath.Join(uc.resultsPath, simID, solverLogName)); err == nil {
+		defer file.Close()
+		return openfoam.ParseResiduals(file)
+	}
+
+	if residuals, err := uc.residuals.load(simID); err == nil {
+		return residuals, nil
+	}
+
+	logs, err := uc.k8sManager.StreamLogs(context.Background(), simID, false, 0)
+	if err != nil {
+		return nil, fmt.Errorf("residuals not available: %w", err)
+	}
+	defer logs.Close()
+
+	return openfoam.ParseResiduals(logs)
+}
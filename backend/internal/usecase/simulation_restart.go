@@ -0,0 +1,162 @@
+package usecase
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/theweirdfulmurk/cfd-platform/internal/domain"
+	"github.com/theweirdfulmurk/cfd-platform/internal/openfoam"
+	"github.com/theweirdfulmurk/cfd-platform/internal/runner"
+)
+
+// Restart continues a finished CFD simulation from its latest written time
+// directory. The child run works in the parent's case directory, which is
+// patched to start from latestTime and, if endTime is given, to run longer.
+// The patch is undone if the child can't be started.
+func (uc *SimulationUseCase) Restart(parentID, name string, endTime *float64) (*domain.Simulation, error) {
+	parent, err := uc.repo.GetByID(parentID)
+	if err != nil {
+		return nil, err
+	}
+	if parent.Type != domain.SimTypeCFD {
+		return nil, fmt.Errorf("%w: only CFD simulations can be restarted", ErrInvalidState)
+	}
+	if isActiveSimulation(parent.Status) {
+		return nil, fmt.Errorf("%w: cannot restart a %s simulation", ErrInvalidState, parent.Status)
+	}
+
+	caseDir, err := openfoam.FindCaseDir(filepath.Join(uc.storagePath, parent.ConfigPath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate case of %s: %w", parentID, err)
+	}
+
+	// Held until the child is saved, so two restarts can't both find the
+	// case free.
+	unlock, err := uc.lockRestarts()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if running, err := uc.caseInUse(caseDir); err != nil {
+		return nil, err
+	} else if running != nil {
+		return nil, fmt.Errorf("%w: the case is in use by %s simulation %s", ErrInvalidState, running.Status, running.ID)
+	}
+
+	latestName, latest, err := openfoam.LatestTime(caseDir)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidState, err)
+	}
+	if latest <= 0 {
+		return nil, fmt.Errorf("%w: no time step was written after the initial conditions", ErrInvalidState)
+	}
+	if endTime != nil && *endTime <= latest {
+		return nil, fmt.Errorf("%w: endTime %g must be after the latest time %s", ErrInvalidState, *endTime, latestName)
+	}
+
+	restore, err := patchControlDict(caseDir, func(controlDict []byte) []byte {
+		controlDict = openfoam.SetTopLevelEntry(controlDict, "startFrom", "latestTime")
+		if endTime != nil {
+			controlDict = openfoam.SetTopLevelEntry(controlDict, "endTime", strconv.FormatFloat(*endTime, 'g', -1, 64))
+		}
+		return controlDict
+	})
+	if err != nil {
+		return nil, err
+	}
+	// The parent's case stays as it was unless the child's Job runs on it.
+	submitted := false
+	defer func() {
+		if !submitted {
+			restore()
+		}
+	}()
+
+	configPath, err := filepath.Rel(uc.storagePath, caseDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if name == "" {
+		name = parent.Name + " (restart)"
+	}
+
+	simID := uuid.New().String()[:8]
+	sim := &domain.Simulation{
+		ID:         simID,
+		Name:       name,
+		Type:       parent.Type,
+		Status:     domain.SimStatusPending,
+		PodName:    fmt.Sprintf("sim-%s", simID),
+		ResultPath: fmt.Sprintf("results/%s", simID),
+		ConfigPath: configPath,
+		CreatedAt:  time.Now(),
+		Divergence: parent.Divergence,
+		ParentID:   parent.ID,
+		Resources:  parent.Resources,
+		Subdomains: parent.Subdomains,
+		Nodes:      parent.Nodes,
+		Solver:     parent.Solver,
+		Run: domain.RunPlan{
+			Application: parent.Run.Application,
+			PostProcess: parent.Run.PostProcess,
+		},
+	}
+
+	if err := uc.submit(sim); err != nil {
+		return nil, err
+	}
+	submitted = true
+
+	return sim, nil
+}
+
+// restartLockName is locked on the shared volume while a restart checks
+// that its case is free, which keeps out restarts on other replicas.
+const restartLockName = ".restart.lock"
+
+// lockRestarts serialises restarts across replicas. The file lock works over
+// NFS, but the kernel keeps it per process, so restartMu keeps out the
+// replica's own other requests.
+func (uc *SimulationUseCase) lockRestarts() (unlock func(), err error) {
+	uc.restartMu.Lock()
+	file, err := os.OpenFile(filepath.Join(uc.storagePath, restartLockName), os.O_RDWR|os.O_CREATE, 0644)
+	if err == nil {
+		if err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+			file.Close()
+		}
+	}
+	if err != nil {
+		uc.restartMu.Unlock()
+		return nil, fmt.Errorf("failed to lock restarts: %w", err)
+	}
+	return func() {
+		// Closing the file releases the lock.
+		file.Close()
+		uc.restartMu.Unlock()
+	}, nil
+}
+
+// caseInUse returns an active simulation working in caseDir, if any. Runs
+// of the same case write into the same time directories.
+func (uc *SimulationUseCase) caseInUse(caseDir string) (*domain.Simulation, error) {
+	sims, err := uc.repo.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, sim := range sims {
+		if !isActiveSimulation(sim.Status) {
+			continue
+		}
+		dir := filepath.Join(uc.storagePath, sim.ConfigPath)
+		if runner.Within(dir, caseDir) || runner.Within(caseDir, dir) {
+			return sim, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,56 @@
+package usecase
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/theweirdfulmurk/cfd-platform/internal/domain"
+	"github.com/theweirdfulmurk/cfd-platform/internal/openfoam"
+)
+
+func TestRestartControlDict(t *testing.T) {
+	jobErr := errors.New("quota exceeded")
+	endTime := 1.0
+	for _, tt := range []struct {
+		name      string
+		jobErr    error
+		startFrom string
+		endTime   string
+	}{
+		{"child started", nil, "latestTime", "1"},
+		{"child not started", jobErr, "startTime", "0.5"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			uc := newTestUseCase(t, &fakeK8s{createErr: tt.jobErr})
+			caseDir := writeCase(t, uc, "s1", caseWith(map[string]string{
+				"0.5/p": cubeCase["0/p"],
+			}))
+			parent := &domain.Simulation{ID: "s1", Name: "cube", Type: domain.SimTypeCFD, Status: domain.SimStatusCompleted, ConfigPath: "s1"}
+			if err := uc.repo.Create(parent); err != nil {
+				t.Fatal(err)
+			}
+			controlDictPath := filepath.Join(caseDir, "system", "controlDict")
+
+			_, err := uc.Restart("s1", "", &endTime)
+			if !errors.Is(err, tt.jobErr) {
+				t.Fatalf("Restart error = %v, want %v", err, tt.jobErr)
+			}
+
+			controlDict, err := os.ReadFile(controlDictPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.jobErr != nil && string(controlDict) != cubeCase["system/controlDict"] {
+				t.Errorf("controlDict of the parent changed:\n%s", controlDict)
+			}
+			if got, _ := openfoam.TopLevelEntry(controlDict, "startFrom"); got != tt.startFrom {
+				t.Errorf("startFrom = %q, want %q", got, tt.startFrom)
+			}
+			if got, _ := openfoam.TopLevelEntry(controlDict, "endTime"); got != tt.endTime {
+				t.Errorf("endTime = %q, want %q", got, tt.endTime)
+			}
+		})
+	}
+}
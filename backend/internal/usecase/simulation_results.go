@@ -0,0 +1,97 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/theweirdfulmurk/cfd-platform/internal/casefile"
+	"github.com/theweirdfulmurk/cfd-platform/internal/runner"
+)
+
+// ErrInvalidPath is returned for a result file path that leaves the
+// simulation's results directory.
+var ErrInvalidPath = errors.New("invalid result path")
+
+// ListResultFiles lists the files in a simulation's results directory with
+// their kind, size, modification time and checksum, plus one entry per
+// time directory. Checksums come from the manifest written when the
+// outputs were staged; files added or changed since are listed without one.
+func (uc *SimulationUseCase) ListResultFiles(simID string) ([]runner.ManifestEntry, error) {
+	if _, err := uc.repo.GetByID(simID); err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(uc.resultsPath, simID)
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+
+	previous, _ := runner.ReadManifest(dir) // absent while the run is going
+	manifest, err := runner.ListResults(dir, previous)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list results of %s: %w", simID, err)
+	}
+
+	times := make(map[string]*runner.ManifestEntry)
+	for _, f := range manifest.Files {
+		if f.Time == "" {
+			continue
+		}
+		t, ok := times[f.Time]
+		if !ok {
+			t = &runner.ManifestEntry{Path: f.Time, Kind: runner.KindTime, Time: f.Time}
+			times[f.Time] = t
+		}
+		t.Size += f.Size
+		if f.Modified.After(t.Modified) {
+			t.Modified = f.Modified
+		}
+	}
+
+	files := manifest.Files
+	for _, t := range times {
+		files = append(files, *t)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// OpenResultFile opens a single result file by its slash-separated path
+// relative to the simulation's results directory.
+func (uc *SimulationUseCase) OpenResultFile(simID, rel string) (*os.File, os.FileInfo, error) {
+	if _, err := uc.repo.GetByID(simID); err != nil {
+		return nil, nil, err
+	}
+	rel = filepath.FromSlash(rel)
+	if !runner.IsLocal(rel) {
+		return nil, nil, fmt.Errorf("%w: %q", ErrInvalidPath, rel)
+	}
+
+	// Results are written by solver pods; resolve links before trusting
+	// where a path ends up.
+	root := filepath.Join(uc.resultsPath, simID)
+	path, err := casefile.Resolve(root, filepath.Join(root, rel))
+	if errors.Is(err, casefile.ErrOutside) {
+		return nil, nil, fmt.Errorf("%w: %q", ErrInvalidPath, rel)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	if !info.Mode().IsRegular() {
+		file.Close()
+		return nil, nil, fmt.Errorf("%w: %q is not a file", ErrInvalidPath, rel)
+	}
+	return file, info, nil
+}
@@ -0,0 +1,156 @@
+package usecase
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/theweirdfulmurk/cfd-platform/internal/domain"
+	"github.com/theweirdfulmurk/cfd-platform/internal/repository"
+	"github.com/theweirdfulmurk/cfd-platform/internal/runner"
+)
+
+// resultsUseCase returns a use case whose simulation "s1" has results in a
+// temporary directory, next to the results of another simulation.
+func resultsUseCase(t *testing.T) (*SimulationUseCase, string) {
+	t.Helper()
+	repo := repository.NewInMemorySimulationRepo()
+	if err := repo.Create(&domain.Simulation{ID: "s1", Type: domain.SimTypeCFD}); err != nil {
+		t.Fatal(err)
+	}
+	resultsPath := t.TempDir()
+	uc := &SimulationUseCase{repo: repo, resultsPath: resultsPath}
+
+	files := map[string]string{
+		"s1/0/U":            "internalField uniform (0 0 0);",
+		"s1/0.5/U":          "internalField uniform (1 0 0);",
+		"s1/log.simpleFoam": "Time = 0.5\n",
+		"s2/secret":         "not yours",
+	}
+	for name, text := range files {
+		path := filepath.Join(resultsPath, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dir := filepath.Join(resultsPath, "s1")
+	for name, target := range map[string]string{"escape": "../s2/secret", "logs": "log.simpleFoam"} {
+		if err := os.Symlink(target, filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return uc, dir
+}
+
+func TestOpenResultFile(t *testing.T) {
+	uc, _ := resultsUseCase(t)
+
+	tests := []struct {
+		sim, rel string
+		want     error // nil for a file that opens
+	}{
+		{sim: "s1", rel: "0.5/U"},
+		{sim: "s1", rel: "log.simpleFoam"},
+		{sim: "s1", rel: "logs"},
+		{sim: "s1", rel: "../s2/secret", want: ErrInvalidPath},
+		{sim: "s1", rel: "0/../../s2/secret", want: ErrInvalidPath},
+		{sim: "s1", rel: "/etc/passwd", want: ErrInvalidPath},
+		{sim: "s1", rel: "", want: ErrInvalidPath},
+		{sim: "s1", rel: "escape", want: ErrInvalidPath},
+		{sim: "s1", rel: "0", want: ErrInvalidPath},
+		{sim: "s1", rel: "missing", want: os.ErrNotExist},
+		{sim: "s3", rel: "0/U", want: repository.ErrNotFound},
+	}
+	for _, tt := range tests {
+		file, _, err := uc.OpenResultFile(tt.sim, tt.rel)
+		if err == nil {
+			file.Close()
+		}
+		if tt.want == nil && err != nil || tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("OpenResultFile(%s, %q) = %v, want %v", tt.sim, tt.rel, err, tt.want)
+		}
+	}
+}
+
+// The handler serves result files with http.ServeContent, which needs the
+// file to seek for Range requests.
+func TestOpenResultFileRange(t *testing.T) {
+	uc, _ := resultsUseCase(t)
+	file, info, err := uc.OpenResultFile("s1", "log.simpleFoam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	tests := []struct {
+		rng    string
+		status int
+		body   string
+	}{
+		{"", http.StatusOK, "Time = 0.5\n"},
+		{"bytes=0-3", http.StatusPartialContent, "Time"},
+		{"bytes=7-", http.StatusPartialContent, "0.5\n"},
+		{"bytes=-4", http.StatusPartialContent, "0.5\n"},
+		{"bytes=100-", http.StatusRequestedRangeNotSatisfiable, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.rng != "" {
+			req.Header.Set("Range", tt.rng)
+		}
+		rec := httptest.NewRecorder()
+		http.ServeContent(rec, req, info.Name(), info.ModTime(), file)
+
+		body, _ := io.ReadAll(rec.Body)
+		if rec.Code != tt.status || tt.status != http.StatusRequestedRangeNotSatisfiable && string(body) != tt.body {
+			t.Errorf("Range %q = %d %q, want %d %q", tt.rng, rec.Code, body, tt.status, tt.body)
+		}
+	}
+}
+
+func TestListResultFiles(t *testing.T) {
+	uc, dir := resultsUseCase(t)
+
+	manifest, err := runner.BuildManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := runner.WriteManifest(dir, manifest); err != nil {
+		t.Fatal(err)
+	}
+	// Written after staging, e.g. by a restart that is still running.
+	if err := os.WriteFile(filepath.Join(dir, "log.restart"), []byte("Time = 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := uc.ListResultFiles("s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]runner.ManifestEntry)
+	for _, f := range files {
+		got[f.Path] = f
+	}
+	if f := got["0.5/U"]; f.Kind != runner.KindField || f.Time != "0.5" || f.SHA256 == "" {
+		t.Errorf("0.5/U = %+v, want a field with a checksum", f)
+	}
+	if f := got["0.5"]; f.Kind != runner.KindTime || f.Size != got["0.5/U"].Size {
+		t.Errorf("0.5 = %+v, want a time directory", f)
+	}
+	if f, ok := got["log.restart"]; !ok || f.SHA256 != "" {
+		t.Errorf("log.restart = %+v, want it listed without a checksum", f)
+	}
+	if _, ok := got[runner.ManifestFile]; ok {
+		t.Errorf("the manifest lists itself")
+	}
+	if _, ok := got["escape"]; ok {
+		t.Errorf("a symlink is listed")
+	}
+}
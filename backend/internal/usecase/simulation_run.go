@@ -0,0 +1,60 @@
+package usecase
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/theweirdfulmurk/cfd-platform/internal/domain"
+	"github.com/theweirdfulmurk/cfd-platform/internal/openfoam"
+)
+
+// maxPostProcess bounds the function objects run after the solver.
+const maxPostProcess = 20
+
+// functionObject is a function name with optional arguments, as accepted by
+// "-func", e.g. "wallShearStress" or "patchAverage(name=outlet, p)".
+var functionObject = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*(\([^()\n]*\))?$`)
+
+// checkRunOptions rejects run options that don't apply to the simulation.
+func checkRunOptions(params SimulationParams) error {
+	if params.Type != domain.SimTypeCFD && (params.Allrun || len(params.PostProcess) > 0) {
+		return fmt.Errorf("%w: allrun and postProcess only apply to CFD runs", domain.ErrInvalidRunOptions)
+	}
+	if params.Allrun && (params.Subdomains > 1 || params.Nodes > 1) {
+		return fmt.Errorf("%w: Allrun scripts only run serially", domain.ErrInvalidRunOptions)
+	}
+	if params.Allrun && len(params.PostProcess) > 0 {
+		return fmt.Errorf("%w: postProcess is up to the Allrun script", domain.ErrInvalidRunOptions)
+	}
+	if len(params.PostProcess) > maxPostProcess {
+		return fmt.Errorf("%w: at most %d postProcess functions", domain.ErrInvalidRunOptions, maxPostProcess)
+	}
+	for _, fn := range params.PostProcess {
+		if !functionObject.MatchString(fn) {
+			return fmt.Errorf("%w: %q is not a function object", domain.ErrInvalidRunOptions, fn)
+		}
+	}
+	return nil
+}
+
+// planRun reads the solver from an unpacked case's controlDict. The Job
+// runs it, after meshing, unless the case opts into its Allrun script.
+func planRun(params SimulationParams, simDir string) (domain.RunPlan, error) {
+	plan := domain.RunPlan{Allrun: params.Allrun, PostProcess: params.PostProcess}
+
+	app, err := openfoam.Application(simDir)
+	if err != nil {
+		return plan, fmt.Errorf("%w: %v", domain.ErrInvalidCase, err)
+	}
+	plan.Application = app
+
+	if params.Allrun {
+		info, err := os.Stat(filepath.Join(simDir, "Allrun"))
+		if err != nil || !info.Mode().IsRegular() {
+			return plan, fmt.Errorf("%w: allrun requested but the case has no Allrun script", domain.ErrInvalidCase)
+		}
+	}
+	return plan, nil
+}
@@ -0,0 +1,35 @@
+package usecase
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/theweirdfulmurk/cfd-platform/internal/runner"
+)
+
+// GetStages returns the per-stage exit codes the solver container's runner
+// recorded for a simulation.
+func (uc *SimulationUseCase) GetStages(simID string) (*runner.Status, error) {
+	if _, err := uc.repo.GetByID(simID); err != nil {
+		return nil, err
+	}
+	return runner.ReadStatus(filepath.Join(uc.resultsPath, simID))
+}
+
+// stageFailure names the stage that failed a run, which says more than the
+// Job's own "BackoffLimitExceeded". fallback is used when the runner left
+// no record, e.g. because the pod never started.
+func (uc *SimulationUseCase) stageFailure(simID, fallback string) string {
+	status, err := runner.ReadStatus(filepath.Join(uc.resultsPath, simID))
+	if err != nil {
+		return fallback
+	}
+	failed := status.Failed()
+	if failed == nil {
+		return fallback
+	}
+	if failed.Error != "" {
+		return fmt.Sprintf("stage %s failed: %s", failed.Name, failed.Error)
+	}
+	return fmt.Sprintf("stage %s exited with code %d", failed.Name, failed.ExitCode)
+}
@@ -0,0 +1,239 @@
+package usecase
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/theweirdfulmurk/cfd-platform/internal/archive"
+	"github.com/theweirdfulmurk/cfd-platform/internal/config"
+	"github.com/theweirdfulmurk/cfd-platform/internal/domain"
+	"github.com/theweirdfulmurk/cfd-platform/internal/openfoam"
+	"github.com/theweirdfulmurk/cfd-platform/internal/repository"
+)
+
+// fakeK8s records the Jobs the use case manages.
+type fakeK8s struct {
+	createErr error
+	resumeErr error
+	created   []*domain.Simulation
+	resumed   []string
+	jobs      []*domain.Simulation
+	gone      map[string]bool // Jobs deleted after they were listed
+}
+
+func (k *fakeK8s) CreateJob(sim *domain.Simulation) error {
+	if k.createErr != nil {
+		return k.createErr
+	}
+	k.created = append(k.created, sim)
+	return nil
+}
+
+func (k *fakeK8s) GetJobStatus(simID string) (domain.SimulationStatus, error) {
+	if k.gone[simID] {
+		return "", errors.New("job not found")
+	}
+	return domain.SimStatusRunning, nil
+}
+
+func (k *fakeK8s) ListJobs() ([]*domain.Simulation, error) { return k.jobs, nil }
+func (k *fakeK8s) DeleteJob(string) error                  { return nil }
+func (k *fakeK8s) SuspendJob(string) error                 { return nil }
+
+func (k *fakeK8s) ResumeJob(simID string) error {
+	if k.resumeErr != nil {
+		return k.resumeErr
+	}
+	k.resumed = append(k.resumed, simID)
+	return nil
+}
+
+func (k *fakeK8s) StreamLogs(context.Context, string, bool, int64) (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(nil)), nil
+}
+
+// newTestUseCase returns a use case that keeps cases and results in
+// temporary directories.
+func newTestUseCase(t *testing.T, k8s *fakeK8s) *SimulationUseCase {
+	t.Helper()
+	return &SimulationUseCase{
+		repo:        repository.NewInMemorySimulationRepo(),
+		k8sManager:  k8s,
+		resources:   config.DefaultResourcePolicy(),
+		solvers:     config.DefaultSolverCatalog(),
+		limits:      archive.DefaultLimits(),
+		events:      NewEventBroker(10),
+		storagePath: t.TempDir(),
+		resultsPath: t.TempDir(),
+	}
+}
+
+func foamHeader(class, object string) string {
+	return "FoamFile\n{\n    version 2.0;\n    format ascii;\n    class " + class + ";\n    object " + object + ";\n}\n"
+}
+
+// cubeCase is a case with a single-cell mesh of one wall patch.
+var cubeCase = map[string]string{
+	"system/controlDict": foamHeader("dictionary", "controlDict") +
+		"application icoFoam;\nstartFrom startTime;\nstartTime 0;\nstopAt endTime;\nendTime 0.5;\ndeltaT 0.005;\nwriteControl timeStep;\nwriteInterval 20;\n",
+	"system/fvSchemes": foamHeader("dictionary", "fvSchemes") +
+		"ddtSchemes {}\ngradSchemes {}\ndivSchemes {}\nlaplacianSchemes {}\ninterpolationSchemes {}\nsnGradSchemes {}\n",
+	"system/fvSolution": foamHeader("dictionary", "fvSolution") + "solvers\n{\n    p { solver PCG; }\n}\n",
+	"0/p": foamHeader("volScalarField", "p") +
+		"dimensions [0 2 -2 0 0 0 0];\ninternalField uniform 0;\nboundaryField\n{\n    walls { type zeroGradient; }\n}\n",
+	"constant/polyMesh/points": foamHeader("vectorField", "points") +
+		"8\n(\n(0 0 0) (1 0 0) (1 1 0) (0 1 0)\n(0 0 1) (1 0 1) (1 1 1) (0 1 1)\n)\n",
+	"constant/polyMesh/faces": foamHeader("faceList", "faces") +
+		"6\n(\n4(0 3 2 1)\n4(4 5 6 7)\n4(0 1 5 4)\n4(3 7 6 2)\n4(0 4 7 3)\n4(1 2 6 5)\n)\n",
+	"constant/polyMesh/owner":     foamHeader("labelList", "owner") + "6{0}\n",
+	"constant/polyMesh/neighbour": foamHeader("labelList", "neighbour") + "0()\n",
+	"constant/polyMesh/boundary": foamHeader("polyBoundaryMesh", "boundary") +
+		"1\n(\n    walls\n    {\n        type wall;\n        nFaces 6;\n        startFace 0;\n    }\n)\n",
+}
+
+// caseWith returns cubeCase with some files replaced; an empty text removes
+// one.
+func caseWith(replace map[string]string) map[string]string {
+	files := make(map[string]string)
+	for name, text := range cubeCase {
+		files[name] = text
+	}
+	for name, text := range replace {
+		delete(files, name)
+		if text != "" {
+			files[name] = text
+		}
+	}
+	return files
+}
+
+// tarGz packs files into a case folder, as users upload them.
+func tarGz(t *testing.T, files map[string]string) *bytes.Buffer {
+	t.Helper()
+	var b bytes.Buffer
+	gz := gzip.NewWriter(&b)
+	tw := tar.NewWriter(gz)
+	for name, text := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: "cube/" + name, Mode: 0644, Size: int64(len(text)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(text)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &b
+}
+
+// writeCase stores files as the case of a simulation and returns its
+// directory.
+func writeCase(t *testing.T, uc *SimulationUseCase, configPath string, files map[string]string) string {
+	t.Helper()
+	dir := filepath.Join(uc.storagePath, configPath)
+	for name, text := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func dirEntries(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+// A rejected upload leaves neither the case nor its mesh report behind.
+func TestCreateWithFileCleanup(t *testing.T) {
+	jobErr := errors.New("quota exceeded")
+	tests := []struct {
+		name    string
+		params  SimulationParams
+		files   map[string]string
+		jobErr  error
+		wantErr error
+	}{
+		{
+			name:    "invalid case",
+			files:   caseWith(map[string]string{"system/fvSchemes": ""}),
+			wantErr: domain.ErrInvalidCase,
+		},
+		{
+			name:    "no Allrun script",
+			params:  SimulationParams{Allrun: true},
+			files:   cubeCase,
+			wantErr: domain.ErrInvalidCase,
+		},
+		{
+			name:    "unreadable mesh",
+			files:   caseWith(map[string]string{"constant/polyMesh/owner": foamHeader("labelList", "owner") + "5{0}\n"}),
+			wantErr: domain.ErrInvalidCase,
+		},
+		{
+			name:    "job not created",
+			files:   cubeCase,
+			jobErr:  jobErr,
+			wantErr: jobErr,
+		},
+		{
+			name:  "submitted",
+			files: cubeCase,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc := newTestUseCase(t, &fakeK8s{createErr: tt.jobErr})
+			params := tt.params
+			params.Name, params.Type = "cube", domain.SimTypeCFD
+
+			sim, err := uc.CreateWithFile(params, tarGz(t, tt.files), "cube.tar.gz")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateWithFile error = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr != nil {
+				if names := dirEntries(t, uc.storagePath); len(names) != 0 {
+					t.Errorf("cases left behind: %v", names)
+				}
+				if names := dirEntries(t, uc.resultsPath); len(names) != 0 {
+					t.Errorf("results left behind: %v", names)
+				}
+				if sims, _ := uc.repo.List(); len(sims) != 0 {
+					t.Errorf("records left behind: %v", sims)
+				}
+				return
+			}
+			if _, err := os.Stat(filepath.Join(uc.storagePath, sim.ConfigPath, "system/controlDict")); err != nil {
+				t.Errorf("case of a submitted run: %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(uc.resultsPath, sim.ID, openfoam.MeshReportFile)); err != nil {
+				t.Errorf("mesh report of a submitted run: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package usecase
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/theweirdfulmurk/cfd-platform/internal/archive"
+	"github.com/theweirdfulmurk/cfd-platform/internal/calculix"
+	"github.com/theweirdfulmurk/cfd-platform/internal/domain"
+	"github.com/theweirdfulmurk/cfd-platform/internal/openfoam"
+)
+
+// unpackCase extracts an uploaded OpenFOAM archive and moves the case
+// itself, whatever folder the archive wrapped it in, to simDir. Solver pods
+// only ever see this normalized tree, never the archive.
+func (uc *SimulationUseCase) unpackCase(file io.Reader, simDir string) error {
+	staging := simDir + ".upload"
+	if err := archive.ExtractTarGz(file, staging, uc.limits); err != nil {
+		return fmt.Errorf("failed to unpack case: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	caseDir, err := openfoam.FindCaseDir(staging)
+	if err != nil {
+		return fmt.Errorf("%w: %v", domain.ErrUnsupportedInput, err)
+	}
+	if err := os.Rename(caseDir, simDir); err != nil {
+		return fmt.Errorf("failed to store case: %w", err)
+	}
+
+	// Catch broken dictionaries here rather than minutes into a Job.
+	if err := openfoam.ValidateCase(simDir); err != nil {
+		return fmt.Errorf("%w: %v", domain.ErrInvalidCase, err)
+	}
+	return nil
+}
+
+// saveInput stores a single-file input deck as simDir/input.inp and checks
+// the whole deck. The client's filename is never used as a path.
+func saveInput(file io.Reader, simDir string) error {
+	if err := os.MkdirAll(simDir, 0755); err != nil {
+		return fmt.Errorf("failed to create simulation directory: %w", err)
+	}
+
+	destFile, err := os.Create(filepath.Join(simDir, "input.inp"))
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer destFile.Close()
+
+	if _, err := io.Copy(destFile, file); err != nil {
+		return fmt.Errorf("failed to save file: %w", err)
+	}
+
+	if err := calculix.ValidateDeck(simDir, "input.inp"); err != nil {
+		return fmt.Errorf("%w: %v", domain.ErrInvalidCase, err)
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package usecase
 
 import (
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/google/uuid"
@@ -36,7 +37,7 @@ func (uc *VisualizationUseCase) Create(simulationID, resultPath string) (*domain
 		UpdatedAt:    time.Now(),
 	}
 
-	if err := uc.k8sManager.CreatePod(vizID, resultPath); err != nil {
+	if err := uc.k8sManager.CreatePod(viz); err != nil {
 		return nil, fmt.Errorf("failed to create visualization pod: %w", err)
 	}
 
@@ -48,20 +49,22 @@ func (uc *VisualizationUseCase) Create(simulationID, resultPath string) (*domain
 }
 
 func (uc *VisualizationUseCase) GetByID(vizID string) (*domain.Visualization, error) {
-	viz, err := uc.repo.GetByID(vizID)
-	if err != nil {
-		return nil, err
-	}
+	return uc.repo.GetByID(vizID)
+}
 
-	// Update status from k8s
-	status, err := uc.k8sManager.GetPodStatus(vizID)
-	if err == nil && status != viz.Status {
-		viz.Status = status
-		viz.UpdatedAt = time.Now()
-		uc.repo.Update(viz)
+// ApplyPodState records the state of a viz Pod as observed by the status
+// watcher. Pods without a record are left to the reconciler.
+func (uc *VisualizationUseCase) ApplyPodState(observed *domain.Visualization) {
+	viz, err := uc.repo.GetByID(observed.ID)
+	if err != nil || viz.Status == observed.Status {
+		return
 	}
 
-	return viz, nil
+	viz.Status = observed.Status
+	viz.UpdatedAt = time.Now()
+	if err := uc.repo.Update(viz); err != nil {
+		log.Printf("failed to update visualization %s status: %v", viz.ID, err)
+	}
 }
 
 func (uc *VisualizationUseCase) GetWebSocketURL(vizID string) (string, error) {
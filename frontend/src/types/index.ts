@@ -1,6 +1,6 @@
 export type SimulationType = 'cfd' | 'fea';
 
-export type SimulationStatus = 'pending' | 'running' | 'completed' | 'failed';
+export type SimulationStatus = 'pending' | 'running' | 'completed' | 'failed' | 'lost' | 'diverged' | 'cancelled' | 'suspended';
 
 export interface Simulation {
   ID: string;           
@@ -11,9 +11,46 @@ export interface Simulation {
   CreatedAt: string;
   StartedAt?: string;
   CompletedAt?: string;
+  StatusReason?: string;
+  ParentID?: string;
+  Resources?: {
+    CPU?: string;
+    Memory?: string;
+    EphemeralStorage?: string;
+  };
+  Subdomains?: number;
+  Nodes?: number;
+  Solver?: string;
+  Run?: {
+    Application: string;
+    Allrun: boolean;
+    PostProcess: string[] | null;
+    Stages: {
+      Name: string;
+      Builtin: string;
+      Args: string[] | null;
+      If: string;
+      Unless: string;
+      Always: boolean;
+    }[] | null;
+  };
 }
 
-export type VisualizationStatus = 'pending' | 'running' | 'ready' | 'failed';
+export interface Solver {
+  Name: string;
+  Version: string;
+  Type: SimulationType;
+  Image: string;
+  Command: string;
+  Resources: {
+    CPU?: string;
+    Memory?: string;
+    EphemeralStorage?: string;
+  };
+  InputFormats: string[];
+}
+
+export type VisualizationStatus = 'pending' | 'running' | 'ready' | 'failed' | 'lost';
 
 export interface Visualization {
   id: string;